@@ -0,0 +1,182 @@
+package main
+
+import (
+	"strings"
+
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/libs/fuzzy"
+	"github.com/vypal/vedit/ui/commands"
+	"github.com/vypal/vedit/ui/widgets"
+)
+
+// registry holds every command of the window. Toolbar buttons, menus, keys
+// and the command palette all run commands from it by ID.
+var registry = commands.NewRegistry()
+
+var palette = &widgets.Picker{Colors: colors, Hint: "Run a command"}
+
+var keybindings = &widgets.Picker{Colors: colors, Hint: "Search keyboard shortcuts"}
+
+func setupCommands(th *material.Theme) {
+	edit.RegisterCommands(registry)
+	files.RegisterCommands(registry)
+	registry.Register(
+		commands.Command{ID: "file.new", Title: "New File", Keys: []string{"Ctrl+N"}, Run: newDocument},
+		commands.Command{ID: "file.open", Title: "Open File…", Keys: []string{"Ctrl+O"}, Run: askOpen},
+		commands.Command{ID: "file.quickOpen", Title: "Go to File…", Keys: []string{"Ctrl+P"}, Run: showQuickOpen},
+		commands.Command{ID: "file.save", Title: "Save", Keys: []string{"Ctrl+S"}, Run: edit.Save},
+		commands.Command{ID: "file.saveAs", Title: "Save As…", Keys: []string{"Ctrl+Shift+S"}, Run: askSaveAs},
+		commands.Command{ID: "file.close", Title: "Close Tab", Keys: []string{"Ctrl+W"}, Run: func() {
+			closeDocument(buffers.Active())
+		}},
+		commands.Command{ID: "find.find", Title: "Find", Keys: []string{"Ctrl+F"}, Context: commands.Editor, Run: func() {
+			openFind(false)
+		}},
+		commands.Command{ID: "find.replace", Title: "Replace", Keys: []string{"Ctrl+H"}, Context: commands.Editor, Run: func() {
+			openFind(true)
+		}},
+		commands.Command{ID: "search.files", Title: "Find in Files", Keys: []string{"Ctrl+Shift+F"}, Run: openSearch},
+		commands.Command{ID: "view.explorer", Title: "Show File Tree", Keys: []string{"Ctrl+Shift+E"}, Run: func() {
+			showSearch = false
+		}},
+		commands.Command{ID: "view.toggleSearch", Title: "Switch Between File Tree and Find in Files", Run: func() {
+			if showSearch {
+				showSearch = false
+			} else {
+				openSearch()
+			}
+		}},
+		commands.Command{ID: "view.loadTheme", Title: "Load Color Theme…", Run: func() {
+			prompt.Show("Theme:", *themePath, func(path string) {
+				*themePath = path
+				loadTheme(path, th)
+			})
+		}},
+		commands.Command{ID: "view.commandPalette", Title: "Show All Commands", Keys: []string{"Ctrl+Shift+P"}, Run: func() {
+			palette.Show("")
+		}},
+		commands.Command{ID: "view.keybindings", Title: "Show Keyboard Shortcuts", Keys: []string{"Ctrl+K Ctrl+S"}, Run: func() {
+			keybindings.Show("")
+		}},
+	)
+	setupPalette()
+	setupKeybindings()
+}
+
+// setupPalette lists the commands that can run now in the palette, matched
+// against their titles.
+func setupPalette() {
+	var shown []commands.Command
+	palette.OnHide = edit.Focus
+	palette.Filter = func(query string) []widgets.PickerItem {
+		shown = shown[:0]
+		var titles []string
+		for _, c := range registry.Commands() {
+			if c.Enabled == nil || c.Enabled() {
+				shown = append(shown, c)
+				titles = append(titles, c.Title)
+			}
+		}
+		// Without a query the commands are listed in the order they were
+		// registered.
+		if strings.TrimSpace(query) == "" {
+			items := make([]widgets.PickerItem, len(shown))
+			for i, c := range shown {
+				items[i] = widgets.PickerItem{Title: c.Title, Detail: keysOf(c.ID)}
+			}
+			return items
+		}
+		results := fuzzy.Rank(query, titles, len(titles), nil)
+		ranked := make([]commands.Command, len(results))
+		items := make([]widgets.PickerItem, len(results))
+		for i, r := range results {
+			ranked[i] = shown[r.Index]
+			items[i] = widgets.PickerItem{Title: titles[r.Index], TitleMatched: r.Positions, Detail: keysOf(ranked[i].ID)}
+		}
+		shown = ranked
+		return items
+	}
+	palette.OnPick = func(i int) {
+		registry.Run(shown[i].ID)
+	}
+}
+
+// setupKeybindings lists every binding in the keyboard shortcuts viewer,
+// with the command and context it is bound to and what it conflicts with.
+// It is searched by command title and keys; picking one runs its command.
+func setupKeybindings() {
+	var shown []commands.Binding
+	keybindings.OnHide = edit.Focus
+	keybindings.Filter = func(query string) []widgets.PickerItem {
+		conflicts := map[int]string{}
+		bindings := registry.Bindings()
+		for _, c := range registry.Conflicts() {
+			for i, b := range bindings {
+				if b.Command == c.Binding.Command && b.Chord.String() == c.Binding.Chord.String() {
+					conflicts[i] = c.String()
+				}
+			}
+		}
+		// Commands without keys are listed too, so that they can be found
+		// when writing a keymap file.
+		bound := map[string]bool{}
+		for _, b := range bindings {
+			bound[b.Command] = true
+		}
+		for _, c := range registry.Commands() {
+			if !bound[c.ID] {
+				bindings = append(bindings, commands.Binding{Command: c.ID})
+			}
+		}
+		all := make([]widgets.PickerItem, len(bindings))
+		texts := make([]string, len(bindings))
+		for i, b := range bindings {
+			c, _ := registry.Command(b.Command)
+			keys := b.Chord.String()
+			if keys == "" {
+				keys = "unbound"
+			}
+			all[i] = widgets.PickerItem{Title: c.Title, Detail: keys + "  " + c.ID}
+			if c.Context != commands.Global {
+				all[i].Detail += " (" + c.Context + ")"
+			}
+			if b.User {
+				all[i].Detail += "  user"
+			}
+			if text, ok := conflicts[i]; ok {
+				all[i].Detail += "  conflict: " + text
+			}
+			texts[i] = c.Title + " " + keys
+		}
+		if strings.TrimSpace(query) == "" {
+			shown = bindings
+			return all
+		}
+		results := fuzzy.Rank(query, texts, len(texts), nil)
+		shown = make([]commands.Binding, len(results))
+		items := make([]widgets.PickerItem, len(results))
+		for k, r := range results {
+			shown[k] = bindings[r.Index]
+			items[k] = all[r.Index]
+			title := len([]rune(items[k].Title))
+			for _, p := range r.Positions {
+				if p < title {
+					items[k].TitleMatched = append(items[k].TitleMatched, p)
+				}
+			}
+		}
+		return items
+	}
+	keybindings.OnPick = func(i int) {
+		registry.Run(shown[i].Command)
+	}
+}
+
+// keysOf lists the keys bound to the command id.
+func keysOf(id string) string {
+	var keys []string
+	for _, k := range registry.KeysOf(id) {
+		keys = append(keys, k.String())
+	}
+	return strings.Join(keys, ", ")
+}
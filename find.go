@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vypal/vedit/libs/search"
+	"github.com/vypal/vedit/ui/widgets"
+)
+
+var findBar = &widgets.FindBar{Colors: colors}
+
+// findOrigin is where the selection started when the find bar was opened.
+// While the pattern is typed the first match from there is selected.
+var findOrigin int
+
+// openFind shows the find bar, searching for the selected text, along with
+// the replace line if replace is set.
+func openFind(replace bool) {
+	// Search for the selected text, unless it spans lines.
+	pattern := edit.SelectedText()
+	if strings.Contains(pattern, "\n") {
+		pattern = ""
+	}
+	findOrigin, _ = edit.Selection()
+	findBar.Show(pattern, replace)
+}
+
+func setupFind() {
+	findBar.OnChange = func(pattern string, opts search.Options) {
+		if pattern == "" {
+			edit.SetQuery(nil)
+			return
+		}
+		q, err := search.Compile(pattern, opts)
+		if err != nil {
+			edit.SetQuery(nil)
+			findBar.Status = "Invalid pattern"
+			return
+		}
+		edit.SetQuery(q)
+		edit.FindFrom(findOrigin)
+	}
+	findBar.OnNext = func(backward bool) {
+		edit.FindNext(backward)
+	}
+	findBar.OnReplace = func(replacement string) {
+		edit.Replace(replacement)
+	}
+	findBar.OnReplaceAll = func(replacement string) {
+		edit.ReplaceAll(replacement)
+	}
+	findBar.OnHide = func() {
+		edit.SetQuery(nil)
+		edit.Focus()
+	}
+}
+
+// updateFindStatus shows how many matches there are and which is selected.
+func updateFindStatus() {
+	if edit.Query() == nil {
+		if findBar.Pattern() == "" {
+			findBar.Status = ""
+		}
+		return
+	}
+	n := len(edit.Matches())
+	switch i := edit.CurrentMatch(); {
+	case n == 0:
+		findBar.Status = "No results"
+	case i >= 0:
+		findBar.Status = fmt.Sprintf("%d of %d", i+1, n)
+	default:
+		findBar.Status = fmt.Sprintf("%d found", n)
+	}
+}
@@ -2,7 +2,11 @@ module github.com/vypal/vedit
 
 go 1.21.3
 
-require gioui.org v0.7.0
+require (
+	gioui.org v0.7.0
+	github.com/BurntSushi/toml v1.4.0
+	golang.org/x/image v0.5.0
+)
 
 require (
 	gioui.org/cpu v0.0.0-20210817075930-8d6a761490d2 // indirect
@@ -10,7 +14,6 @@ require (
 	github.com/go-text/typesetting v0.1.1 // indirect
 	golang.org/x/exp v0.0.0-20221012211006-4de253d81b95 // indirect
 	golang.org/x/exp/shiny v0.0.0-20220827204233-334a2380cb91 // indirect
-	golang.org/x/image v0.5.0 // indirect
 	golang.org/x/sys v0.5.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 )
@@ -0,0 +1,60 @@
+package main
+
+import "github.com/vypal/vedit/ui/commands"
+
+// presets are the keymaps picked by the keybindings setting. They are
+// applied like a keymap file, under the user's.
+var presets = map[string][]commands.KeymapEntry{
+	"emacs": emacsKeymap,
+}
+
+// emacsKeymap binds Emacs's keys. The default keys in their way are removed
+// as Control chords, which only name them where Control is the shortcut
+// modifier; on macOS the Command keys are left alone. Chords only go on
+// with keys that don't type, as those that do would type their text too.
+var emacsKeymap = []commands.KeymapEntry{
+	{Key: "Control+A", Command: "-edit.selectAll"},
+	{Key: "Control+X", Command: "-edit.cut"},
+	{Key: "Control+Y", Command: "-edit.redo"},
+	{Key: "Control+D", Command: "-cursor.addNextOccurrence"},
+	{Key: "Control+F", Command: "-find.find"},
+	{Key: "Control+N", Command: "-file.new"},
+	{Key: "Control+P", Command: "-file.quickOpen"},
+	{Key: "Control+S", Command: "-file.save"},
+	{Key: "Control+W", Command: "-file.close"},
+	{Key: "Control+K Control+S", Command: "-view.keybindings"},
+
+	{Key: "Control+F", Command: "cursor.right"},
+	{Key: "Control+B", Command: "cursor.left"},
+	{Key: "Control+N", Command: "cursor.down"},
+	{Key: "Control+P", Command: "cursor.up"},
+	{Key: "Control+A", Command: "cursor.lineStart"},
+	{Key: "Control+E", Command: "cursor.lineEnd"},
+	{Key: "Alt+F", Command: "cursor.wordRight"},
+	{Key: "Alt+B", Command: "cursor.wordLeft"},
+	{Key: "Alt+Shift+<", Command: "cursor.fileStart"},
+	{Key: "Alt+Shift+>", Command: "cursor.fileEnd"},
+
+	{Key: "Control+D", Command: "edit.deleteForward"},
+	{Key: "Alt+D", Command: "edit.killWord"},
+	{Key: "Alt+Backspace", Command: "edit.killWordBackward"},
+	{Key: "Control+K", Command: "edit.killLine"},
+	{Key: "Control+W", Command: "edit.killRegion"},
+	{Key: "Alt+W", Command: "edit.copyRegion"},
+	{Key: "Control+Y", Command: "edit.paste"},
+	{Key: "Alt+Y", Command: "edit.pasteCycle"},
+	{Key: "Control+Space", Command: "edit.setMark"},
+	{Key: "Control+X Control+X", Command: "edit.exchangeMark"},
+	{Key: "Control+G", Command: "edit.cancel"},
+	{Key: "Control+/", Command: "edit.undo"},
+
+	{Key: "Control+S", Command: "find.incremental"},
+	{Key: "Control+R", Command: "find.incrementalBackward"},
+	{Key: "Alt+Shift+%", Command: "find.replace"},
+
+	{Key: "Control+X Control+F", Command: "file.open"},
+	{Key: "Control+X Control+S", Command: "file.save"},
+	{Key: "Control+X Control+W", Command: "file.saveAs"},
+	{Key: "Control+X Control+B", Command: "file.quickOpen"},
+	{Key: "Alt+X", Command: "view.commandPalette"},
+}
@@ -0,0 +1,137 @@
+package libs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ScanOptions controls which entries Directory.Load lists.
+type ScanOptions struct {
+	// ShowHidden lists files and directories whose name starts with a dot.
+	ShowHidden bool
+	// UseGitIgnore leaves out whatever .gitignore files ignore.
+	UseGitIgnore bool
+}
+
+// OpenDirectory returns the directory at path without reading it yet.
+func OpenDirectory(path string) (*Directory, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	d := NewDirectory(filepath.Base(abs), filepath.Dir(abs))
+	return &d, nil
+}
+
+// FullPath returns the path of the directory including its name.
+func (d *Directory) FullPath() string {
+	return filepath.Join(d.Path, d.Name)
+}
+
+// Loaded reports whether the entries of the directory have been read.
+func (d *Directory) Loaded() bool {
+	return d.loaded
+}
+
+// Load reads the entries of the directory, but not of its subdirectories,
+// which are filled when they are loaded in turn. Reloading keeps the contents
+// of subdirectories that were already loaded.
+func (d *Directory) Load(opts ScanOptions) error {
+	dir := d.FullPath()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	ignore := d.ignore
+	if opts.UseGitIgnore {
+		ignore = LoadGitIgnore(d.ignore, dir)
+	}
+	old := make(map[string]Directory, len(d.Directories))
+	for _, sub := range d.Directories {
+		old[sub.Name] = sub
+	}
+
+	d.Files = d.Files[:0]
+	d.Directories = d.Directories[:0]
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == ".git" || (!opts.ShowHidden && strings.HasPrefix(name, ".")) {
+			continue
+		}
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if stat, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				isDir = stat.IsDir()
+			}
+		}
+		if opts.UseGitIgnore && ignore.Ignored(filepath.Join(dir, name), isDir) {
+			continue
+		}
+		if isDir {
+			sub, ok := old[name]
+			if !ok {
+				sub = NewDirectory(name, dir)
+			}
+			sub.ignore = ignore
+			d.Directories = append(d.Directories, sub)
+		} else {
+			d.Files = append(d.Files, NewFile(name, dir, ""))
+		}
+	}
+	sort.Slice(d.Directories, func(i, j int) bool {
+		return lessName(d.Directories[i].Name, d.Directories[j].Name)
+	})
+	sort.Slice(d.Files, func(i, j int) bool {
+		return lessName(d.Files[i].Name, d.Files[j].Name)
+	})
+	d.loaded = true
+	return nil
+}
+
+// Walk calls fn for every file below the directory, loading subdirectories
+// as it goes. Directories that can't be read are skipped, and so are those
+// already walked through another symlink, so that a link to a parent doesn't
+// send Walk round in circles. Walking stops at the first error returned by
+// fn.
+func (d *Directory) Walk(opts ScanOptions, fn func(f *File) error) error {
+	return d.walk(opts, fn, make(map[string]bool))
+}
+
+// walk walks d unless seen holds it, adding the directories it reaches to
+// seen by their path with symlinks resolved.
+func (d *Directory) walk(opts ScanOptions, fn func(f *File) error, seen map[string]bool) error {
+	if real, err := filepath.EvalSymlinks(d.FullPath()); err == nil {
+		if seen[real] {
+			return nil
+		}
+		seen[real] = true
+	}
+	if !d.loaded {
+		if err := d.Load(opts); err != nil {
+			return nil
+		}
+	}
+	for i := range d.Files {
+		if err := fn(&d.Files[i]); err != nil {
+			return err
+		}
+	}
+	for i := range d.Directories {
+		if err := d.Directories[i].walk(opts, fn, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lessName(a, b string) bool {
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	if la != lb {
+		return la < lb
+	}
+	return a < b
+}
@@ -0,0 +1,50 @@
+package libs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWalkSymlinkLoop(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("..", filepath.Join(root, "sub", "loop")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "c.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(other, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := OpenDirectory(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	err = dir.Walk(ScanOptions{}, func(f *File) error {
+		rel, err := filepath.Rel(root, f.FullPath())
+		paths = append(paths, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	if got := strings.Join(paths, " "); got != "a.txt link/c.txt sub/b.txt" {
+		t.Errorf("walked %q", got)
+	}
+}
@@ -0,0 +1,88 @@
+package libs
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+)
+
+// errStale stops the walk of an index that a newer one replaced.
+var errStale = errors.New("index replaced")
+
+// FileIndex is the list of every file below a directory, built in the
+// background. It is safe to use from several goroutines.
+type FileIndex struct {
+	mu       sync.Mutex
+	root     string
+	paths    []string
+	building bool
+	// generation tells the walk that is current from those it replaced.
+	generation int
+}
+
+// Refresh lists the files below root again in a goroutine, calling changed
+// whenever more of them are known. When root is the directory already
+// indexed the old list is kept until the new one is complete; otherwise
+// files are added as they are found.
+func (x *FileIndex) Refresh(root string, opts ScanOptions, changed func()) {
+	x.mu.Lock()
+	x.generation++
+	gen := x.generation
+	fresh := root != x.root
+	if fresh {
+		x.root, x.paths = root, nil
+	}
+	x.building = true
+	x.mu.Unlock()
+
+	go func() {
+		var paths []string
+		// publish makes the files found so far visible, for a fresh index.
+		publish := func(final bool) error {
+			x.mu.Lock()
+			defer x.mu.Unlock()
+			if gen != x.generation {
+				return errStale
+			}
+			if fresh || final {
+				x.paths = paths[:len(paths):len(paths)]
+			}
+			x.building = !final
+			return nil
+		}
+		dir, err := OpenDirectory(root)
+		if err == nil {
+			err = dir.Walk(opts, func(f *File) error {
+				rel, err := filepath.Rel(root, f.FullPath())
+				if err != nil {
+					rel = f.FullPath()
+				}
+				paths = append(paths, rel)
+				if len(paths)%5000 == 0 {
+					if err := publish(false); err != nil {
+						return err
+					}
+					if fresh && changed != nil {
+						changed()
+					}
+				}
+				return nil
+			})
+		}
+		if err == errStale || publish(true) != nil {
+			return
+		}
+		if changed != nil {
+			changed()
+		}
+	}()
+}
+
+// Files returns the directory indexed and the paths of the files in it,
+// relative to it. The slice must not be modified. building reports whether
+// the index is still being built.
+func (x *FileIndex) Files() (root string, paths []string, building bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.root, x.paths, x.building
+}
@@ -0,0 +1,44 @@
+package libs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileIndex(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.go", "sub/b.go", "sub/deeper/c.txt"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var x FileIndex
+	done := make(chan struct{}, 1)
+	x.Refresh(root, ScanOptions{}, func() { done <- struct{}{} })
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the index wasn't built")
+	}
+	gotRoot, paths, building := x.Files()
+	if gotRoot != root || building {
+		t.Errorf("Files() = %q, building %v, want %q, done", gotRoot, building, root)
+	}
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	for i := range sorted {
+		sorted[i] = filepath.ToSlash(sorted[i])
+	}
+	if got := strings.Join(sorted, " "); got != "a.go sub/b.go sub/deeper/c.txt" {
+		t.Errorf("indexed %q", got)
+	}
+}
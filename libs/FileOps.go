@@ -0,0 +1,185 @@
+package libs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// CreateFile creates an empty file at path. It fails if something already
+// exists there.
+func CreateFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// CreateDirectory creates a directory at path along with any missing parents.
+func CreateDirectory(path string) error {
+	if _, err := os.Lstat(path); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrExist}
+	}
+	return os.MkdirAll(path, 0o777)
+}
+
+// Rename gives the file or directory at path a new name in the same
+// directory and returns its new path.
+func Rename(path, name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, filepath.Separator) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	dst := filepath.Join(filepath.Dir(path), name)
+	return dst, Move(path, dst)
+}
+
+// rename is os.Rename, which tests replace to make it fail.
+var rename = os.Rename
+
+// Move moves the file or directory at src to dst, which must not exist yet.
+// Moves between file systems fall back to copying and deleting.
+func Move(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return &fs.PathError{Op: "move", Path: dst, Err: fs.ErrExist}
+	}
+	if isWithin(dst, src) {
+		return fmt.Errorf("cannot move %s into itself", src)
+	}
+	if err := rename(src, dst); !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := Copy(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// Copy copies the file or directory at src to dst, which must not exist yet.
+// Directories are copied recursively and symlinks are copied as links.
+func Copy(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return &fs.PathError{Op: "copy", Path: dst, Err: fs.ErrExist}
+	}
+	if isWithin(dst, src) {
+		return fmt.Errorf("cannot copy %s into itself", src)
+	}
+	return copyTree(src, dst)
+}
+
+func copyTree(src, dst string) error {
+	stat, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case stat.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case stat.IsDir():
+		if err := os.Mkdir(dst, stat.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyTree(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return copyFile(src, dst, stat.Mode().Perm())
+	}
+}
+
+// isWithin reports whether path is dir or lies below it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// TrashDir returns the home trash directory of the freedesktop.org trash
+// specification.
+func TrashDir() (string, error) {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "Trash"), nil
+}
+
+// Trash moves the file or directory at path to the trash, recording where it
+// came from so that file managers can restore it.
+func Trash(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(abs); err != nil {
+		return err
+	}
+	trash, err := TrashDir()
+	if err != nil {
+		return err
+	}
+	files := filepath.Join(trash, "files")
+	info := filepath.Join(trash, "info")
+	if err := os.MkdirAll(files, 0o700); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(info, 0o700); err != nil {
+		return err
+	}
+
+	// Reserve a name by creating its info file, which the specification
+	// requires to happen atomically before the file itself is moved.
+	base := filepath.Base(abs)
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s.%d", base, i)
+		}
+		infoPath := filepath.Join(info, name+".trashinfo")
+		file, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(filepath.Join(files, name)); err == nil {
+			// Left behind without its info file.
+			file.Close()
+			os.Remove(infoPath)
+			continue
+		}
+		_, err = fmt.Fprintf(file, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+			(&url.URL{Path: abs}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = Move(abs, filepath.Join(files, name))
+		}
+		if err != nil {
+			os.Remove(infoPath)
+		}
+		return err
+	}
+}
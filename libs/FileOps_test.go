@@ -0,0 +1,127 @@
+package libs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestTrash(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := t.TempDir()
+	trash, err := TrashDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two files of the same name get different names in the trash.
+	for i, text := range []string{"first", "second"} {
+		path := filepath.Join(dir, "my file.txt")
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Trash(path); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Fatalf("file still there after Trash: %v", err)
+		}
+
+		name := "my file.txt"
+		if i > 0 {
+			name += ".2"
+		}
+		got, err := os.ReadFile(filepath.Join(trash, "files", name))
+		if err != nil || string(got) != text {
+			t.Errorf("trashed %s = %q, %v, want %q", name, got, err, text)
+		}
+		info, err := os.ReadFile(filepath.Join(trash, "info", name+".trashinfo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantPath := "Path=" + strings.ReplaceAll(filepath.ToSlash(path), " ", "%20") + "\n"
+		if !strings.HasPrefix(string(info), "[Trash Info]\n") || !strings.Contains(string(info), wantPath) ||
+			!strings.Contains(string(info), "DeletionDate=") {
+			t.Errorf("info file =\n%s\nwant the header, %q and a deletion date", info, wantPath)
+		}
+	}
+}
+
+func TestTrashDirectory(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	dir := filepath.Join(t.TempDir(), "project")
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Trash(dir); err != nil {
+		t.Fatal(err)
+	}
+	trash, _ := TrashDir()
+	if _, err := os.Stat(filepath.Join(trash, "files", "project", "src", "main.go")); err != nil {
+		t.Errorf("directory contents not in the trash: %v", err)
+	}
+}
+
+func TestTrashMissing(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	if err := Trash(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("Trash of a missing file = %v, want a not-exist error", err)
+	}
+	trash, _ := TrashDir()
+	if entries, _ := os.ReadDir(filepath.Join(trash, "info")); len(entries) > 0 {
+		t.Errorf("left %d info files behind", len(entries))
+	}
+}
+
+// failRename makes Move's rename fail with err until the test ends.
+func failRename(t *testing.T, err error) {
+	t.Cleanup(func() { rename = os.Rename })
+	rename = func(src, dst string) error {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: err}
+	}
+}
+
+func TestMoveAcrossFileSystems(t *testing.T) {
+	failRename(t, syscall.EXDEV)
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Move(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dst, "sub", "a.txt")); err != nil || string(got) != "a" {
+		t.Errorf("moved file = %q, %v, want %q", got, err, "a")
+	}
+	if _, err := os.Lstat(src); !os.IsNotExist(err) {
+		t.Errorf("source still there after Move: %v", err)
+	}
+}
+
+func TestMoveRenameError(t *testing.T) {
+	failRename(t, syscall.EACCES)
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src.txt"), filepath.Join(dir, "dst.txt")
+	if err := os.WriteFile(src, []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Move(src, dst); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("Move error = %v, want the rename error", err)
+	}
+	if got, err := os.ReadFile(src); err != nil || string(got) != "a" {
+		t.Errorf("source after a failed Move = %q, %v, want it left alone", got, err)
+	}
+	if _, err := os.Lstat(dst); !os.IsNotExist(err) {
+		t.Errorf("a failed Move copied the file: %v", err)
+	}
+}
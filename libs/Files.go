@@ -2,14 +2,18 @@ package libs
 
 import (
 	"errors"
+	"io"
 	"os"
+	"path/filepath"
 )
 
 type File struct {
 	File     *os.File
 	Name     string
 	Path     string
-	Contents []rune
+	Contents []byte
+	// Backup keeps the previous version of the file as "name~" on Save.
+	Backup bool
 }
 
 type Directory struct {
@@ -17,14 +21,33 @@ type Directory struct {
 	Path        string
 	Files       []File
 	Directories []Directory
+
+	loaded bool
+	ignore *IgnoreRules
 }
 
 func NewFile(name, path, contents string) File {
 	return File{
 		Name:     name,
 		Path:     path,
-		Contents: []rune(contents),
+		Contents: []byte(contents),
+	}
+}
+
+// NewFileFromPath returns an empty File for the given path. The path is made
+// absolute and split into the directory and the file name.
+func NewFileFromPath(path string) (File, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return File{}, err
 	}
+	dir, name := filepath.Split(abs)
+	return NewFile(name, dir, ""), nil
+}
+
+// FullPath returns the path of the file including its name.
+func (f *File) FullPath() string {
+	return filepath.Join(f.Path, f.Name)
 }
 
 func NewDirectory(name, path string) Directory {
@@ -43,12 +66,12 @@ func (f *File) Touch() error {
 		return errors.New("File name is empty")
 	}
 
-	if _, err := os.Stat(f.Path + f.Name); err != nil && os.IsNotExist(err) {
-		file, err := os.Create(f.Path + f.Name)
+	if _, err := os.Stat(f.FullPath()); err != nil && os.IsNotExist(err) {
+		file, err := os.Create(f.FullPath())
 		if err != nil {
 			return err
 		}
-		defer file.Close()
+		return file.Close()
 	}
 	return nil
 }
@@ -60,43 +83,110 @@ func (f *File) Load() error {
 		return errors.New("File name is empty")
 	}
 
-	file, err := os.Open(f.Path + f.Name)
+	data, err := os.ReadFile(f.FullPath())
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	stat, err := file.Stat()
+	f.Contents = data
+	return nil
+}
+
+// Save writes the contents to a temporary file next to the target and renames
+// it over the target once it is safely on disk, so the old file stays intact
+// if anything goes wrong. The mode and owner of an existing file are kept, and
+// a new one gets the usual mode of new files.
+func (f *File) Save() (err error) {
+	if f.Path == "" {
+		return errors.New("File path is empty")
+	} else if f.Name == "" {
+		return errors.New("File name is empty")
+	}
+
+	target := f.FullPath()
+	// Save through symlinks instead of replacing them.
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		target = resolved
+	}
+	stat, statErr := os.Stat(target)
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return statErr
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.tmp")
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
 
-	data := make([]byte, stat.Size())
-	_, err = file.Read(data)
-	if err != nil {
+	if _, err = tmp.Write(f.Contents); err != nil {
+		return err
+	}
+	if statErr == nil {
+		if err = tmp.Chmod(stat.Mode().Perm()); err != nil {
+			return err
+		}
+		// Only root can give files away, so failing to keep the owner is
+		// not worth losing the save over.
+		chown(tmp, stat)
+	} else if err = tmp.Chmod(newFileMode()); err != nil {
+		// CreateTemp makes the file readable by its owner alone.
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
 		return err
 	}
 
-	f.Contents = []rune(string(data))
+	if f.Backup && statErr == nil {
+		if err = copyFile(target, target+"~", stat.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if err = os.Rename(tmp.Name(), target); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(target))
 	return nil
 }
 
-func (f *File) Save() error {
-	if f.Path == "" {
-		return errors.New("File path is empty")
-	} else if f.Name == "" {
-		return errors.New("File name is empty")
+// copyFile copies src to dst, replacing dst if it exists.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
 	}
+	defer in.Close()
 
-	file, err := os.Create(f.Path + f.Name)
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
 
-	_, err = file.WriteString(string(f.Contents))
+// syncDir flushes a directory so that a rename inside it survives a crash.
+// Not every platform can open directories for this, so errors are ignored.
+func syncDir(path string) {
+	dir, err := os.Open(path)
 	if err != nil {
-		return err
+		return
 	}
-	return nil
+	dir.Sync()
+	dir.Close()
 }
@@ -0,0 +1,87 @@
+package libs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileLoadSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.txt")
+	// Larger than a single read is guaranteed to return.
+	data := bytes.Repeat([]byte("héllo wörld\n"), 1<<16)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewFileFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Contents, data) {
+		t.Fatalf("Load read %d bytes, want %d", len(f.Contents), len(data))
+	}
+
+	f.Contents = []byte("changed\n")
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "changed\n" {
+		t.Errorf("saved %q, want %q", got, "changed\n")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode after save = %v, want 0600", info.Mode().Perm())
+	}
+	if _, err := os.Stat(path + "~"); !os.IsNotExist(err) {
+		t.Errorf("saving without Backup left a backup: %v", err)
+	}
+}
+
+func TestFileSaveBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFileFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Backup = true
+	f.Contents = []byte("new")
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{path: "new", path + "~": "old"} {
+		if got, err := os.ReadFile(name); err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(name), got, err, want)
+		}
+	}
+}
+
+func TestFileSaveNewMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new.txt")
+	f := NewFile(filepath.Base(path), filepath.Dir(path), "new")
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// CreateTemp would have left the file private to its owner.
+	if got, want := info.Mode().Perm(), newFileMode(); got != want {
+		t.Errorf("mode of a new file = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+package libs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitIgnore(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(dir, text string) {
+		if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(root, `# build output
+*.log
+!keep.log
+build/
+/top.txt
+docs/**/*.tmp
+\#hash
+file?.[ch]
+`)
+	write(sub, "local.txt\n!*.log\n")
+
+	rootRules := LoadGitIgnore(nil, root)
+	subRules := LoadGitIgnore(rootRules, sub)
+	tests := []struct {
+		rules *IgnoreRules
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{rootRules, "app.log", false, true},
+		{rootRules, "deep/down/app.log", false, true},
+		{rootRules, "keep.log", false, false},
+		{rootRules, "build", true, true},
+		{rootRules, "build", false, false},
+		{rootRules, "src/build", true, true},
+		{rootRules, "top.txt", false, true},
+		{rootRules, "src/top.txt", false, false},
+		{rootRules, "docs/a/b/x.tmp", false, true},
+		{rootRules, "docs/x.tmp", false, true},
+		{rootRules, "other/x.tmp", false, false},
+		{rootRules, "#hash", false, true},
+		{rootRules, "file1.c", false, true},
+		{rootRules, "file1.go", false, false},
+		{rootRules, "file12.c", false, false},
+		{rootRules, "main.go", false, false},
+		// Rules of a nested .gitignore apply below it, after the inherited ones.
+		{subRules, "sub/local.txt", false, true},
+		{subRules, "sub/app.log", false, false},
+		{subRules, "local.txt", false, false},
+		{subRules, "sub/build", true, true},
+		{nil, "app.log", false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.rules.Ignored(filepath.Join(root, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestGitIgnoreMissing(t *testing.T) {
+	parent := &IgnoreRules{}
+	if got := LoadGitIgnore(parent, t.TempDir()); got != parent {
+		t.Error("a directory without .gitignore didn't keep the parent's rules")
+	}
+}
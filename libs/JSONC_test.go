@@ -0,0 +1,19 @@
+package libs
+
+import "testing"
+
+func TestStripJSONC(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"{\"a\": 1 // note\n}", "{\"a\": 1 \n}"},
+		{`{"a": /* x */ 1}`, `{"a":  1}`},
+		{`{"a": [1, 2,], }`, `{"a": [1, 2] }`},
+		{`{"url": "http://x/*y*/", "q": "a\"//b"}`, `{"url": "http://x/*y*/", "q": "a\"//b"}`},
+		{`{"a": 1 /* open`, `{"a": 1 `},
+	}
+	for _, tt := range tests {
+		if got := string(StripJSONC([]byte(tt.in))); got != tt.want {
+			t.Errorf("StripJSONC(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package libs
+
+import "os"
+
+// chown does nothing on platforms without Unix file ownership.
+func chown(file *os.File, stat os.FileInfo) {}
+
+// newFileMode is the mode a new file gets where there is no umask.
+func newFileMode() os.FileMode {
+	return 0o644
+}
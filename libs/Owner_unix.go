@@ -0,0 +1,24 @@
+//go:build unix
+
+package libs
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+// chown gives file the owner and group from stat, ignoring failures.
+func chown(file *os.File, stat os.FileInfo) {
+	if sys, ok := stat.Sys().(*syscall.Stat_t); ok {
+		file.Chown(int(sys.Uid), int(sys.Gid))
+	}
+}
+
+// newFileMode is the mode a new file gets: 0666 less the umask, which can
+// only be read by setting it, so it is read once and put back.
+var newFileMode = sync.OnceValue(func() os.FileMode {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	return 0o666 &^ os.FileMode(mask)
+})
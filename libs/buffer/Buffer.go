@@ -0,0 +1,30 @@
+package buffer
+
+// Buffer is an editable text store. All offsets are in runes.
+type Buffer interface {
+	Len() int
+	RuneAt(pos int) rune
+	Insert(pos int, text string)
+	Delete(start, end int)
+	Slice(start, end int) string
+	String() string
+
+	// LineCount returns the number of lines, which is always at least one.
+	LineCount() int
+	// LineStart returns the offset of the first rune of the line.
+	LineStart(line int) int
+	// LineEnd returns the offset of the line's newline, or Len() for the last line.
+	LineEnd(line int) int
+	// Line returns the line's text without the trailing newline.
+	Line(line int) string
+
+	// Position converts an offset to a zero-based line and column.
+	Position(offset int) (line, col int)
+	// Offset converts a line and column to an offset, clamping the column to
+	// the length of the line.
+	Offset(line, col int) int
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
@@ -0,0 +1,101 @@
+package buffer
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Every node of the piece tree counts the newlines below it, which is the
+// line index: a line is found by descending to the piece holding its
+// newline, and an edit only updates the counts along the path it touched.
+
+func countNewlines(b []byte) int {
+	return bytes.Count(b, []byte{'\n'})
+}
+
+// newline returns the offset of the k-th newline, counting from one, or Len()
+// if there are fewer.
+func (pt *PieceTable) newline(k int) int {
+	n, off := pt.root, 0
+	for n != nil {
+		left := linesOf(n.left)
+		switch {
+		case k <= left:
+			n = n.left
+		case k <= left+n.p.lines:
+			return off + runesOf(n.left) + pt.newlineIn(n.p, k-left)
+		default:
+			k -= left + n.p.lines
+			off += runesOf(n.left) + n.p.runes
+			n = n.right
+		}
+	}
+	return pt.Len()
+}
+
+// newlineIn returns the rune index of the k-th newline in the piece.
+func (pt *PieceTable) newlineIn(p piece, k int) int {
+	b := pt.bytes(p)
+	i := -1
+	for ; k > 0; k-- {
+		i += 1 + bytes.IndexByte(b[i+1:], '\n')
+	}
+	if p.runes == p.size {
+		return i
+	}
+	return utf8.RuneCount(b[:i])
+}
+
+// linesBefore returns the number of newlines before offset.
+func (pt *PieceTable) linesBefore(offset int) int {
+	n, lines := pt.root, 0
+	for n != nil {
+		left := runesOf(n.left)
+		switch {
+		case offset < left:
+			n = n.left
+		case offset < left+n.p.runes:
+			b := pt.bytes(n.p)[:pt.offsetIn(n.p, offset-left)]
+			return lines + linesOf(n.left) + countNewlines(b)
+		default:
+			lines += linesOf(n.left) + n.p.lines
+			offset -= left + n.p.runes
+			n = n.right
+		}
+	}
+	return lines
+}
+
+func (pt *PieceTable) LineCount() int {
+	return linesOf(pt.root) + 1
+}
+
+func (pt *PieceTable) LineStart(line int) int {
+	if line <= 0 {
+		return 0
+	}
+	if line >= pt.LineCount() {
+		return pt.Len()
+	}
+	return pt.newline(line) + 1
+}
+
+func (pt *PieceTable) LineEnd(line int) int {
+	return pt.newline(max(line, 0) + 1)
+}
+
+func (pt *PieceTable) Line(line int) string {
+	return pt.Slice(pt.LineStart(line), pt.LineEnd(line))
+}
+
+func (pt *PieceTable) Position(offset int) (int, int) {
+	offset = clamp(offset, 0, pt.Len())
+	line := pt.linesBefore(offset)
+	return line, offset - pt.LineStart(line)
+}
+
+func (pt *PieceTable) Offset(line, col int) int {
+	line = clamp(line, 0, pt.LineCount()-1)
+	start := pt.LineStart(line)
+	return start + clamp(col, 0, pt.LineEnd(line)-start)
+}
@@ -0,0 +1,316 @@
+package buffer
+
+import (
+	"math/rand"
+	"strings"
+	"unicode/utf8"
+)
+
+type source uint8
+
+const (
+	original source = iota
+	added
+)
+
+// maxPiece is the most bytes a piece holds. Finding a rune or a line inside
+// a piece scans it, so keeping pieces small keeps lookups cheap.
+const maxPiece = 1024
+
+// piece is a run of bytes in one of the sources, together with the number of
+// runes and newlines in it.
+type piece struct {
+	src   source
+	start int
+	size  int
+	runes int
+	lines int
+}
+
+// node is a piece in a treap ordered by position in the document. Each node
+// also counts the runes and newlines of its whole subtree, which is what
+// lets lookups by offset or by line descend straight to the right piece.
+type node struct {
+	p           piece
+	left, right *node
+	prio        uint32
+	runes       int
+	lines       int
+}
+
+func (n *node) update() {
+	n.runes, n.lines = n.p.runes, n.p.lines
+	if n.left != nil {
+		n.runes += n.left.runes
+		n.lines += n.left.lines
+	}
+	if n.right != nil {
+		n.runes += n.right.runes
+		n.lines += n.right.lines
+	}
+}
+
+func runesOf(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return n.runes
+}
+
+func linesOf(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return n.lines
+}
+
+// PieceTable is a Buffer that never moves text once it is stored. The loaded
+// text and everything inserted afterwards live in two append-only byte
+// slices, and the document is a balanced tree of pieces pointing into them,
+// so an edit or a lookup only walks one path of the tree no matter how large
+// the document is.
+type PieceTable struct {
+	original []byte
+	add      []byte
+	root     *node
+}
+
+var _ Buffer = (*PieceTable)(nil)
+
+func NewPieceTable(text string) *PieceTable {
+	return NewPieceTableBytes([]byte(text))
+}
+
+// NewPieceTableBytes returns a PieceTable holding data. The table keeps data
+// instead of copying it, so the caller must not change it afterwards.
+func NewPieceTableBytes(data []byte) *PieceTable {
+	pt := &PieceTable{original: data}
+	pt.root = pt.build(original, 0, len(data))
+	return pt
+}
+
+func (pt *PieceTable) bytes(p piece) []byte {
+	if p.src == original {
+		return pt.original[p.start : p.start+p.size]
+	}
+	return pt.add[p.start : p.start+p.size]
+}
+
+func (pt *PieceTable) newPiece(src source, start, size int) piece {
+	p := piece{src: src, start: start, size: size}
+	b := pt.bytes(p)
+	p.runes = utf8.RuneCount(b)
+	p.lines = countNewlines(b)
+	return p
+}
+
+// build returns a tree of pieces covering size bytes of src from start. The
+// text is cut into pieces of at most maxPiece bytes, on rune boundaries.
+func (pt *PieceTable) build(src source, start, size int) *node {
+	b := pt.original
+	if src == added {
+		b = pt.add
+	}
+	var root *node
+	for size > 0 {
+		n := min(size, maxPiece)
+		for i := 0; i < utf8.UTFMax && n < size && !utf8.RuneStart(b[start+n]); i++ {
+			n--
+		}
+		root = merge(root, newNode(pt.newPiece(src, start, n)))
+		start += n
+		size -= n
+	}
+	return root
+}
+
+func newNode(p piece) *node {
+	n := &node{p: p, prio: rand.Uint32()}
+	n.update()
+	return n
+}
+
+// offsetIn returns the byte offset of the rune at index i of the piece.
+func (pt *PieceTable) offsetIn(p piece, i int) int {
+	if p.runes == p.size {
+		return i
+	}
+	b := pt.bytes(p)
+	off := 0
+	for ; i > 0; i-- {
+		_, n := utf8.DecodeRune(b[off:])
+		off += n
+	}
+	return off
+}
+
+// cut splits a piece before the rune at index i.
+func (pt *PieceTable) cut(p piece, i int) (piece, piece) {
+	off := pt.offsetIn(p, i)
+	return pt.newPiece(p.src, p.start, off), pt.newPiece(p.src, p.start+off, p.size-off)
+}
+
+// merge joins two trees, with all of a before all of b.
+func merge(a, b *node) *node {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.prio > b.prio {
+		a.right = merge(a.right, b)
+		a.update()
+		return a
+	}
+	b.left = merge(a, b.left)
+	b.update()
+	return b
+}
+
+// split cuts a tree into the text before pos and the text from pos on,
+// cutting the piece that pos falls inside.
+func (pt *PieceTable) split(n *node, pos int) (*node, *node) {
+	if n == nil {
+		return nil, nil
+	}
+	left := runesOf(n.left)
+	switch {
+	case pos <= left:
+		l, r := pt.split(n.left, pos)
+		n.left = r
+		n.update()
+		return l, n
+	case pos >= left+n.p.runes:
+		l, r := pt.split(n.right, pos-left-n.p.runes)
+		n.right = l
+		n.update()
+		return n, r
+	}
+	lp, rp := pt.cut(n.p, pos-left)
+	right := n.right
+	n.p, n.right = lp, nil
+	n.update()
+	return n, merge(newNode(rp), right)
+}
+
+func (pt *PieceTable) Len() int {
+	return runesOf(pt.root)
+}
+
+func (pt *PieceTable) RuneAt(pos int) rune {
+	n := pt.root
+	for n != nil {
+		left := runesOf(n.left)
+		switch {
+		case pos < left:
+			n = n.left
+		case pos < left+n.p.runes:
+			r, _ := utf8.DecodeRune(pt.bytes(n.p)[pt.offsetIn(n.p, pos-left):])
+			return r
+		default:
+			pos -= left + n.p.runes
+			n = n.right
+		}
+	}
+	return 0
+}
+
+func (pt *PieceTable) Insert(pos int, text string) {
+	if text == "" {
+		return
+	}
+	pos = clamp(pos, 0, pt.Len())
+	start := len(pt.add)
+	pt.add = append(pt.add, text...)
+	if pt.extend(pt.root, pos, start, text) {
+		return
+	}
+	l, r := pt.split(pt.root, pos)
+	pt.root = merge(merge(l, pt.build(added, start, len(text))), r)
+}
+
+// extend grows the piece ending at pos when the text was appended right
+// after it, so that typing doesn't add a piece for every key.
+func (pt *PieceTable) extend(n *node, pos, start int, text string) bool {
+	if n == nil {
+		return false
+	}
+	left := runesOf(n.left)
+	end := left + n.p.runes
+	var ok bool
+	switch {
+	case pos <= left:
+		ok = pt.extend(n.left, pos, start, text)
+	case pos > end:
+		ok = pt.extend(n.right, pos-end, start, text)
+	case pos == end:
+		p := &n.p
+		ok = p.src == added && p.start+p.size == start && p.size+len(text) <= maxPiece
+		if ok {
+			p.size += len(text)
+			p.runes += utf8.RuneCountInString(text)
+			p.lines += strings.Count(text, "\n")
+		}
+	}
+	if ok {
+		n.update()
+	}
+	return ok
+}
+
+func (pt *PieceTable) Delete(start, end int) {
+	start = clamp(start, 0, pt.Len())
+	end = clamp(end, 0, pt.Len())
+	if start >= end {
+		return
+	}
+	l, r := pt.split(pt.root, start)
+	_, r = pt.split(r, end-start)
+	pt.root = merge(l, r)
+}
+
+// walk calls fn with the bytes of every piece overlapping the range from
+// start to end, in order. The range is relative to the subtree.
+func (pt *PieceTable) walk(n *node, start, end int, fn func(b []byte)) {
+	if n == nil || start >= n.runes || end <= 0 {
+		return
+	}
+	pt.walk(n.left, start, end, fn)
+	left := runesOf(n.left)
+	if start < left+n.p.runes && end > left {
+		from := pt.offsetIn(n.p, max(start-left, 0))
+		to := pt.offsetIn(n.p, min(end-left, n.p.runes))
+		fn(pt.bytes(n.p)[from:to])
+	}
+	pt.walk(n.right, start-left-n.p.runes, end-left-n.p.runes, fn)
+}
+
+func (pt *PieceTable) Slice(start, end int) string {
+	start = clamp(start, 0, pt.Len())
+	end = clamp(end, 0, pt.Len())
+	if start >= end {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.Grow(end - start)
+	pt.walk(pt.root, start, end, func(b []byte) {
+		if utf8.Valid(b) {
+			sb.Write(b)
+			return
+		}
+		// Invalid bytes come out as U+FFFD, one per byte, just as they
+		// are counted.
+		for len(b) > 0 {
+			r, n := utf8.DecodeRune(b)
+			sb.WriteRune(r)
+			b = b[n:]
+		}
+	})
+	return sb.String()
+}
+
+func (pt *PieceTable) String() string {
+	return pt.Slice(0, pt.Len())
+}
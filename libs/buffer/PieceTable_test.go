@@ -0,0 +1,175 @@
+package buffer
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// reference is the obvious Buffer: a slice of runes edited in place.
+type reference []rune
+
+func (r *reference) insert(pos int, text string) {
+	*r = append((*r)[:pos], append([]rune(text), (*r)[pos:]...)...)
+}
+
+func (r *reference) delete(start, end int) {
+	*r = append((*r)[:start], (*r)[end:]...)
+}
+
+func checkAgainst(t *testing.T, pt *PieceTable, ref reference) {
+	t.Helper()
+	if pt.Len() != len(ref) {
+		t.Fatalf("Len() = %d, want %d", pt.Len(), len(ref))
+	}
+	if got, want := pt.String(), string(ref); got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+	lines := strings.Split(string(ref), "\n")
+	if pt.LineCount() != len(lines) {
+		t.Fatalf("LineCount() = %d, want %d", pt.LineCount(), len(lines))
+	}
+	start := 0
+	for i, line := range lines {
+		n := len([]rune(line))
+		if got := pt.LineStart(i); got != start {
+			t.Fatalf("LineStart(%d) = %d, want %d", i, got, start)
+		}
+		if got := pt.LineEnd(i); got != start+n {
+			t.Fatalf("LineEnd(%d) = %d, want %d", i, got, start+n)
+		}
+		if got := pt.Line(i); got != line {
+			t.Fatalf("Line(%d) = %q, want %q", i, got, line)
+		}
+		start += n + 1
+	}
+	line, col := 0, 0
+	for pos, r := range ref {
+		if got := pt.RuneAt(pos); got != r {
+			t.Fatalf("RuneAt(%d) = %q, want %q", pos, got, r)
+		}
+		if l, c := pt.Position(pos); l != line || c != col {
+			t.Fatalf("Position(%d) = %d, %d, want %d, %d", pos, l, c, line, col)
+		}
+		if got := pt.Offset(line, col); got != pos {
+			t.Fatalf("Offset(%d, %d) = %d, want %d", line, col, got, pos)
+		}
+		col++
+		if r == '\n' {
+			line, col = line+1, 0
+		}
+	}
+}
+
+func TestPieceTableMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a", "bc", "\n", "déjà", "日本語", "\n\n", "x\ny", "🙂", "\t"}
+	randomText := func(n int) string {
+		var sb strings.Builder
+		for i := 0; i < n; i++ {
+			sb.WriteString(words[rng.Intn(len(words))])
+		}
+		return sb.String()
+	}
+
+	// Start larger than a piece so the loaded text is split too.
+	initial := randomText(1000)
+	pt := NewPieceTable(initial)
+	ref := reference([]rune(initial))
+	checkAgainst(t, pt, ref)
+
+	for i := 0; i < 2000; i++ {
+		switch n := len(ref); {
+		case rng.Intn(3) > 0 || n == 0:
+			pos := rng.Intn(n + 1)
+			text := randomText(rng.Intn(5) + 1)
+			if rng.Intn(50) == 0 {
+				text = randomText(500)
+			}
+			pt.Insert(pos, text)
+			ref.insert(pos, text)
+		default:
+			start := rng.Intn(n)
+			end := min(n, start+rng.Intn(20)+1)
+			if rng.Intn(50) == 0 {
+				end = min(n, start+rng.Intn(5000))
+			}
+			pt.Delete(start, end)
+			ref.delete(start, end)
+		}
+		if i%250 == 0 {
+			checkAgainst(t, pt, ref)
+		}
+		start := rng.Intn(len(ref) + 1)
+		end := start + rng.Intn(len(ref)-start+1)
+		if got, want := pt.Slice(start, end), string(ref[start:end]); got != want {
+			t.Fatalf("Slice(%d, %d) = %q, want %q", start, end, got, want)
+		}
+	}
+	checkAgainst(t, pt, ref)
+}
+
+func TestPieceTableTyping(t *testing.T) {
+	pt := NewPieceTable("hello\nworld")
+	for i, r := range "abc\ndef" {
+		pt.Insert(5+i, string(r))
+	}
+	if got, want := pt.String(), "helloabc\ndef\nworld"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+	// Text typed in a row extends one piece instead of adding one per key.
+	if pieces := countPieces(pt.root); pieces != 3 {
+		t.Errorf("typing left %d pieces, want 3", pieces)
+	}
+}
+
+func TestPieceTableInvalidUTF8(t *testing.T) {
+	pt := NewPieceTable("a\xffb\xe2\x82")
+	ref := reference([]rune("a\xffb\xe2\x82"))
+	checkAgainst(t, pt, ref)
+	pt.Insert(2, "é")
+	ref.insert(2, "é")
+	checkAgainst(t, pt, ref)
+}
+
+func countPieces(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return countPieces(n.left) + 1 + countPieces(n.right)
+}
+
+// largeText returns about size bytes of source-like lines.
+func largeText(size int) string {
+	line := "\tfmt.Println(\"the quick brown fox jumps over the lazy dog\", i, j)\n"
+	return strings.Repeat(line, size/len(line))
+}
+
+func BenchmarkInsert(b *testing.B) {
+	pt := NewPieceTable(largeText(100 << 20))
+	rng := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pt.Insert(rng.Intn(pt.Len()), "\n")
+	}
+}
+
+func BenchmarkDelete(b *testing.B) {
+	pt := NewPieceTable(largeText(100 << 20))
+	rng := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pos := rng.Intn(pt.Len() - 10)
+		pt.Delete(pos, pos+10)
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	pt := NewPieceTable(largeText(100 << 20))
+	rng := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		line, _ := pt.Position(rng.Intn(pt.Len()))
+		pt.Line(line)
+	}
+}
@@ -0,0 +1,265 @@
+// Package fuzzy ranks strings by how well they match an abbreviation of
+// them, such as "edbuf" for "ui/editor/buffers.go".
+package fuzzy
+
+import (
+	"container/heap"
+	"runtime"
+	"sort"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	scoreMatch       = 16
+	bonusBoundary    = 8
+	bonusSeparator   = 10
+	bonusCamel       = 7
+	bonusConsecutive = 4
+	bonusCase        = 1
+	// bonusBaseName is given when every rune matched is in the last element
+	// of a path.
+	bonusBaseName = 20
+	penaltyGap    = 1
+	penaltyStart  = 3
+)
+
+// Match reports whether every rune of pattern appears in candidate in
+// order, ignoring case, and how well. A higher score is a better match.
+// Matches at the start of words and runs of matched runes score higher.
+func Match(pattern, candidate string) (score int, ok bool) {
+	return match([]rune(pattern), candidate, nil)
+}
+
+// Positions returns the runes of candidate that pattern matches, as rune
+// indices, or nil if it doesn't match.
+func Positions(pattern, candidate string) []int {
+	p := []rune(pattern)
+	pos := make([]int, len(p))
+	if _, ok := match(p, candidate, pos); !ok {
+		return nil
+	}
+	return pos
+}
+
+// match scores candidate against pattern, filling positions with where the
+// runes matched if it isn't nil.
+func match(pattern []rune, candidate string, positions []int) (int, bool) {
+	if len(pattern) == 0 {
+		return 0, true
+	}
+	// Most candidates don't match at all, which is found out without
+	// decoding them into runes.
+	i := 0
+	for _, r := range candidate {
+		if equalFold(pattern[i], r) {
+			i++
+			if i == len(pattern) {
+				break
+			}
+		}
+	}
+	if i < len(pattern) {
+		return 0, false
+	}
+	c := []rune(candidate)
+	score, _ := align(pattern, c, 0, positions)
+	// A match within the last element of a path is usually the one meant,
+	// even if an earlier one scores better on its own.
+	base := len(c) - 1
+	for base >= 0 && c[base] != '/' && c[base] != '\\' {
+		base--
+	}
+	var inBase []int
+	if positions != nil {
+		inBase = make([]int, len(pattern))
+	}
+	if s, ok := align(pattern, c, base+1, inBase); ok && s+bonusBaseName > score {
+		score = s + bonusBaseName
+		copy(positions, inBase)
+	}
+	return score, true
+}
+
+// align scores the shortest stretch of c after from that holds a match of
+// pattern.
+func align(pattern, c []rune, from int, positions []int) (int, bool) {
+	// The earliest match ends the stretch; going back from there finds its
+	// start.
+	end, i := from, 0
+	for ; end < len(c) && i < len(pattern); end++ {
+		if equalFold(pattern[i], c[end]) {
+			i++
+		}
+	}
+	if i < len(pattern) {
+		return 0, false
+	}
+	start := end - 1
+	for i := len(pattern) - 1; i >= 0; start-- {
+		if equalFold(pattern[i], c[start]) {
+			i--
+		}
+	}
+	start++
+
+	score, prev := 0, -1
+	i = 0
+	for j := start; j < end && i < len(pattern); j++ {
+		if !equalFold(pattern[i], c[j]) {
+			continue
+		}
+		score += scoreMatch
+		if pattern[i] == c[j] {
+			score += bonusCase
+		}
+		if j == prev+1 {
+			score += bonusConsecutive
+		} else if prev >= 0 {
+			score -= penaltyGap * min(j-prev-1, 8)
+		}
+		score += boundary(c, j)
+		if positions != nil {
+			positions[i] = j
+		}
+		prev = j
+		i++
+	}
+	score -= penaltyStart * min(start-from, 4)
+	return score, true
+}
+
+// boundary returns the bonus for matching the rune at j, which is higher at
+// the start of a word.
+func boundary(c []rune, j int) int {
+	if j == 0 {
+		return bonusBoundary
+	}
+	prev, r := c[j-1], c[j]
+	switch {
+	case prev == '/' || prev == '\\':
+		return bonusSeparator
+	case prev == '_' || prev == '-' || prev == '.' || prev == ' ':
+		return bonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(r):
+		return bonusCamel
+	case !unicode.IsDigit(prev) && unicode.IsDigit(r):
+		return bonusCamel
+	}
+	return 0
+}
+
+func equalFold(p, r rune) bool {
+	if p == r {
+		return true
+	}
+	if p < utf8.RuneSelf && r < utf8.RuneSelf {
+		if 'A' <= p && p <= 'Z' {
+			p += 'a' - 'A'
+		}
+		if 'A' <= r && r <= 'Z' {
+			r += 'a' - 'A'
+		}
+		return p == r
+	}
+	return unicode.ToLower(p) == unicode.ToLower(r)
+}
+
+// Result is a candidate that matched.
+type Result struct {
+	// Index is the position of the candidate in the slice given to Rank.
+	Index int
+	Score int
+	// Positions are the runes of the candidate that matched.
+	Positions []int
+}
+
+// Rank returns the best limit candidates matching pattern, best first. bonus,
+// if not nil, is added to the score of each candidate that matches, to favour
+// some of them. With an empty pattern every candidate matches and only the
+// bonus orders them. The candidates are scored on all CPUs, so that lists of
+// hundreds of thousands stay quick to search.
+func Rank(pattern string, candidates []string, limit int, bonus func(i int) int) []Result {
+	p := []rune(pattern)
+	workers := runtime.NumCPU()
+	chunk := max(1024, (len(candidates)+workers-1)/workers)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var best results
+	for from := 0; from < len(candidates); from += chunk {
+		to := min(from+chunk, len(candidates))
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			var top results
+			for i := from; i < to; i++ {
+				score, ok := match(p, candidates[i], nil)
+				if !ok {
+					continue
+				}
+				if bonus != nil {
+					score += bonus(i)
+				}
+				top.add(Result{Index: i, Score: score}, candidates, limit)
+			}
+			mu.Lock()
+			for _, r := range top.list {
+				best.add(r, candidates, limit)
+			}
+			mu.Unlock()
+		}(from, to)
+	}
+	wg.Wait()
+
+	sort.Slice(best.list, func(i, j int) bool {
+		return best.better(best.list[i], best.list[j], candidates)
+	})
+	for k := range best.list {
+		r := &best.list[k]
+		if len(p) > 0 {
+			r.Positions = make([]int, len(p))
+			match(p, candidates[r.Index], r.Positions)
+		}
+	}
+	return best.list
+}
+
+// results keeps the best results seen, with the worst of them on top of a
+// heap so that it is the one pushed out.
+type results struct {
+	list       []Result
+	candidates []string
+}
+
+func (h *results) add(r Result, candidates []string, limit int) {
+	h.candidates = candidates
+	if len(h.list) < limit {
+		heap.Push(h, r)
+	} else if limit > 0 && h.better(r, h.list[0], candidates) {
+		h.list[0] = r
+		heap.Fix(h, 0)
+	}
+}
+
+// better orders by score, then prefers shorter candidates and then the
+// order they were given in.
+func (h *results) better(a, b Result, candidates []string) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	if la, lb := len(candidates[a.Index]), len(candidates[b.Index]); la != lb {
+		return la < lb
+	}
+	return a.Index < b.Index
+}
+
+func (h *results) Len() int           { return len(h.list) }
+func (h *results) Less(i, j int) bool { return h.better(h.list[j], h.list[i], h.candidates) }
+func (h *results) Swap(i, j int)      { h.list[i], h.list[j] = h.list[j], h.list[i] }
+func (h *results) Push(x any)         { h.list = append(h.list, x.(Result)) }
+func (h *results) Pop() any {
+	r := h.list[len(h.list)-1]
+	h.list = h.list[:len(h.list)-1]
+	return r
+}
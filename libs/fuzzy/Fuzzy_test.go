@@ -0,0 +1,110 @@
+package fuzzy
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, candidate string
+		ok                 bool
+	}{
+		{"", "anything", true},
+		{"edbuf", "ui/editor/buffers.go", true},
+		{"EDBUF", "ui/editor/buffers.go", true},
+		{"fbe", "ui/editor/buffers.go", false},
+		{"ümlaut", "ÜMLAUT.txt", true},
+		{"abc", "ab", false},
+	}
+	for _, tt := range tests {
+		if _, ok := Match(tt.pattern, tt.candidate); ok != tt.ok {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.candidate, ok, tt.ok)
+		}
+	}
+}
+
+func TestMatchPrefersBetterMatches(t *testing.T) {
+	tests := []struct {
+		pattern, better, worse string
+	}{
+		// Word starts beat runes in the middle of words.
+		{"fb", "foo_bar", "xfxbx"},
+		{"fb", "fooBar", "foobar"},
+		// Consecutive runes beat scattered ones.
+		{"main", "main.go", "mxaxixn.go"},
+		// Matches in the file name beat those in directories.
+		{"edit", "src/editor.go", "editor/src/main.go"},
+		// Matching case is a tie breaker.
+		{"Go", "Go.go", "go.go"},
+	}
+	for _, tt := range tests {
+		b, _ := Match(tt.pattern, tt.better)
+		w, _ := Match(tt.pattern, tt.worse)
+		if b <= w {
+			t.Errorf("%q: %q scores %d, not more than %q with %d", tt.pattern, tt.better, b, tt.worse, w)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	tests := []struct {
+		pattern, candidate string
+		want               []int
+	}{
+		{"edbuf", "ui/editor/buffers.go", []int{3, 4, 10, 11, 12}},
+		// The match in the last path element is the one shown.
+		{"main", "main/cmd/main.go", []int{9, 10, 11, 12}},
+		{"xyz", "abc", nil},
+	}
+	for _, tt := range tests {
+		if got := Positions(tt.pattern, tt.candidate); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Positions(%q, %q) = %v, want %v", tt.pattern, tt.candidate, got, tt.want)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	candidates := []string{
+		"docs/readme.md",
+		"ui/editor/editor.go",
+		"ui/editor/buffers.go",
+		"libs/buffer/Buffer.go",
+		"main.go",
+	}
+	got := Rank("buf", candidates, 2, nil)
+	if len(got) != 2 {
+		t.Fatalf("Rank returned %d results, want 2", len(got))
+	}
+	// Both match at the start of the file name; the case decides.
+	if got[0].Index != 2 || got[1].Index != 3 {
+		t.Errorf("Rank order = %d, %d, want 2, 3", got[0].Index, got[1].Index)
+	}
+	if !reflect.DeepEqual(got[0].Positions, []int{10, 11, 12}) {
+		t.Errorf("Positions = %v, want [10 11 12]", got[0].Positions)
+	}
+
+	// With no pattern only the bonus orders the candidates.
+	recent := func(i int) int { return i }
+	got = Rank("", candidates, 10, recent)
+	if len(got) != len(candidates) || got[0].Index != 4 || got[0].Positions != nil {
+		t.Errorf("Rank with an empty pattern = %+v, want every candidate, the one with the biggest bonus first", got)
+	}
+}
+
+func TestRankMany(t *testing.T) {
+	// Enough candidates to be split among workers.
+	candidates := make([]string, 20000)
+	for i := range candidates {
+		candidates[i] = fmt.Sprintf("dir%d/file%d.txt", i%100, i)
+	}
+	candidates[12345] = "target.go"
+	got := Rank("target", candidates, 5, nil)
+	if len(got) != 1 || got[0].Index != 12345 {
+		t.Errorf("Rank = %+v, want only the target", got)
+	}
+	if got := Rank("file", candidates, 0, nil); len(got) != 0 {
+		t.Errorf("Rank with limit 0 returned %d results", len(got))
+	}
+}
@@ -0,0 +1,261 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/vypal/vedit/libs"
+)
+
+var (
+	ErrBinary   = errors.New("binary file")
+	ErrTooLarge = errors.New("file too large")
+)
+
+// maxFileSize is the size above which files are not searched.
+const maxFileSize = 8 << 20
+
+// maxPreview is the most of a line a Hit keeps, in runes.
+const maxPreview = 200
+
+// Hit is a match in a file, along with the line it starts on.
+type Hit struct {
+	// Start and End are the offsets of the match in the file, in runes.
+	Start, End int
+	// Line is the zero-based line the match starts on, and Text that line
+	// or, for long lines, the part of it around the match.
+	Line int
+	Text string
+	// Col and EndCol are where the match starts and ends within Text, in
+	// runes. A match that goes on to the next line ends at the end of Text.
+	Col, EndCol int
+}
+
+type FileResult struct {
+	Path string
+	Hits []Hit
+}
+
+// ReadText reads the file at path as text. Files that look binary or are too
+// large to search sensibly give ErrBinary or ErrTooLarge.
+func ReadText(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > maxFileSize {
+		return "", ErrTooLarge
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 || !utf8.Valid(data) {
+		return "", ErrBinary
+	}
+	return string(data), nil
+}
+
+// SearchFiles looks for q in the files below the directory root with the
+// given number of workers, leaving out what opts leaves out and binary
+// files. found is called for every file with matches as soon as it has been
+// searched, from several goroutines at once. SearchFiles returns when every
+// file is done or ctx is cancelled.
+func SearchFiles(ctx context.Context, root string, opts libs.ScanOptions, q *Query, workers int, found func(FileResult)) error {
+	dir, err := libs.OpenDirectory(root)
+	if err != nil {
+		return err
+	}
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < max(1, workers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if ctx.Err() != nil {
+					continue
+				}
+				text, err := ReadText(path)
+				if err != nil {
+					continue
+				}
+				if hits := q.Hits(text); len(hits) > 0 {
+					found(FileResult{Path: path, Hits: hits})
+				}
+			}
+		}()
+	}
+	err = dir.Walk(opts, func(f *libs.File) error {
+		select {
+		case paths <- f.FullPath():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(paths)
+	wg.Wait()
+	return err
+}
+
+// lines holds where each line of a text starts, in runes.
+type lines struct {
+	runes  []rune
+	starts []int
+}
+
+func splitLines(text string) lines {
+	l := lines{runes: []rune(text), starts: []int{0}}
+	for i, r := range l.runes {
+		if r == '\n' {
+			l.starts = append(l.starts, i+1)
+		}
+	}
+	return l
+}
+
+// lineOf returns the line containing offset pos.
+func (l lines) lineOf(pos int) int {
+	lo, hi := 0, len(l.starts)
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if l.starts[mid] <= pos {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// end returns the offset of the newline ending line n, or the length of
+// the text for the last line.
+func (l lines) end(n int) int {
+	if n+1 < len(l.starts) {
+		return l.starts[n+1] - 1
+	}
+	return len(l.runes)
+}
+
+// Hits returns the matches of q in text with the lines they are on.
+func (q *Query) Hits(text string) []Hit {
+	matches := q.FindAll(text)
+	if len(matches) == 0 {
+		return nil
+	}
+	l := splitLines(text)
+	hits := make([]Hit, 0, len(matches))
+	for _, m := range matches {
+		n := l.lineOf(m.Start)
+		start, end := l.starts[n], l.end(n)
+		line := l.runes[start:end]
+		col, endCol := m.Start-start, min(m.End, end)-start
+		if len(line) > maxPreview {
+			// Keep some context before the match.
+			from := max(0, min(col-maxPreview/4, len(line)-maxPreview))
+			line = line[from : from+maxPreview]
+			col, endCol = col-from, min(endCol-from, maxPreview)
+		}
+		hits = append(hits, Hit{
+			Start: m.Start, End: m.End,
+			Line: n, Text: string(line),
+			Col: col, EndCol: endCol,
+		})
+	}
+	return hits
+}
+
+// Change is what replacing every match in a file would do, kept to be
+// reviewed before it is written.
+type Change struct {
+	Path  string
+	Count int
+	Hunks []Hunk
+	old   string
+	new   string
+}
+
+// Hunk is a run of lines that a change replaces.
+type Hunk struct {
+	// Line is the zero-based line of the first old line.
+	Line int
+	Old  []string
+	New  []string
+}
+
+// Replace works out the change of replacing every match of q in text, the
+// contents of the file at path, with replacement.
+func (q *Query) Replace(path, text, replacement string) Change {
+	c := Change{Path: path, old: text}
+	matches := q.FindAll(text)
+	c.Count = len(matches)
+	if c.Count == 0 {
+		c.new = text
+		return c
+	}
+	l := splitLines(text)
+	var out strings.Builder
+	pos := 0
+	for i := 0; i < len(matches); {
+		// Matches on the same or neighbouring lines make up one hunk.
+		first := l.lineOf(matches[i].Start)
+		last := l.lineOf(matches[i].End)
+		j := i + 1
+		for j < len(matches) && l.lineOf(matches[j].Start) <= last+1 {
+			last = max(last, l.lineOf(matches[j].End))
+			j++
+		}
+		from, to := l.starts[first], l.end(last)
+
+		out.WriteString(string(l.runes[pos:from]))
+		var hunk strings.Builder
+		at := from
+		for _, m := range matches[i:j] {
+			hunk.WriteString(string(l.runes[at:m.Start]))
+			hunk.WriteString(q.Expand(replacement, text, m))
+			at = m.End
+		}
+		hunk.WriteString(string(l.runes[at:to]))
+		out.WriteString(hunk.String())
+		pos = to
+
+		old := make([]string, 0, last-first+1)
+		for n := first; n <= last; n++ {
+			old = append(old, string(l.runes[l.starts[n]:l.end(n)]))
+		}
+		c.Hunks = append(c.Hunks, Hunk{Line: first, Old: old, New: strings.Split(hunk.String(), "\n")})
+		i = j
+	}
+	out.WriteString(string(l.runes[pos:]))
+	c.new = out.String()
+	return c
+}
+
+// Text returns the contents of the file after the change.
+func (c *Change) Text() string {
+	return c.new
+}
+
+// Write saves the changed file. It refuses to if the file was modified since
+// the change was worked out.
+func (c *Change) Write() error {
+	current, err := os.ReadFile(c.Path)
+	if err != nil {
+		return err
+	}
+	if string(current) != c.old {
+		return errors.New(c.Path + " changed since it was searched")
+	}
+	f, err := libs.NewFileFromPath(c.Path)
+	if err != nil {
+		return err
+	}
+	f.Contents = []byte(c.new)
+	return f.Save()
+}
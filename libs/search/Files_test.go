@@ -0,0 +1,137 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vypal/vedit/libs"
+)
+
+func TestHits(t *testing.T) {
+	q, _ := Compile("needle", Options{})
+	text := "one\ntwo needle\nthree\nneedle needle"
+	hits := q.Hits(text)
+	want := []Hit{
+		{Start: 8, End: 14, Line: 1, Text: "two needle", Col: 4, EndCol: 10},
+		{Start: 21, End: 27, Line: 3, Text: "needle needle", Col: 0, EndCol: 6},
+		{Start: 28, End: 34, Line: 3, Text: "needle needle", Col: 7, EndCol: 13},
+	}
+	if len(hits) != len(want) {
+		t.Fatalf("got %d hits, want %d", len(hits), len(want))
+	}
+	for i := range want {
+		if hits[i] != want[i] {
+			t.Errorf("hit %d = %+v, want %+v", i, hits[i], want[i])
+		}
+	}
+}
+
+func TestHitsLongLine(t *testing.T) {
+	q, _ := Compile("needle", Options{})
+	line := strings.Repeat("x", 1000) + "needle" + strings.Repeat("y", 1000)
+	h := q.Hits(line)[0]
+	if n := len([]rune(h.Text)); n != maxPreview {
+		t.Errorf("preview is %d runes, want %d", n, maxPreview)
+	}
+	if got := string([]rune(h.Text)[h.Col:h.EndCol]); got != "needle" {
+		t.Errorf("preview at Col:EndCol is %q, want the match", got)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	q, _ := Compile(`(\w+)@old`, Options{Regexp: true})
+	text := "a@old b\nkeep\nkeep\nc@old\nd@old\n"
+	c := q.Replace("f", text, "$1@new")
+	if c.Count != 3 {
+		t.Errorf("Count = %d, want 3", c.Count)
+	}
+	if got, want := c.Text(), "a@new b\nkeep\nkeep\nc@new\nd@new\n"; got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+	// Matches on neighbouring lines share a hunk.
+	want := []Hunk{
+		{Line: 0, Old: []string{"a@old b"}, New: []string{"a@new b"}},
+		{Line: 3, Old: []string{"c@old", "d@old"}, New: []string{"c@new", "d@new"}},
+	}
+	if len(c.Hunks) != len(want) {
+		t.Fatalf("got %d hunks, want %d: %+v", len(c.Hunks), len(want), c.Hunks)
+	}
+	for i, h := range c.Hunks {
+		if h.Line != want[i].Line || strings.Join(h.Old, "|") != strings.Join(want[i].Old, "|") ||
+			strings.Join(h.New, "|") != strings.Join(want[i].New, "|") {
+			t.Errorf("hunk %d = %+v, want %+v", i, h, want[i])
+		}
+	}
+}
+
+func TestChangeWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	write := func(text string) {
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("old text")
+	q, _ := Compile("old", Options{})
+	c := q.Replace(path, "old text", "new")
+	if err := c.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "new text" {
+		t.Errorf("file = %q, want %q", got, "new text")
+	}
+
+	// A file changed since it was searched is left alone.
+	c = q.Replace(path, "old text", "new")
+	if err := c.Write(); err == nil {
+		t.Error("Write overwrote a file that changed since it was searched")
+	}
+}
+
+func TestSearchFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"a.go":          "package a // TODO",
+		"sub/b.txt":     "nothing here",
+		"sub/c.md":      "TODO one\nTODO two",
+		"bin.dat":       "TODO\x00\x01",
+		"ignored/d.txt": "TODO",
+		".gitignore":    "ignored/\n",
+	}
+	for name, text := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q, _ := Compile("TODO", Options{CaseSensitive: true})
+	var mu sync.Mutex
+	found := map[string]int{}
+	err := SearchFiles(context.Background(), root, libs.ScanOptions{UseGitIgnore: true}, q, 4, func(r FileResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		rel, _ := filepath.Rel(root, r.Path)
+		found[filepath.ToSlash(rel)] = len(r.Hits)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for name, n := range found {
+		got = append(got, fmt.Sprintf("%s:%d", name, n))
+	}
+	sort.Strings(got)
+	if strings.Join(got, " ") != "a.go:1 sub/c.md:2" {
+		t.Errorf("found %v, want a.go:1 sub/c.md:2", got)
+	}
+}
@@ -0,0 +1,97 @@
+// Package search finds text by plain string or regular expression.
+package search
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Options struct {
+	CaseSensitive bool
+	// WholeWord only matches text that isn't part of a longer word.
+	WholeWord bool
+	// Regexp treats the pattern as a regular expression in Go's syntax.
+	// Otherwise it is matched literally.
+	Regexp bool
+}
+
+// Match is a range of text found by a Query, in runes.
+type Match struct {
+	Start, End int
+	// groups holds the byte offsets of the submatches, for Expand.
+	groups []int
+}
+
+type Query struct {
+	Pattern string
+	Options Options
+	re      *regexp.Regexp
+}
+
+// Compile prepares pattern for searching. It fails only for invalid regular
+// expressions.
+func Compile(pattern string, opts Options) (*Query, error) {
+	expr := pattern
+	if !opts.Regexp {
+		expr = regexp.QuoteMeta(pattern)
+	}
+	// ^ and $ match at line breaks, as they would searching line by line.
+	flags := "(?m)"
+	if !opts.CaseSensitive {
+		flags = "(?mi)"
+	}
+	re, err := regexp.Compile(flags + expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{Pattern: pattern, Options: opts, re: re}, nil
+}
+
+// FindAll returns the matches in text in order. Empty matches are left out,
+// since there is nothing to show or replace.
+func (q *Query) FindAll(text string) []Match {
+	var matches []Match
+	// Byte offsets are turned into rune offsets as the matches go by.
+	bytePos, runePos := 0, 0
+	runeAt := func(b int) int {
+		runePos += utf8.RuneCountInString(text[bytePos:b])
+		bytePos = b
+		return runePos
+	}
+	for _, loc := range q.re.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		if q.Options.WholeWord && !isWholeWord(text, loc[0], loc[1]) {
+			continue
+		}
+		start := runeAt(loc[0])
+		end := runeAt(loc[1])
+		matches = append(matches, Match{Start: start, End: end, groups: loc})
+	}
+	return matches
+}
+
+// Expand returns what m should be replaced with. In regular expression
+// queries $1, ${1} and ${name} in replacement stand for the submatches of m;
+// otherwise replacement is used as it is. text must be what m was found in.
+func (q *Query) Expand(replacement, text string, m Match) string {
+	if !q.Options.Regexp {
+		return replacement
+	}
+	return string(q.re.ExpandString(nil, replacement, text, m.groups))
+}
+
+// isWholeWord reports whether the text from start to end isn't preceded or
+// followed by a word character.
+func isWholeWord(text string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	return !(start > 0 && isWordChar(before)) && !(end < len(text) && isWordChar(after))
+}
+
+// isWordChar reports whether r can be part of a word.
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
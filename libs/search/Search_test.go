@@ -0,0 +1,65 @@
+package search
+
+import "testing"
+
+func TestFindAll(t *testing.T) {
+	tests := []struct {
+		pattern string
+		opts    Options
+		text    string
+		want    []Match
+	}{
+		{"foo", Options{}, "Foo foo FOO", []Match{{Start: 0, End: 3}, {Start: 4, End: 7}, {Start: 8, End: 11}}},
+		{"foo", Options{CaseSensitive: true}, "Foo foo FOO", []Match{{Start: 4, End: 7}}},
+		{"a.c", Options{}, "abc a.c", []Match{{Start: 4, End: 7}}},
+		{"a.c", Options{Regexp: true}, "abc a.c", []Match{{Start: 0, End: 3}, {Start: 4, End: 7}}},
+		{"cat", Options{WholeWord: true}, "cat concat cats cat_ (cat)", []Match{{Start: 0, End: 3}, {Start: 22, End: 25}}},
+		// Offsets are in runes.
+		{"é", Options{}, "café é", []Match{{Start: 3, End: 4}, {Start: 5, End: 6}}},
+		{"^x", Options{Regexp: true}, "x\nax\nx", []Match{{Start: 0, End: 1}, {Start: 5, End: 6}}},
+		// Empty matches are left out.
+		{"a*", Options{Regexp: true}, "baab", []Match{{Start: 1, End: 3}}},
+		{"", Options{}, "text", nil},
+	}
+	for _, tt := range tests {
+		q, err := Compile(tt.pattern, tt.opts)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tt.pattern, err)
+		}
+		got := q.FindAll(tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q %+v in %q: got %d matches, want %d", tt.pattern, tt.opts, tt.text, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i].Start != tt.want[i].Start || got[i].End != tt.want[i].End {
+				t.Errorf("%q %+v in %q: match %d is %d-%d, want %d-%d", tt.pattern, tt.opts, tt.text,
+					i, got[i].Start, got[i].End, tt.want[i].Start, tt.want[i].End)
+			}
+		}
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	if _, err := Compile("(", Options{Regexp: true}); err == nil {
+		t.Error("an invalid regular expression compiled")
+	}
+	if _, err := Compile("(", Options{}); err != nil {
+		t.Errorf("a literal pattern failed: %v", err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	text := "name = value"
+	re, _ := Compile(`(\w+) = (?P<v>\w+)`, Options{Regexp: true})
+	m := re.FindAll(text)[0]
+	if got := re.Expand("$2: ${1} (${v})", text, m); got != "value: name (value)" {
+		t.Errorf("Expand = %q", got)
+	}
+
+	lit, _ := Compile("name", Options{})
+	m = lit.FindAll(text)[0]
+	if got := lit.Expand("$1", text, m); got != "$1" {
+		t.Errorf("literal Expand = %q, want the replacement as it is", got)
+	}
+}
@@ -0,0 +1,225 @@
+// Package settings reads the user's settings file and the per-project
+// overrides, and notices when either changes.
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vypal/vedit/libs"
+)
+
+// Settings are the user's preferences. The zero value of a field is never
+// meaningful on its own; use Default for the built-in values.
+type Settings struct {
+	// FontSize is the size of the editor's text in Sp.
+	FontSize float32
+	// LineHeight is the distance between lines in Sp. Zero picks a height
+	// that suits the font size.
+	LineHeight float32
+	// TabWidth is the number of columns a tab is shown as.
+	TabWidth int
+	// GutterPadding is the room between the line numbers and the text, in Dp.
+	GutterPadding float32
+	// Theme is the path of a color theme, or empty for the default one.
+	Theme string
+	// ShowHidden shows dotfiles in the file tree.
+	ShowHidden bool
+	// Keybindings picks how keys edit text: "default", "vim" for Vim's
+	// modes on top of the default keys, or "emacs" for Emacs's keys.
+	Keybindings string
+	// Backup keeps the previous version of a file as "name~" when saving.
+	Backup bool
+}
+
+func Default() Settings {
+	return Settings{
+		FontSize:      22,
+		TabWidth:      4,
+		GutterPadding: 20,
+	}
+}
+
+// LineHeightSp returns the line height, working it out from the font size
+// when none was set.
+func (s Settings) LineHeightSp() float32 {
+	if s.LineHeight > 0 {
+		return s.LineHeight
+	}
+	return float32(math.Round(float64(s.FontSize) * 1.2))
+}
+
+// file is one settings file, written as JSON (comments are allowed) or
+// TOML. Fields left out keep the value from the files read before it.
+type file struct {
+	FontSize      *float32 `json:"fontSize" toml:"fontSize"`
+	LineHeight    *float32 `json:"lineHeight" toml:"lineHeight"`
+	TabWidth      *int     `json:"tabWidth" toml:"tabWidth"`
+	GutterPadding *float32 `json:"gutterPadding" toml:"gutterPadding"`
+	Theme         *string  `json:"theme" toml:"theme"`
+	ShowHidden    *bool    `json:"showHidden" toml:"showHidden"`
+	Keybindings   *string  `json:"keybindings" toml:"keybindings"`
+	Backup        *bool    `json:"backup" toml:"backup"`
+}
+
+// UserDir is the directory of the user's settings, ~/.config/vedit on Linux.
+func UserDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "vedit")
+}
+
+// ProjectDir is the directory of the settings of the project at root.
+func ProjectDir(root string) string {
+	return filepath.Join(root, ".vedit")
+}
+
+// KeymapPath is the user's keymap file, or "" when there is no settings
+// directory.
+func KeymapPath() string {
+	dir := UserDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "keymap.json")
+}
+
+// Paths lists the files settings may be read from, in the order they apply:
+// the user's settings, then those of the project at root. root may be empty.
+// The files don't have to exist.
+func Paths(root string) []string {
+	var dirs []string
+	if dir := UserDir(); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if root != "" {
+		dirs = append(dirs, ProjectDir(root))
+	}
+	var paths []string
+	for _, dir := range dirs {
+		paths = append(paths, filepath.Join(dir, "settings.json"), filepath.Join(dir, "settings.toml"))
+	}
+	return paths
+}
+
+// ParseError is a settings file that couldn't be read, such as one with a
+// syntax error or an unknown key. Nothing in the file is applied.
+type ParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Load reads the settings for the project at root on top of the defaults.
+// Values that are invalid are reported in the error and skipped, so the
+// returned settings are always usable. A file that can't be parsed at all
+// is skipped as a whole and reported as a *ParseError.
+func Load(root string) (Settings, error) {
+	s := Default()
+	var errs []error
+	for _, path := range Paths(root) {
+		if err := s.merge(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	if s.LineHeight != 0 && s.LineHeight < s.FontSize {
+		errs = append(errs, fmt.Errorf("lineHeight %v is smaller than fontSize %v", s.LineHeight, s.FontSize))
+		s.LineHeight = 0
+	}
+	return s, errors.Join(errs...)
+}
+
+// merge applies the settings file at path.
+func (s *Settings) merge(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f file
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = decodeTOML(data, &f)
+	} else {
+		err = decodeJSON(data, &f)
+	}
+	if err != nil {
+		return &ParseError{Path: path, Err: err}
+	}
+
+	var errs []error
+	check := func(name string, v, lo, hi float64) bool {
+		if v < lo || v > hi {
+			errs = append(errs, fmt.Errorf("%s: %s must be between %v and %v, not %v", path, name, lo, hi, v))
+			return false
+		}
+		return true
+	}
+	if f.FontSize != nil && check("fontSize", float64(*f.FontSize), 6, 96) {
+		s.FontSize = *f.FontSize
+	}
+	if f.LineHeight != nil && (*f.LineHeight == 0 || check("lineHeight", float64(*f.LineHeight), 6, 200)) {
+		s.LineHeight = *f.LineHeight
+	}
+	if f.TabWidth != nil && check("tabWidth", float64(*f.TabWidth), 1, 16) {
+		s.TabWidth = *f.TabWidth
+	}
+	if f.GutterPadding != nil && check("gutterPadding", float64(*f.GutterPadding), 0, 200) {
+		s.GutterPadding = *f.GutterPadding
+	}
+	if f.Theme != nil {
+		s.Theme = *f.Theme
+		if s.Theme != "" && !filepath.IsAbs(s.Theme) {
+			// Relative to the settings file, so projects can ship a theme.
+			s.Theme = filepath.Join(filepath.Dir(path), s.Theme)
+		}
+	}
+	if f.ShowHidden != nil {
+		s.ShowHidden = *f.ShowHidden
+	}
+	if f.Keybindings != nil {
+		switch k := *f.Keybindings; k {
+		case "", "default":
+			s.Keybindings = ""
+		case "vim", "emacs":
+			s.Keybindings = k
+		default:
+			errs = append(errs, fmt.Errorf("%s: keybindings must be \"default\", \"vim\" or \"emacs\", not %q", path, k))
+		}
+	}
+	if f.Backup != nil {
+		s.Backup = *f.Backup
+	}
+	return errors.Join(errs...)
+}
+
+func decodeJSON(data []byte, f *file) error {
+	dec := json.NewDecoder(bytes.NewReader(libs.StripJSONC(data)))
+	dec.DisallowUnknownFields()
+	return dec.Decode(f)
+}
+
+func decodeTOML(data []byte, f *file) error {
+	md, err := toml.Decode(string(data), f)
+	if err != nil {
+		return err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("unknown key %q", undecoded[0].String())
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package settings
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setup points the user's settings at a temporary directory and returns it
+// along with a project root.
+func setup(t *testing.T) (user, root string) {
+	t.Helper()
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("HOME", config)
+	user = UserDir()
+	root = t.TempDir()
+	for _, dir := range []string{user, ProjectDir(root)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return user, root
+}
+
+func write(t *testing.T, path, text string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	_, root := setup(t)
+	s, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != Default() {
+		t.Errorf("Load without files = %+v, want the defaults", s)
+	}
+	if got := s.LineHeightSp(); got != 26 {
+		t.Errorf("LineHeightSp() = %v, want 26 for font size 22", got)
+	}
+}
+
+func TestLoadProjectOverridesUser(t *testing.T) {
+	user, root := setup(t)
+	write(t, filepath.Join(user, "settings.json"), `{
+		// Comments and trailing commas are fine.
+		"fontSize": 14,
+		"tabWidth": 8,
+		"theme": "themes/dark.json",
+		"backup": true,
+	}`)
+	write(t, filepath.Join(ProjectDir(root), "settings.toml"), `
+tabWidth = 2
+keybindings = "vim"
+`)
+	s, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.FontSize = 14
+	want.TabWidth = 2
+	want.Theme = filepath.Join(user, "themes", "dark.json")
+	want.Keybindings = "vim"
+	want.Backup = true
+	if s != want {
+		t.Errorf("Load = %+v, want %+v", s, want)
+	}
+}
+
+func TestLoadSkipsInvalidValues(t *testing.T) {
+	user, root := setup(t)
+	write(t, filepath.Join(user, "settings.json"), `{"fontSize": 2, "tabWidth": 3, "keybindings": "nano"}`)
+	write(t, filepath.Join(ProjectDir(root), "settings.json"), `{"lineHeight": 10, "fontSize": 30}`)
+	s, err := Load(root)
+	if err == nil {
+		t.Fatal("invalid values weren't reported")
+	}
+	for _, want := range []string{"fontSize must be between", "keybindings must be", "lineHeight 10 is smaller"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+	want := Default()
+	want.TabWidth = 3
+	want.FontSize = 30
+	if s != want {
+		t.Errorf("Load = %+v, want %+v", s, want)
+	}
+}
+
+func TestLoadUnknownKey(t *testing.T) {
+	user, root := setup(t)
+	write(t, filepath.Join(user, "settings.toml"), "fontColor = 12\n")
+	write(t, filepath.Join(ProjectDir(root), "settings.json"), `{"tabSize": 2}`)
+	s, err := Load(root)
+	if err == nil || !strings.Contains(err.Error(), "fontColor") || !strings.Contains(err.Error(), "tabSize") {
+		t.Errorf("Load error = %v, want both unknown keys reported", err)
+	}
+	if s != Default() {
+		t.Errorf("Load = %+v, want the defaults", s)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.json")
+	w := NewWatcher([]string{path})
+	if w.Changed() {
+		t.Error("nothing changed yet")
+	}
+	write(t, path, "{}")
+	if !w.Changed() {
+		t.Error("creating the file wasn't noticed")
+	}
+	if w.Changed() {
+		t.Error("the same change was reported twice")
+	}
+	// Same size, so only the modification time tells.
+	write(t, path, "[]")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if !w.Changed() {
+		t.Error("saving the file wasn't noticed")
+	}
+	os.Remove(path)
+	if !w.Changed() {
+		t.Error("removing the file wasn't noticed")
+	}
+}
+
+func TestLoadParseError(t *testing.T) {
+	user, root := setup(t)
+	write(t, filepath.Join(user, "settings.json"), `{"fontSize": 20, "keybindings": "vim",`)
+	write(t, filepath.Join(ProjectDir(root), "settings.toml"), "tabWidth = 8\n")
+	s, err := Load(root)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Path != filepath.Join(user, "settings.json") {
+		t.Fatalf("Load error = %v, want a ParseError for the user's settings", err)
+	}
+	want := Default()
+	want.TabWidth = 8
+	if s != want {
+		t.Errorf("Load = %+v, want %+v", s, want)
+	}
+}
@@ -0,0 +1,70 @@
+package settings
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Watcher notices when settings files are created, saved or removed. It
+// polls, which is cheap for a handful of files and works on every platform.
+// It is safe to use from several goroutines.
+type Watcher struct {
+	mu     sync.Mutex
+	stamps map[string]stamp
+}
+
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher returns a watcher of the files at paths as they are now.
+func NewWatcher(paths []string) *Watcher {
+	w := &Watcher{}
+	w.Watch(paths)
+	return w
+}
+
+// Watch replaces the watched files with those at paths.
+func (w *Watcher) Watch(paths []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stamps = make(map[string]stamp, len(paths))
+	for _, path := range paths {
+		w.stamps[path] = stampOf(path)
+	}
+}
+
+// Changed reports whether any of the files changed since the last call.
+func (w *Watcher) Changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := false
+	for path, old := range w.stamps {
+		if s := stampOf(path); s != old {
+			w.stamps[path] = s
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Run calls onChange whenever a file changed, checking every interval. It
+// never returns.
+func (w *Watcher) Run(interval time.Duration, onChange func()) {
+	for range time.Tick(interval) {
+		if w.Changed() {
+			onChange()
+		}
+	}
+}
+
+// stampOf returns the zero stamp for files that don't exist.
+func stampOf(path string) stamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return stamp{}
+	}
+	return stamp{info.ModTime(), info.Size()}
+}
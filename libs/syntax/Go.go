@@ -0,0 +1,98 @@
+package syntax
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Go highlights Go source.
+type Go struct{}
+
+const (
+	goNormal State = iota
+	goBlockComment
+	goRawString
+)
+
+var (
+	goKeywords = set("break", "case", "chan", "const", "continue", "default", "defer", "else",
+		"fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map", "package",
+		"range", "return", "select", "struct", "switch", "type", "var")
+	goTypes = set("any", "bool", "byte", "comparable", "complex64", "complex128", "error",
+		"float32", "float64", "int", "int8", "int16", "int32", "int64", "rune", "string",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr")
+	goBuiltins = set("append", "cap", "clear", "close", "complex", "copy", "delete", "imag",
+		"len", "make", "max", "min", "new", "panic", "print", "println", "real", "recover")
+	goConstants = set("true", "false", "nil", "iota")
+)
+
+func (Go) Name() string { return "Go" }
+
+func (Go) Tokenize(line []rune, state State) ([]Span, State) {
+	l := newLexer(line)
+	switch state {
+	case goBlockComment:
+		if !l.blockEnd(0, "*/", Comment) {
+			return l.spans, goBlockComment
+		}
+	case goRawString:
+		if !l.blockEnd(0, "`", String) {
+			return l.spans, goRawString
+		}
+	}
+
+	for !l.done() {
+		start := l.pos
+		r := l.peek(0)
+		switch {
+		case l.at("//"):
+			l.rest(Comment)
+		case l.accept("/*"):
+			if !l.blockEnd(start, "*/", Comment) {
+				return l.spans, goBlockComment
+			}
+		case r == '`':
+			l.pos++
+			if !l.blockEnd(start, "`", String) {
+				return l.spans, goRawString
+			}
+		case r == '"' || r == '\'':
+			l.pos++
+			l.quoted(start, r, true)
+		case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(l.peek(1))):
+			l.number()
+		case isIdentStart(r):
+			w := l.word()
+			switch {
+			case goKeywords[w]:
+				l.emit(start, Keyword)
+			case goConstants[w]:
+				l.emit(start, Constant)
+			case goTypes[w]:
+				l.emit(start, Type)
+			case l.peek(0) == '(' && goBuiltins[w]:
+				l.emit(start, Builtin)
+			case l.peek(0) == '(':
+				l.emit(start, Function)
+			case isExportedAfterDot(line, start):
+				// Qualified names like io.Reader are usually types.
+				l.emit(start, Type)
+			}
+		case strings.ContainsRune("+-*/%&|^<>=!:~", r):
+			l.skip(func(r rune) bool { return strings.ContainsRune("+-*/%&|^<>=!:~", r) && !l.at("//") && !l.at("/*") })
+			l.emit(start, Operator)
+		case strings.ContainsRune("(){}[],;.", r):
+			l.pos++
+			l.emit(start, Punctuation)
+		default:
+			l.pos++
+		}
+	}
+	return l.spans, goNormal
+}
+
+// isExportedAfterDot reports whether the identifier at start follows a
+// package selector and is exported.
+func isExportedAfterDot(line []rune, start int) bool {
+	return start > 0 && line[start-1] == '.' && unicode.IsUpper(line[start])
+}
@@ -0,0 +1,99 @@
+package syntax
+
+// Lines is the text a Highlighter reads. buffer.Buffer satisfies it.
+type Lines interface {
+	LineCount() int
+	Line(line int) string
+}
+
+// Highlighter caches the spans of every line of a document. Lines are
+// tokenized when they are first asked for, and after an edit only the edited
+// lines are, followed by as many lines as it takes for the lexer state at
+// the end of a line to match what it was before.
+type Highlighter struct {
+	lang  Language
+	lines []lineCache
+	// dirty is the first line that may need tokenizing; every line before
+	// it is up to date.
+	dirty int
+}
+
+type lineCache struct {
+	spans []Span
+	end   State
+	valid bool
+}
+
+// NewHighlighter returns a highlighter for a document in lang, which may be
+// nil for plain text.
+func NewHighlighter(lang Language) *Highlighter {
+	return &Highlighter{lang: lang}
+}
+
+func (h *Highlighter) Language() Language {
+	return h.lang
+}
+
+// SetLanguage switches to lang and forgets all spans.
+func (h *Highlighter) SetLanguage(lang Language) {
+	h.lang = lang
+	h.lines = nil
+	h.dirty = 0
+}
+
+// Edited tells the highlighter that lines line to line+removed were replaced
+// by lines line to line+inserted.
+func (h *Highlighter) Edited(line, removed, inserted int) {
+	if h.lang == nil || line >= len(h.lines) {
+		h.dirty = min(h.dirty, line)
+		return
+	}
+	last := min(line+removed, len(h.lines)-1)
+	// The last new line inherits the old end state, so that tokenizing can
+	// stop there if the edit didn't change it.
+	end := h.lines[last].end
+	fresh := make([]lineCache, inserted+1)
+	fresh[inserted].end = end
+	h.lines = append(h.lines[:line], append(fresh, h.lines[last+1:]...)...)
+	h.dirty = min(h.dirty, line)
+}
+
+// Spans returns the spans of line n of text.
+func (h *Highlighter) Spans(text Lines, n int) []Span {
+	if h.lang == nil {
+		return nil
+	}
+	count := text.LineCount()
+	if len(h.lines) != count {
+		// Out of step with the text; start over rather than show garbage.
+		if len(h.lines) != 0 {
+			h.lines = h.lines[:0]
+		}
+		for len(h.lines) < count {
+			h.lines = append(h.lines, lineCache{})
+		}
+		h.dirty = 0
+	}
+	if n < 0 || n >= count {
+		return nil
+	}
+
+	for i := h.dirty; i <= n; i++ {
+		c := &h.lines[i]
+		if c.valid {
+			continue
+		}
+		var start State
+		if i > 0 {
+			start = h.lines[i-1].end
+		}
+		spans, end := h.lang.Tokenize([]rune(text.Line(i)), start)
+		if end != c.end && i+1 < count {
+			// The next line starts in a different state now.
+			h.lines[i+1].valid = false
+		}
+		*c = lineCache{spans: spans, end: end, valid: true}
+	}
+	h.dirty = max(h.dirty, n+1)
+	return h.lines[n].spans
+}
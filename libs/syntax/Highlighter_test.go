@@ -0,0 +1,135 @@
+package syntax
+
+import (
+	"strings"
+	"testing"
+)
+
+// lines is the text of a document as a slice of lines.
+type lines []string
+
+func (l lines) LineCount() int       { return len(l) }
+func (l lines) Line(line int) string { return l[line] }
+
+// counting wraps a language and records which lines it tokenized.
+type counting struct {
+	Language
+	seen []string
+}
+
+func (c *counting) Tokenize(line []rune, state State) ([]Span, State) {
+	c.seen = append(c.seen, string(line))
+	return c.Language.Tokenize(line, state)
+}
+
+func kindAt(spans []Span, col int) Kind {
+	for _, s := range spans {
+		if s.Start <= col && col < s.End {
+			return s.Kind
+		}
+	}
+	return Plain
+}
+
+func TestHighlighterCarriesState(t *testing.T) {
+	text := lines{"x := 1 /* open", "still comment", "done */ y := 2"}
+	h := NewHighlighter(Go{})
+	if k := kindAt(h.Spans(text, 1), 0); k != Comment {
+		t.Errorf("line inside a block comment is %v, want comment", k)
+	}
+	spans := h.Spans(text, 2)
+	if k := kindAt(spans, 0); k != Comment {
+		t.Errorf("end of the block comment is %v, want comment", k)
+	}
+	if k := kindAt(spans, 13); k != Number {
+		t.Errorf("number after the comment is %v, want number", k)
+	}
+}
+
+func TestHighlighterTokenizesOnlyWhatChanged(t *testing.T) {
+	text := lines{"a := 1", "b := 2", "c := 3", "d := 4"}
+	lang := &counting{Language: Go{}}
+	h := NewHighlighter(lang)
+	h.Spans(text, 3)
+	if len(lang.seen) != 4 {
+		t.Fatalf("first Spans tokenized %d lines, want 4", len(lang.seen))
+	}
+
+	// Asking again doesn't tokenize anything.
+	lang.seen = nil
+	h.Spans(text, 3)
+	h.Spans(text, 0)
+	if len(lang.seen) != 0 {
+		t.Errorf("cached lines were tokenized again: %q", lang.seen)
+	}
+
+	// An edit that keeps the state at the end of the line only redoes it.
+	text[1] = "b := 20"
+	h.Edited(1, 0, 0)
+	h.Spans(text, 3)
+	if got := strings.Join(lang.seen, "|"); got != "b := 20" {
+		t.Errorf("after editing line 1, tokenized %q, want only that line", got)
+	}
+
+	// Opening a block comment changes the state, so the lines after it are
+	// redone until the state matches again.
+	lang.seen = nil
+	text[1] = "b := 2 /*"
+	h.Edited(1, 0, 0)
+	spans := h.Spans(text, 3)
+	if len(lang.seen) != 3 {
+		t.Errorf("opening a comment tokenized %q, want lines 1 to 3", lang.seen)
+	}
+	if k := kindAt(spans, 0); k != Comment {
+		t.Errorf("line 3 is %v after the comment was opened, want comment", k)
+	}
+}
+
+func TestHighlighterInsertedLines(t *testing.T) {
+	text := lines{"a := 1", "b := 2"}
+	h := NewHighlighter(Go{})
+	h.Spans(text, 1)
+
+	text = lines{"a := 1", "// new", "b := 2"}
+	h.Edited(0, 0, 1)
+	if k := kindAt(h.Spans(text, 1), 0); k != Comment {
+		t.Errorf("inserted line is %v, want comment", k)
+	}
+	if k := kindAt(h.Spans(text, 2), 5); k != Number {
+		t.Errorf("line after the insertion is %v at the number, want number", k)
+	}
+
+	text = lines{"a := 1", "b := 2"}
+	h.Edited(0, 1, 0)
+	if k := kindAt(h.Spans(text, 1), 5); k != Number {
+		t.Errorf("line after a deletion is %v at the number, want number", k)
+	}
+}
+
+func TestHighlighterPlainText(t *testing.T) {
+	h := NewHighlighter(nil)
+	if spans := h.Spans(lines{"func"}, 0); spans != nil {
+		t.Errorf("plain text has spans %v", spans)
+	}
+}
+
+func TestForFile(t *testing.T) {
+	tests := map[string]string{
+		"main.go":          "Go",
+		"dir/README.md":    "Markdown",
+		"settings.json":    "JSON",
+		".bashrc":          "Shell",
+		"config.yml":       "YAML",
+		"notes.txt":        "",
+		"go.mod.orig.json": "JSON",
+	}
+	for name, want := range tests {
+		got := ""
+		if lang := ForFile(name); lang != nil {
+			got = lang.Name()
+		}
+		if got != want {
+			t.Errorf("ForFile(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
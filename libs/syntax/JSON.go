@@ -0,0 +1,61 @@
+package syntax
+
+import (
+	"strings"
+	"unicode"
+)
+
+// JSON highlights JSON, allowing the comments of JSON with comments.
+type JSON struct{}
+
+const (
+	jsonNormal State = iota
+	jsonBlockComment
+)
+
+func (JSON) Name() string { return "JSON" }
+
+func (JSON) Tokenize(line []rune, state State) ([]Span, State) {
+	l := newLexer(line)
+	if state == jsonBlockComment && !l.blockEnd(0, "*/", Comment) {
+		return l.spans, jsonBlockComment
+	}
+	for !l.done() {
+		start := l.pos
+		r := l.peek(0)
+		switch {
+		case l.at("//"):
+			l.rest(Comment)
+		case l.accept("/*"):
+			if !l.blockEnd(start, "*/", Comment) {
+				return l.spans, jsonBlockComment
+			}
+		case r == '"':
+			n := len(l.spans)
+			l.pos++
+			if !l.quoted(start, '"', true) {
+				break
+			}
+			// A string followed by a colon is an object key.
+			if l.nextNonSpace() == ':' {
+				l.spans = l.spans[:n]
+				l.emit(start, Key)
+			}
+		case r == '-' || unicode.IsDigit(r):
+			l.accept("-")
+			l.emit(start, Number)
+			l.number()
+		case isIdentStart(r):
+			switch l.word() {
+			case "true", "false", "null":
+				l.emit(start, Constant)
+			}
+		case strings.ContainsRune("{}[],:", r):
+			l.pos++
+			l.emit(start, Punctuation)
+		default:
+			l.pos++
+		}
+	}
+	return l.spans, jsonNormal
+}
@@ -0,0 +1,180 @@
+package syntax
+
+import "unicode"
+
+// lexer is a cursor over one line that collects spans, shared by the
+// hand-written languages.
+type lexer struct {
+	line  []rune
+	pos   int
+	spans []Span
+}
+
+func newLexer(line []rune) *lexer {
+	return &lexer{line: line}
+}
+
+func (l *lexer) done() bool {
+	return l.pos >= len(l.line)
+}
+
+// peek returns the rune off places ahead, or 0 past the end.
+func (l *lexer) peek(off int) rune {
+	if i := l.pos + off; i >= 0 && i < len(l.line) {
+		return l.line[i]
+	}
+	return 0
+}
+
+// at reports whether the line continues with s at the current position.
+func (l *lexer) at(s string) bool {
+	i := l.pos
+	for _, r := range s {
+		if i >= len(l.line) || l.line[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// accept advances past s if the line continues with it.
+func (l *lexer) accept(s string) bool {
+	if !l.at(s) {
+		return false
+	}
+	l.pos += len([]rune(s))
+	return true
+}
+
+// skip advances while f holds.
+func (l *lexer) skip(f func(rune) bool) {
+	for l.pos < len(l.line) && f(l.line[l.pos]) {
+		l.pos++
+	}
+}
+
+// emit adds a span from start to the current position, merging it with the
+// previous span when they touch and share a kind.
+func (l *lexer) emit(start int, kind Kind) {
+	if start >= l.pos || kind == Plain {
+		return
+	}
+	if n := len(l.spans); n > 0 && l.spans[n-1].End == start && l.spans[n-1].Kind == kind {
+		l.spans[n-1].End = l.pos
+		return
+	}
+	l.spans = append(l.spans, Span{Start: start, End: l.pos, Kind: kind})
+}
+
+// rest emits the remainder of the line as kind.
+func (l *lexer) rest(kind Kind) {
+	start := l.pos
+	l.pos = len(l.line)
+	l.emit(start, kind)
+}
+
+// nextNonSpace returns the first rune from the current position that isn't
+// a space or tab, without advancing.
+func (l *lexer) nextNonSpace() rune {
+	for i := l.pos; i < len(l.line); i++ {
+		if !isSpace(l.line[i]) {
+			return l.line[i]
+		}
+	}
+	return 0
+}
+
+// word reads an identifier.
+func (l *lexer) word() string {
+	start := l.pos
+	l.skip(isIdent)
+	return string(l.line[start:l.pos])
+}
+
+// quoted reads a string started at start up to the closing quote,
+// honouring backslash escapes, which get their own spans. It reports whether
+// the quote was closed on this line.
+func (l *lexer) quoted(start int, quote rune, escapes bool) bool {
+	for !l.done() {
+		r := l.line[l.pos]
+		switch {
+		case r == quote:
+			l.pos++
+			l.emit(start, String)
+			return true
+		case r == '\\' && escapes:
+			l.emit(start, String)
+			esc := l.pos
+			l.pos = min(l.pos+2, len(l.line))
+			l.emit(esc, Escape)
+			start = l.pos
+		default:
+			l.pos++
+		}
+	}
+	l.emit(start, String)
+	return false
+}
+
+// blockEnd emits everything from start up to and including end as kind and
+// reports whether end was found on this line.
+func (l *lexer) blockEnd(start int, end string, kind Kind) bool {
+	for !l.done() {
+		if l.accept(end) {
+			l.emit(start, kind)
+			return true
+		}
+		l.pos++
+	}
+	l.emit(start, kind)
+	return false
+}
+
+// number reads a numeric literal in the style shared by most languages:
+// decimal, hex, octal and binary integers, floats with exponents and digit
+// separators.
+func (l *lexer) number() {
+	start := l.pos
+	if l.peek(0) == '0' && (l.peek(1) == 'x' || l.peek(1) == 'X') {
+		l.pos += 2
+		l.skip(func(r rune) bool { return isHexDigit(r) || r == '_' })
+	} else {
+		l.skip(func(r rune) bool { return unicode.IsDigit(r) || r == '_' })
+		if l.peek(0) == '.' && unicode.IsDigit(l.peek(1)) {
+			l.pos++
+			l.skip(func(r rune) bool { return unicode.IsDigit(r) || r == '_' })
+		}
+		if (l.peek(0) == 'e' || l.peek(0) == 'E') && (unicode.IsDigit(l.peek(1)) || ((l.peek(1) == '-' || l.peek(1) == '+') && unicode.IsDigit(l.peek(2)))) {
+			l.pos += 2
+			l.skip(unicode.IsDigit)
+		}
+	}
+	// Suffixes such as Go's imaginary i or the b and o of other bases.
+	l.skip(isIdent)
+	l.emit(start, Number)
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isHexDigit(r rune) bool {
+	return unicode.IsDigit(r) || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+func set(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
@@ -0,0 +1,188 @@
+package syntax
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Markdown highlights CommonMark documents.
+type Markdown struct{}
+
+const (
+	mdNormal State = iota
+	mdFenceBacktick
+	mdFenceTilde
+)
+
+func (Markdown) Name() string { return "Markdown" }
+
+func (Markdown) Tokenize(line []rune, state State) ([]Span, State) {
+	l := newLexer(line)
+	l.skip(isSpace)
+	indent := l.pos
+
+	switch state {
+	case mdFenceBacktick, mdFenceTilde:
+		fence := "```"
+		if state == mdFenceTilde {
+			fence = "~~~"
+		}
+		l.pos = 0
+		if indent < 4 && strings.HasPrefix(strings.TrimSpace(string(line)), fence) {
+			l.rest(Punctuation)
+			return l.spans, mdNormal
+		}
+		l.rest(Code)
+		return l.spans, state
+	}
+
+	switch {
+	case indent < 4 && (l.at("```") || l.at("~~~")):
+		next := mdFenceBacktick
+		if l.at("~~~") {
+			next = mdFenceTilde
+		}
+		l.pos = 0
+		l.rest(Punctuation)
+		return l.spans, next
+	case indent >= 4:
+		l.pos = 0
+		l.rest(Code)
+		return l.spans, mdNormal
+	case l.peek(0) == '#':
+		l.skip(func(r rune) bool { return r == '#' })
+		if l.pos-indent <= 6 && (l.done() || isSpace(l.peek(0))) {
+			l.pos = 0
+			l.rest(Heading)
+			return l.spans, mdNormal
+		}
+		l.pos = indent
+	case isRule(line):
+		l.pos = 0
+		l.rest(Punctuation)
+		return l.spans, mdNormal
+	case l.peek(0) == '>':
+		l.pos++
+		l.emit(indent, Punctuation)
+	case strings.ContainsRune("-*+", l.peek(0)) && isSpace(l.peek(1)):
+		l.pos++
+		l.emit(indent, Punctuation)
+	case unicode.IsDigit(l.peek(0)):
+		l.skip(unicode.IsDigit)
+		if (l.peek(0) == '.' || l.peek(0) == ')') && isSpace(l.peek(1)) {
+			l.pos++
+			l.emit(indent, Punctuation)
+		} else {
+			l.pos = indent
+		}
+	}
+	l.inline()
+	return l.spans, mdNormal
+}
+
+// inline highlights code spans, emphasis and links in the rest of the line.
+func (l *lexer) inline() {
+	for !l.done() {
+		start := l.pos
+		r := l.peek(0)
+		switch {
+		case r == '\\':
+			l.pos = min(l.pos+2, len(l.line))
+			l.emit(start, Escape)
+		case r == '`':
+			l.skip(func(r rune) bool { return r == '`' })
+			ticks := string(l.line[start:l.pos])
+			if !l.until(ticks) {
+				l.pos = start + len(ticks)
+				continue
+			}
+			l.emit(start, Code)
+		case (r == '*' || r == '_') && l.peek(1) == r:
+			l.pos += 2
+			if !l.until(string([]rune{r, r})) {
+				l.pos = start + 2
+				continue
+			}
+			l.emit(start, Strong)
+		case (r == '*' || r == '_') && l.peek(1) != ' ' && l.peek(1) != 0:
+			l.pos++
+			if !l.until(string(r)) {
+				l.pos = start + 1
+				continue
+			}
+			l.emit(start, Emphasis)
+		case r == '[' || (r == '!' && l.peek(1) == '['):
+			if !l.link() {
+				l.pos = start + 1
+				continue
+			}
+			l.emit(start, Link)
+		case r == '<' && (l.at("<http://") || l.at("<https://")):
+			if !l.until(">") {
+				l.pos = start + 1
+				continue
+			}
+			l.emit(start, Link)
+		default:
+			l.pos++
+		}
+	}
+}
+
+// until advances past the next occurrence of end and reports whether there
+// was one. The position is left unchanged otherwise.
+func (l *lexer) until(end string) bool {
+	start := l.pos
+	for !l.done() {
+		if l.accept(end) {
+			return true
+		}
+		l.pos++
+	}
+	l.pos = start
+	return false
+}
+
+// link reads [text](target) or [text][ref], optionally preceded by the !
+// of an image.
+func (l *lexer) link() bool {
+	start := l.pos
+	l.accept("!")
+	l.pos++
+	if !l.until("]") {
+		l.pos = start
+		return false
+	}
+	switch {
+	case l.accept("("):
+		if !l.until(")") {
+			l.pos = start
+			return false
+		}
+	case l.accept("["):
+		if !l.until("]") {
+			l.pos = start
+			return false
+		}
+	}
+	return true
+}
+
+// isRule reports whether line is a thematic break such as "---" or "* * *".
+func isRule(line []rune) bool {
+	var mark rune
+	n := 0
+	for _, r := range line {
+		switch {
+		case isSpace(r):
+		case mark == 0 && (r == '-' || r == '*' || r == '_'):
+			mark = r
+			n++
+		case r == mark:
+			n++
+		default:
+			return false
+		}
+	}
+	return n >= 3
+}
@@ -0,0 +1,194 @@
+package syntax
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Shell highlights POSIX shell and bash scripts.
+type Shell struct{}
+
+const (
+	shNormal State = iota
+	shDoubleQuote
+	shSingleQuote
+)
+
+var (
+	shKeywords = set("if", "then", "else", "elif", "fi", "for", "while", "until", "do", "done",
+		"case", "esac", "in", "function", "select", "time", "coproc")
+	shBuiltins = set("alias", "bg", "break", "builtin", "cd", "command", "continue", "declare",
+		"echo", "eval", "exec", "exit", "export", "false", "fg", "getopts", "hash", "jobs", "kill",
+		"let", "local", "printf", "pwd", "read", "readonly", "return", "set", "shift", "source",
+		"test", "trap", "true", "type", "ulimit", "umask", "unalias", "unset", "wait")
+)
+
+func (Shell) Name() string { return "Shell" }
+
+func (Shell) Tokenize(line []rune, state State) ([]Span, State) {
+	l := newLexer(line)
+	switch state {
+	case shDoubleQuote:
+		if !l.shellDoubleQuoted(0) {
+			return l.spans, shDoubleQuote
+		}
+	case shSingleQuote:
+		if !l.quoted(0, '\'', false) {
+			return l.spans, shSingleQuote
+		}
+	}
+
+	// commandStart is set where a word would be a command name.
+	commandStart := true
+	for !l.done() {
+		start := l.pos
+		r := l.peek(0)
+		switch {
+		case r == '#' && (start == 0 || isSpace(line[start-1]) || strings.ContainsRune(";&|(", line[start-1])):
+			l.rest(Comment)
+		case r == '"':
+			l.pos++
+			if !l.shellDoubleQuoted(start) {
+				return l.spans, shDoubleQuote
+			}
+			commandStart = false
+		case r == '\'':
+			l.pos++
+			if !l.quoted(start, '\'', false) {
+				return l.spans, shSingleQuote
+			}
+			commandStart = false
+		case r == '\\':
+			l.pos = min(l.pos+2, len(l.line))
+			l.emit(start, Escape)
+		case r == '$':
+			l.variable()
+			commandStart = false
+		case isSpace(r):
+			l.pos++
+		case strings.ContainsRune(";&|()", r):
+			l.skip(func(r rune) bool { return strings.ContainsRune(";&|()", r) })
+			l.emit(start, Operator)
+			commandStart = true
+		case strings.ContainsRune("<>", r):
+			l.skip(func(r rune) bool { return strings.ContainsRune("<>&-", r) })
+			l.emit(start, Operator)
+		case unicode.IsDigit(r) && !isIdent(l.peek(-1)):
+			l.skip(isIdent)
+			if isNumber(line[start:l.pos]) {
+				l.emit(start, Number)
+			}
+			commandStart = false
+		default:
+			l.skip(func(r rune) bool {
+				return !isSpace(r) && !strings.ContainsRune(";&|()<>\"'$`\\", r)
+			})
+			if l.pos == start {
+				l.pos++
+				break
+			}
+			w := string(line[start:l.pos])
+			switch {
+			case w == "{" || w == "}" || w == "!":
+				l.emit(start, Punctuation)
+				commandStart = true
+				continue
+			case shKeywords[w] && commandStart:
+				l.emit(start, Keyword)
+				continue
+			case l.peek(0) == '(' && l.peek(1) == ')':
+				l.emit(start, Function)
+			case isAssignment(w):
+				eq := start + len([]rune(w[:strings.IndexByte(w, '=')]))
+				end := l.pos
+				l.pos = eq
+				l.emit(start, Variable)
+				l.pos = eq + 1
+				l.emit(eq, Operator)
+				l.pos = end
+				continue
+			case shBuiltins[w] && commandStart:
+				l.emit(start, Builtin)
+			case commandStart:
+				l.emit(start, Function)
+			}
+			commandStart = false
+		}
+	}
+	return l.spans, shNormal
+}
+
+// shellDoubleQuoted reads a double-quoted string from start, in which
+// variables are expanded. It reports whether the string was closed.
+func (l *lexer) shellDoubleQuoted(start int) bool {
+	for !l.done() {
+		switch l.peek(0) {
+		case '"':
+			l.pos++
+			l.emit(start, String)
+			return true
+		case '\\':
+			l.emit(start, String)
+			esc := l.pos
+			l.pos = min(l.pos+2, len(l.line))
+			l.emit(esc, Escape)
+			start = l.pos
+		case '$':
+			l.emit(start, String)
+			l.variable()
+			start = l.pos
+		default:
+			l.pos++
+		}
+	}
+	l.emit(start, String)
+	return false
+}
+
+// variable reads a parameter expansion such as $x, ${x:-y}, $1 or $(cmd).
+func (l *lexer) variable() {
+	start := l.pos
+	l.pos++
+	switch r := l.peek(0); {
+	case r == '{':
+		l.until("}")
+		if l.peek(-1) != '}' {
+			l.pos = len(l.line)
+		}
+	case r == '(':
+		// Command substitution: highlight only the opening.
+		l.pos++
+	case isIdentStart(r):
+		l.skip(isIdent)
+	case unicode.IsDigit(r) || strings.ContainsRune("@*#?$!-", r):
+		l.pos++
+	}
+	l.emit(start, Variable)
+}
+
+// isAssignment reports whether word starts with "name=".
+func isAssignment(word string) bool {
+	i := strings.IndexByte(word, '=')
+	return i > 0 && isShellName(word[:i])
+}
+
+func isShellName(s string) bool {
+	if s == "" || !isIdentStart(rune(s[0])) {
+		return false
+	}
+	for _, r := range s {
+		if !isIdent(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNumber(word []rune) bool {
+	for _, r := range word {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+// Package syntax splits lines of source code into styled spans.
+package syntax
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Kind is the role of a span of text, which a theme maps to a style.
+type Kind uint8
+
+const (
+	Plain Kind = iota
+	Keyword
+	Type
+	Builtin
+	Function
+	Constant
+	Number
+	String
+	Escape
+	Comment
+	Operator
+	Punctuation
+	Variable
+	Key
+	Heading
+	Emphasis
+	Strong
+	Code
+	Link
+)
+
+var kindNames = [...]string{
+	Plain:       "plain",
+	Keyword:     "keyword",
+	Type:        "type",
+	Builtin:     "builtin",
+	Function:    "function",
+	Constant:    "constant",
+	Number:      "number",
+	String:      "string",
+	Escape:      "escape",
+	Comment:     "comment",
+	Operator:    "operator",
+	Punctuation: "punctuation",
+	Variable:    "variable",
+	Key:         "key",
+	Heading:     "heading",
+	Emphasis:    "emphasis",
+	Strong:      "strong",
+	Code:        "code",
+	Link:        "link",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "plain"
+}
+
+// KindByName returns the kind called name, as returned by Kind.String.
+func KindByName(name string) (Kind, bool) {
+	for k, n := range kindNames {
+		if n == name {
+			return Kind(k), true
+		}
+	}
+	return Plain, false
+}
+
+// Span is a styled run of a line. Start and End are rune columns.
+type Span struct {
+	Start, End int
+	Kind       Kind
+}
+
+// State is what a lexer carries over from the end of one line to the next,
+// such as being inside a block comment. The zero State is the start of a
+// file. Languages choose their own values.
+type State int
+
+// Language tokenizes the lines of one file type.
+type Language interface {
+	Name() string
+	// Tokenize splits line into spans, starting in the state the previous
+	// line ended in, and returns the state at the end of the line. Text not
+	// covered by a span is plain.
+	Tokenize(line []rune, state State) ([]Span, State)
+}
+
+type registration struct {
+	lang Language
+	// patterns are file name globs such as "*.go" or "Makefile".
+	patterns []string
+}
+
+var languages []registration
+
+// Register makes lang available for files whose name matches one of the
+// glob patterns. Later registrations take precedence.
+func Register(lang Language, patterns ...string) {
+	languages = append(languages, registration{lang, patterns})
+}
+
+// ForFile returns the language of the file with the given name, or nil for
+// plain text.
+func ForFile(name string) Language {
+	base := filepath.Base(name)
+	for i := len(languages) - 1; i >= 0; i-- {
+		for _, p := range languages[i].patterns {
+			if ok, _ := filepath.Match(p, base); ok {
+				return languages[i].lang
+			}
+		}
+	}
+	return nil
+}
+
+// Lookup returns the registered language called name, ignoring case.
+func Lookup(name string) Language {
+	for i := len(languages) - 1; i >= 0; i-- {
+		if strings.EqualFold(languages[i].lang.Name(), name) {
+			return languages[i].lang
+		}
+	}
+	return nil
+}
+
+// Languages returns the registered languages.
+func Languages() []Language {
+	langs := make([]Language, len(languages))
+	for i, r := range languages {
+		langs[i] = r.lang
+	}
+	return langs
+}
+
+func init() {
+	Register(Go{}, "*.go")
+	Register(Markdown{}, "*.md", "*.markdown")
+	Register(JSON{}, "*.json", "*.jsonc", ".prettierrc", "*.code-workspace")
+	Register(YAML{}, "*.yaml", "*.yml")
+	Register(Shell{}, "*.sh", "*.bash", "*.zsh", ".bashrc", ".zshrc", ".profile", ".bash_profile")
+}
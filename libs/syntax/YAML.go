@@ -0,0 +1,157 @@
+package syntax
+
+import (
+	"strings"
+	"unicode"
+)
+
+// YAML highlights YAML documents.
+type YAML struct{}
+
+// A YAML state above zero means the line is inside a block scalar (| or >)
+// whose key is indented by state-1 columns.
+const yamlNormal State = 0
+
+var yamlConstants = set("true", "false", "yes", "no", "on", "off", "null", "True", "False", "TRUE", "FALSE", "Null", "NULL", "~")
+
+func (YAML) Name() string { return "YAML" }
+
+func (YAML) Tokenize(line []rune, state State) ([]Span, State) {
+	l := newLexer(line)
+	l.skip(isSpace)
+	indent := l.pos
+
+	if state > yamlNormal {
+		// Blank lines and lines indented deeper than the key stay in the
+		// block scalar.
+		if l.done() || indent > int(state)-1 {
+			l.pos = 0
+			l.rest(String)
+			return l.spans, state
+		}
+		state = yamlNormal
+	}
+
+	if indent == 0 && (l.at("---") || l.at("...")) {
+		l.rest(Punctuation)
+		return l.spans, yamlNormal
+	}
+	// Sequence entries, which may start a mapping on the same line.
+	for l.peek(0) == '-' && (isSpace(l.peek(1)) || l.peek(1) == 0) {
+		start := l.pos
+		l.pos++
+		l.emit(start, Punctuation)
+		l.skip(isSpace)
+	}
+	keyIndent := l.pos
+
+	if l.yamlKey() {
+		l.skip(isSpace)
+	}
+	next := yamlNormal
+	for !l.done() {
+		start := l.pos
+		r := l.peek(0)
+		switch {
+		case r == '#' && (start == 0 || isSpace(line[start-1])):
+			l.rest(Comment)
+		case r == '"':
+			l.pos++
+			l.quoted(start, '"', true)
+		case r == '\'':
+			l.pos++
+			l.quoted(start, '\'', false)
+		case (r == '|' || r == '>') && isBlockIndicator(line[start+1:]):
+			l.pos++
+			l.skip(func(r rune) bool { return r == '+' || r == '-' || unicode.IsDigit(r) })
+			l.emit(start, Operator)
+			next = State(keyIndent + 1)
+		case r == '&' || (r == '*' && isIdent(l.peek(1))):
+			l.pos++
+			l.skip(func(r rune) bool { return !isSpace(r) && r != ',' && r != ']' && r != '}' })
+			l.emit(start, Variable)
+		case r == '!':
+			l.skip(func(r rune) bool { return !isSpace(r) })
+			l.emit(start, Type)
+		case strings.ContainsRune("{}[],", r):
+			l.pos++
+			l.emit(start, Punctuation)
+			l.skip(isSpace)
+			l.yamlKey()
+		case isSpace(r):
+			l.pos++
+		default:
+			// A plain scalar runs up to a comment or the end of a flow item.
+			l.skip(func(r rune) bool {
+				return !strings.ContainsRune(",]}", r) && !(r == '#' && isSpace(l.peek(-1)))
+			})
+			word := strings.TrimSpace(string(line[start:l.pos]))
+			switch {
+			case yamlConstants[word]:
+				l.emit(start, Constant)
+			case isYAMLNumber(word):
+				l.emit(start, Number)
+			}
+		}
+	}
+	return l.spans, next
+}
+
+// yamlKey emits the mapping key at the current position, if there is one,
+// along with its colon.
+func (l *lexer) yamlKey() bool {
+	start := l.pos
+	end := -1
+	quote := rune(0)
+	for i := l.pos; i < len(l.line); i++ {
+		r := l.line[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && i == start:
+			quote = r
+		case r == ':' && (i+1 == len(l.line) || isSpace(l.line[i+1])):
+			end = i
+		case r == '#' && i > 0 && isSpace(l.line[i-1]):
+			i = len(l.line)
+		case strings.ContainsRune("{}[],", r):
+			i = len(l.line)
+		}
+		if end >= 0 {
+			break
+		}
+	}
+	if end < 0 {
+		return false
+	}
+	l.pos = end
+	l.emit(start, Key)
+	l.pos++
+	l.emit(end, Punctuation)
+	return true
+}
+
+// isBlockIndicator reports whether the rest of a line after | or > only
+// holds the indicator's modifiers and maybe a comment.
+func isBlockIndicator(rest []rune) bool {
+	s := strings.TrimSpace(strings.TrimLeft(string(rest), "+-0123456789"))
+	return s == "" || strings.HasPrefix(s, "#")
+}
+
+func isYAMLNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	if s == ".inf" || s == ".nan" || s == ".Inf" || s == ".NaN" {
+		return true
+	}
+	l := newLexer([]rune(s))
+	if !unicode.IsDigit(l.peek(0)) && !(l.peek(0) == '.' && unicode.IsDigit(l.peek(1))) {
+		return false
+	}
+	l.number()
+	return l.done()
+}
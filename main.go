@@ -1,10 +1,13 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"os"
+	"path/filepath"
 
 	"gioui.org/app"
 	"gioui.org/layout"
@@ -12,12 +15,18 @@ import (
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/widget/material"
+	"github.com/vypal/vedit/libs"
 	"github.com/vypal/vedit/ui/editor"
+	"github.com/vypal/vedit/ui/explorer"
+	"github.com/vypal/vedit/ui/theme"
 	"github.com/vypal/vedit/ui/toolbar"
 	"github.com/vypal/vedit/ui/widgets"
 )
 
+var themePath = flag.String("theme", "", "color theme to load")
+
 func main() {
+	flag.Parse()
 	go func() {
 		window := new(app.Window)
 		err := run(window)
@@ -29,16 +38,151 @@ func main() {
 	app.Main()
 }
 
+// colors is shared by every part of the window, so that loading a theme
+// into it repaints them all.
+var colors = theme.Default()
+
 var LayoutManager = widgets.NewLayoutManager()
 var edit *editor.Editor
+var files *explorer.Explorer
+var prompt = &widgets.Prompt{Colors: colors}
+var statusBar = &widgets.StatusBar{Colors: colors}
+
+// openFiles loads the files named on the command line and shows the first.
+// A directory among them becomes the root of the file tree.
+func openFiles(paths []string) {
+	var first *editor.Document
+	for _, path := range paths {
+		if stat, err := os.Stat(path); err == nil && stat.IsDir() {
+			setRoot(path)
+			continue
+		}
+		doc, err := editor.OpenDocument(path)
+		if err != nil {
+			log.Printf("opening %s: %v", path, err)
+			continue
+		}
+		addDocument(doc)
+		if first == nil {
+			first = doc
+		}
+	}
+	if first != nil {
+		show(first)
+	}
+}
+
+func setRoot(path string) {
+	root, err := libs.OpenDirectory(path)
+	if err != nil {
+		log.Printf("opening %s: %v", path, err)
+		return
+	}
+	files.SetRoot(root)
+	projectChanged()
+}
+
+// openPath shows the file at path in the editor.
+func openPath(path string) {
+	if doc := buffers.Find(path); doc != nil {
+		show(doc)
+		return
+	}
+	doc, err := editor.OpenDocument(path)
+	if err != nil {
+		log.Printf("opening %s: %v", path, err)
+		return
+	}
+	addDocument(doc)
+}
+
+// promptPath suggests a path next to the current document.
+func promptPath() string {
+	doc := edit.Document()
+	if doc.Untitled() {
+		wd, _ := os.Getwd()
+		return wd + string(filepath.Separator)
+	}
+	return doc.File.FullPath()
+}
+
+// askOpen asks for a file to open.
+func askOpen() {
+	prompt.Show("Open:", promptPath(), func(path string) {
+		if abs, err := filepath.Abs(path); err == nil {
+			openPath(abs)
+		}
+	})
+}
+
+// askSaveAs asks for a file name to save the current document as.
+func askSaveAs() {
+	prompt.Show("Save as:", promptPath(), func(path string) {
+		if err := edit.Document().SaveAs(path); err != nil {
+			log.Printf("saving %s: %v", path, err)
+		}
+	})
+}
+
+func setupPrompts() {
+	prompt.OnHide = edit.Focus
+	edit.OnSaveAs = askSaveAs
+}
+
+// loadTheme replaces the colors of the window with the theme at path.
+func loadTheme(path string, th *material.Theme) {
+	t, err := theme.Load(path)
+	if err != nil {
+		log.Printf("loading theme %s: %v", path, err)
+		return
+	}
+	*colors = *t
+	colors.Apply(th)
+}
+
+func windowTitle() string {
+	doc := edit.Document()
+	title := doc.Title()
+	if doc.Dirty() {
+		title = "• " + title
+	}
+	return fmt.Sprintf("%s - Vedit", title)
+}
 
 func exampleSplit(th *material.Theme) {
 	edit = editor.NewEditor(th.Shaper)
+	edit.Colors = colors
+	edit.OnClose = func() { removeDocument(edit.Document()) }
+	LayoutManager.Colors = colors
+	buffers.Add(edit.Document())
+	root, err := libs.OpenDirectory(".")
+	if err != nil {
+		log.Fatal(err)
+	}
+	files = explorer.New(root, th)
+	files.Colors = colors
+	files.OnOpen = openPath
+	files.Ask = prompt.Show
+	files.OnRenamed = func(oldPath, newPath string) {
+		for _, doc := range buffers.Documents() {
+			doc.Moved(oldPath, newPath)
+		}
+	}
+	files.OnDeleted = func(path string) {
+		// Unsaved changes stay open for saving elsewhere; the rest close.
+		for _, doc := range append([]*editor.Document(nil), buffers.Documents()...) {
+			if doc.Deleted(path) && !doc.Dirty() {
+				removeDocument(doc)
+			}
+		}
+	}
 	toolbar := toolbar.ToolBar{
 		Items: []toolbar.ToolBarItem{
-			&toolbar.Button{Text: "New", Theme: th},
+			&toolbar.Button{Text: "New", Theme: th, Command: "file.new", Commands: registry},
+			&toolbar.Button{Text: "Search", Theme: th, Command: "view.toggleSearch", Commands: registry},
+			&toolbar.Button{Text: "Theme", Theme: th, Command: "view.loadTheme", Commands: registry},
 		},
-		BackgroundColor: color.NRGBA{R: 0x1a, G: 0x1b, B: 0x1b, A: 0xff},
+		Colors: colors,
 	}
 	// Vytvoření kořenového rozdělení
 	rootsplit := LayoutManager.AddSplit(nil, widgets.Horizontal, 0.075, nil)
@@ -50,11 +194,29 @@ func exampleSplit(th *material.Theme) {
 
 	// Vytvoření a přidání dalších rozdělení a komponent
 	bsplit := LayoutManager.AddSplit(rootsplit, widgets.Vertical, -0.5, nil)
+	LayoutManager.AddSplit(bsplit, widgets.Horizontal, 0.5, layoutSidebar)
 	LayoutManager.AddSplit(bsplit, widgets.Horizontal, 0.5, func(gtx layout.Context) layout.Dimensions {
-		return FillWithLabel(gtx, th, "Files", color.NRGBA{R: 0x1a, G: 0x1b, B: 0x1b, A: 0xff})
-	})
-	LayoutManager.AddSplit(bsplit, widgets.Horizontal, 0.5, func(gtx layout.Context) layout.Dimensions {
-		return edit.Layout(gtx, th)
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layoutTabs(gtx, th)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				updateFindStatus()
+				return findBar.Layout(gtx, th)
+			}),
+			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+				return edit.Layout(gtx, th)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				// The bar stays while other keybindings are picked, as
+				// their messages come and go.
+				statusBar.Left, statusBar.Right = edit.Status()
+				if statusBar.Left == "" && config.Keybindings == "" {
+					return layout.Dimensions{}
+				}
+				return statusBar.Layout(gtx, th)
+			}),
+		)
 	})
 
 	// Nastavení minimální velikosti pro kořenové rozdělení
@@ -63,19 +225,48 @@ func exampleSplit(th *material.Theme) {
 
 func run(window *app.Window) error {
 	theme := material.NewTheme()
+	colors.Apply(theme)
 	exampleSplit(theme)
+	setupPrompts()
+	setupFind()
+	setupSearch(window, theme)
+	setupQuickOpen(window)
+	setupCommands(theme)
+	openFiles(flag.Args())
+	watchSettings(window, theme)
+	if *themePath != "" {
+		loadTheme(*themePath, theme)
+	}
 	var ops op.Ops
+	var title string
 	for {
 
 		switch e := window.Event().(type) {
 		case app.DestroyEvent:
 			return e.Err
 		case app.FrameEvent:
-			paint.Fill(&ops, color.NRGBA{R: 0x1a, G: 0x1b, B: 0x1b, A: 0xff})
+			if reloadSettings.Swap(false) {
+				applySettings(theme)
+			}
+			if indexChanged.Swap(false) {
+				quickOpen.Refresh()
+			}
+			paint.Fill(&ops, colors.Background)
 			// This graphics context is used for managing the rendering state.
 			gtx := app.NewContext(&ops, e)
 
+			handleTabKeys(gtx)
+			registry.Handle(gtx)
 			LayoutManager.Layout(gtx)
+			prompt.Layout(gtx, theme)
+			quickOpen.Layout(gtx, theme)
+			palette.Layout(gtx, theme)
+			keybindings.Layout(gtx, theme)
+
+			if t := windowTitle(); t != title {
+				title = t
+				window.Option(app.Title(title))
+			}
 
 			// Pass the drawing operations to the GPU.
 			e.Frame(&ops)
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"gioui.org/app"
+	"github.com/vypal/vedit/libs"
+	"github.com/vypal/vedit/libs/fuzzy"
+	"github.com/vypal/vedit/ui/editor"
+	"github.com/vypal/vedit/ui/widgets"
+)
+
+var quickOpen = &widgets.Picker{Colors: colors, Hint: "Go to file"}
+
+// fileIndex lists the files of the project for quick open.
+var fileIndex libs.FileIndex
+
+// indexWindow is redrawn when more files are indexed, which sets
+// indexChanged from the indexing goroutine.
+var indexWindow *app.Window
+var indexChanged atomic.Bool
+
+// recentFiles are the files shown most recently, the latest first. They
+// rank higher in quick open.
+var recentFiles []string
+
+const maxRecentFiles = 50
+
+// maxQuickOpenResults is how many files quick open lists.
+const maxQuickOpenResults = 100
+
+func setupQuickOpen(window *app.Window) {
+	indexWindow = window
+	refreshIndex()
+
+	var root string
+	var paths []string
+	var results []fuzzy.Result
+	quickOpen.OnHide = edit.Focus
+	quickOpen.Filter = func(query string) []widgets.PickerItem {
+		root, paths, _ = fileIndex.Files()
+		recent := make(map[string]int, len(recentFiles))
+		for i, path := range recentFiles {
+			if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+				recent[rel] = i
+			}
+		}
+		query = strings.ReplaceAll(query, " ", "")
+		results = fuzzy.Rank(query, paths, maxQuickOpenResults, func(i int) int {
+			if n, ok := recent[paths[i]]; ok {
+				return 20 + (maxRecentFiles-n)/5
+			}
+			return 0
+		})
+		items := make([]widgets.PickerItem, len(results))
+		for k, r := range results {
+			items[k] = pathItem(paths[r.Index], r.Positions)
+		}
+		return items
+	}
+	quickOpen.OnPick = func(i int) {
+		openPath(filepath.Join(root, paths[results[i].Index]))
+	}
+}
+
+// pathItem shows the name of the file at path first and its directory
+// after it, splitting the matched runes between them.
+func pathItem(path string, matched []int) widgets.PickerItem {
+	dir, name := filepath.Split(path)
+	split := len([]rune(dir))
+	item := widgets.PickerItem{Title: name, Detail: filepath.Clean(dir)}
+	if dir == "" {
+		item.Detail = ""
+	}
+	for _, i := range matched {
+		if i >= split {
+			item.TitleMatched = append(item.TitleMatched, i-split)
+		} else {
+			item.DetailMatched = append(item.DetailMatched, i)
+		}
+	}
+	return item
+}
+
+// refreshIndex lists the files of the project again in the background.
+func refreshIndex() {
+	if indexWindow == nil || files == nil {
+		return
+	}
+	fileIndex.Refresh(projectRoot(), files.Options, func() {
+		indexChanged.Store(true)
+		indexWindow.Invalidate()
+	})
+}
+
+// noteRecent moves the file of d to the front of the recent files.
+func noteRecent(d *editor.Document) {
+	if d.Untitled() {
+		return
+	}
+	path := d.File.FullPath()
+	for i, p := range recentFiles {
+		if p == path {
+			recentFiles = append(recentFiles[:i], recentFiles[i+1:]...)
+			break
+		}
+	}
+	recentFiles = append([]string{path}, recentFiles...)
+	if len(recentFiles) > maxRecentFiles {
+		recentFiles = recentFiles[:maxRecentFiles]
+	}
+}
+
+// showQuickOpen opens quick open. The files are listed again in case some
+// were added, while the old list is used until that is done.
+func showQuickOpen() {
+	refreshIndex()
+	quickOpen.Show("")
+}
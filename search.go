@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"gioui.org/app"
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/ui/searchpanel"
+)
+
+var searchPanel *searchpanel.Panel
+
+// showSearch puts the search panel in place of the file tree.
+var showSearch bool
+
+func setupSearch(window *app.Window, th *material.Theme) {
+	searchPanel = searchpanel.New(th)
+	searchPanel.Colors = colors
+	searchPanel.Invalidate = window.Invalidate
+	searchPanel.OnOpen = func(path string, start, end int) {
+		openPath(path)
+		edit.SetSelection(start, end)
+		edit.Focus()
+	}
+	searchPanel.Unsaved = func(path string) bool {
+		doc := buffers.Find(path)
+		return doc != nil && doc.Dirty()
+	}
+	searchPanel.OnReplaced = func(paths []string) {
+		for _, path := range paths {
+			doc := buffers.Find(path)
+			if doc == nil {
+				continue
+			}
+			if doc.Dirty() {
+				log.Printf("%s changed on disk but has unsaved changes", path)
+				continue
+			}
+			if err := doc.Reload(); err != nil {
+				log.Printf("reloading %s: %v", path, err)
+			}
+		}
+		// Keep the selection within the reloaded text.
+		edit.SetSelection(edit.Selection())
+	}
+}
+
+// openSearch shows the search panel, searching for the selected text.
+func openSearch() {
+	pattern := edit.SelectedText()
+	if strings.Contains(pattern, "\n") {
+		pattern = ""
+	}
+	searchPanel.Root = projectRoot()
+	searchPanel.Options = files.Options
+	showSearch = true
+	searchPanel.Show(pattern)
+}
+
+// layoutSidebar lays out the file tree or the search panel.
+func layoutSidebar(gtx layout.Context) layout.Dimensions {
+	if showSearch {
+		return searchPanel.Layout(gtx)
+	}
+	return files.Layout(gtx)
+}
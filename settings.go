@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/libs/settings"
+	"github.com/vypal/vedit/ui/commands"
+	"github.com/vypal/vedit/ui/theme"
+)
+
+// config holds the settings that were applied last.
+var config = settings.Default()
+
+var settingsWatcher *settings.Watcher
+
+// reloadSettings is set from the watcher's goroutine when a settings file
+// changed; the settings are read again in the next frame.
+var reloadSettings atomic.Bool
+
+// projectRoot is the directory shown in the file tree, whose settings
+// override the user's.
+func projectRoot() string {
+	if files == nil || files.Root == nil {
+		return ""
+	}
+	return files.Root.FullPath()
+}
+
+// watchSettings applies the settings and reloads them whenever one of the
+// settings files, or the theme they name, is saved.
+func watchSettings(window *app.Window, th *material.Theme) {
+	settingsWatcher = settings.NewWatcher(watchedPaths())
+	applySettings(th)
+	go settingsWatcher.Run(time.Second, func() {
+		reloadSettings.Store(true)
+		window.Invalidate()
+	})
+}
+
+// projectChanged watches the settings of the new project root and searches
+// and indexes it from now on.
+func projectChanged() {
+	if searchPanel != nil {
+		searchPanel.Root = projectRoot()
+	}
+	refreshIndex()
+	if settingsWatcher == nil {
+		return
+	}
+	settingsWatcher.Watch(watchedPaths())
+	reloadSettings.Store(true)
+}
+
+// watchedPaths lists the settings files, the keymap file and the theme.
+func watchedPaths() []string {
+	paths := settings.Paths(projectRoot())
+	if path := settings.KeymapPath(); path != "" {
+		paths = append(paths, path)
+	}
+	if config.Theme != "" {
+		paths = append(paths, config.Theme)
+	}
+	return paths
+}
+
+// applySettings reads the settings files again and applies what changed.
+// Invalid values are logged and left at their default. While a settings
+// file can't be parsed, as it may not while it is being edited, all the
+// settings stay as they were.
+func applySettings(th *material.Theme) {
+	s, err := settings.Load(projectRoot())
+	if err != nil {
+		log.Printf("settings: %v", err)
+	}
+	var parseErr *settings.ParseError
+	if errors.As(err, &parseErr) {
+		s = config
+	}
+	edit.Configure(s)
+	buffers.SetBackup(s.Backup)
+	if s.ShowHidden != config.ShowHidden {
+		files.SetShowHidden(s.ShowHidden)
+		refreshIndex()
+	}
+	switch {
+	case s.Theme != "":
+		// Loaded on every reload, since the theme file itself may be what
+		// changed.
+		loadTheme(s.Theme, th)
+	case config.Theme != "":
+		*colors = *theme.Default()
+		colors.Apply(th)
+	}
+	themeChanged := s.Theme != config.Theme
+	config = s
+	if themeChanged && settingsWatcher != nil {
+		settingsWatcher.Watch(watchedPaths())
+	}
+	applyKeymap()
+}
+
+// applyKeymap binds the keys of the preset picked in the settings and then
+// those of the user's keymap file on top of the defaults, and logs the keys
+// that are bound more than once.
+func applyKeymap() {
+	entries := presets[config.Keybindings]
+	if path := settings.KeymapPath(); path != "" {
+		user, err := commands.LoadKeymap(path)
+		if err != nil {
+			log.Printf("keymap: %v", err)
+		}
+		entries = append(entries[:len(entries):len(entries)], user...)
+	}
+	if err := registry.ApplyKeymap(entries); err != nil {
+		log.Printf("keymap: %v", err)
+	}
+	for _, c := range registry.Conflicts() {
+		log.Printf("keymap: %v", c)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/ui/editor"
+	"github.com/vypal/vedit/ui/widgets"
+)
+
+var buffers = editor.NewBuffers()
+
+var tabBar = &widgets.TabBar{
+	Colors: colors,
+	OnSelect: func(i int) {
+		show(buffers.Documents()[i])
+	},
+	OnClose: func(i int) {
+		closeDocument(buffers.Documents()[i])
+	},
+	OnMove: func(from, to int) {
+		buffers.Move(from, to)
+	},
+}
+
+// show switches the editor to d.
+func show(d *editor.Document) {
+	buffers.Activate(d)
+	edit.SetDocument(d)
+	edit.Focus()
+	noteRecent(d)
+}
+
+// addDocument opens d in a new tab.
+func addDocument(d *editor.Document) {
+	// Replace the empty document the editor starts with.
+	if cur := buffers.Active(); cur != nil && buffers.Len() == 1 && cur.Untitled() && !cur.Dirty() && cur.Buffer.Len() == 0 {
+		buffers.Remove(cur)
+	}
+	buffers.Add(d)
+	edit.SetDocument(d)
+	edit.Focus()
+	noteRecent(d)
+}
+
+func newDocument() {
+	buffers.Add(editor.NewDocument())
+	edit.SetDocument(buffers.Active())
+	edit.Focus()
+}
+
+// closeDocument closes the tab of d, asking first whether to save unsaved
+// changes.
+func closeDocument(d *editor.Document) {
+	if !d.Dirty() {
+		removeDocument(d)
+		return
+	}
+	show(d)
+	prompt.Show(fmt.Sprintf("Save changes to %s? (y/n)", d.Title()), "y", func(answer string) {
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			if !d.HasFile() {
+				prompt.Show("Save as:", promptPath(), func(path string) {
+					if err := d.SaveAs(path); err != nil {
+						log.Printf("saving %s: %v", path, err)
+						return
+					}
+					removeDocument(d)
+				})
+				return
+			}
+			if err := d.Save(); err != nil {
+				log.Printf("saving %s: %v", d.Title(), err)
+				return
+			}
+			removeDocument(d)
+		case "n", "no":
+			removeDocument(d)
+		}
+	})
+}
+
+func removeDocument(d *editor.Document) {
+	buffers.Remove(d)
+	if buffers.Len() == 0 {
+		buffers.Add(editor.NewDocument())
+	}
+	edit.SetDocument(buffers.Active())
+}
+
+// handleTabKeys handles Ctrl+Tab for switching tabs, which isn't a command
+// since the tab is only settled on when Ctrl is released. It has to run
+// before the editor's Layout, which would otherwise take these keys.
+func handleTabKeys(gtx layout.Context) {
+	for {
+		ev, ok := gtx.Event(
+			key.Filter{Name: key.NameTab, Required: key.ModCtrl, Optional: key.ModShift},
+			key.Filter{Name: key.NameCtrl, Optional: key.ModCtrl | key.ModShift},
+		)
+		if !ok {
+			break
+		}
+		ke, ok := ev.(key.Event)
+		if !ok {
+			continue
+		}
+		switch {
+		case ke.Name == key.NameCtrl:
+			// Releasing Ctrl settles on the tab reached with Ctrl+Tab.
+			if ke.State == key.Release {
+				buffers.EndCycle()
+			}
+		case ke.State != key.Press:
+		case ke.Name == key.NameTab:
+			buffers.Cycle(ke.Modifiers.Contain(key.ModShift))
+			edit.SetDocument(buffers.Active())
+		}
+	}
+}
+
+func layoutTabs(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	docs := buffers.Documents()
+	tabBar.Tabs = tabBar.Tabs[:0]
+	for _, d := range docs {
+		tabBar.Tabs = append(tabBar.Tabs, widgets.Tab{Title: d.Title(), Dirty: d.Dirty()})
+	}
+	tabBar.Active = buffers.Index(buffers.Active())
+	return tabBar.Layout(gtx, th)
+}
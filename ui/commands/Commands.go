@@ -0,0 +1,110 @@
+// Package commands keeps the actions of the editor by ID, so that toolbar
+// buttons, menus, key bindings and the command palette all run them the same
+// way.
+package commands
+
+import (
+	"log"
+)
+
+// The contexts in which the keys of a command work.
+const (
+	// Global keys work anywhere in the window, even in text fields.
+	Global = ""
+	// Editor keys only work while the editor has the focus.
+	Editor = "editor"
+	// Explorer keys only work while the file tree has the focus.
+	Explorer = "explorer"
+)
+
+type Command struct {
+	// ID names the command, such as "file.save".
+	ID    string
+	Title string
+	// Keys are the chords bound to the command by default, as ParseChord
+	// reads them.
+	Keys    []string
+	Context string
+	// Enabled reports whether the command can run now. A nil Enabled means
+	// it always can.
+	Enabled func() bool
+	Run     func()
+}
+
+// Registry holds the commands and the keys bound to them.
+type Registry struct {
+	commands map[string]*Command
+	order    []string
+	bindings []Binding
+	// pending holds the keys of a chord typed so far.
+	pending Chord
+}
+
+func NewRegistry() *Registry {
+	return &Registry{commands: map[string]*Command{}}
+}
+
+// Register adds commands and binds their keys. A command replaces the one
+// registered before with the same ID. Keys that can't be read are logged and
+// left out.
+func (r *Registry) Register(cmds ...Command) {
+	for _, c := range cmds {
+		c := c
+		if _, ok := r.commands[c.ID]; !ok {
+			r.order = append(r.order, c.ID)
+		}
+		r.commands[c.ID] = &c
+		r.bindDefaults(c)
+	}
+}
+
+// bindDefaults binds the keys of c, logging those that can't be read.
+func (r *Registry) bindDefaults(c Command) {
+	for _, s := range c.Keys {
+		chord, err := ParseChord(s)
+		if err != nil {
+			log.Printf("command %s: %v", c.ID, err)
+			continue
+		}
+		r.Bind(chord, c.ID)
+	}
+}
+
+// Command returns the command with the given ID.
+func (r *Registry) Command(id string) (Command, bool) {
+	c, ok := r.commands[id]
+	if !ok {
+		return Command{}, false
+	}
+	return *c, true
+}
+
+// Commands returns every command in the order they were registered.
+func (r *Registry) Commands() []Command {
+	cmds := make([]Command, len(r.order))
+	for i, id := range r.order {
+		cmds[i] = *r.commands[id]
+	}
+	return cmds
+}
+
+// Enabled reports whether the command id exists and can run now.
+func (r *Registry) Enabled(id string) bool {
+	c, ok := r.commands[id]
+	return ok && (c.Enabled == nil || c.Enabled())
+}
+
+// Run runs the command id, reporting whether it did. Unknown IDs are
+// logged.
+func (r *Registry) Run(id string) bool {
+	c, ok := r.commands[id]
+	if !ok {
+		log.Printf("unknown command %s", id)
+		return false
+	}
+	if c.Enabled != nil && !c.Enabled() {
+		return false
+	}
+	c.Run()
+	return true
+}
@@ -0,0 +1,294 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"github.com/vypal/vedit/libs"
+)
+
+// Chord is a sequence of keys pressed one after another, such as
+// Ctrl+K Ctrl+C. Most chords are a single key.
+type Chord []Key
+
+// ParseChord reads keys separated by spaces, each as ParseKey reads them.
+func ParseChord(s string) (Chord, error) {
+	var c Chord
+	for _, field := range strings.Fields(s) {
+		k, err := ParseKey(field)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, k)
+	}
+	if len(c) == 0 {
+		return nil, fmt.Errorf("no key in %q", s)
+	}
+	return c, nil
+}
+
+func (c Chord) String() string {
+	keys := make([]string, len(c))
+	for i, k := range c {
+		keys[i] = k.String()
+	}
+	return strings.Join(keys, " ")
+}
+
+// hasPrefix reports whether c starts with the keys of p.
+func (c Chord) hasPrefix(p Chord) bool {
+	if len(p) > len(c) {
+		return false
+	}
+	for i := range p {
+		if c[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Chord) equal(o Chord) bool {
+	return len(c) == len(o) && c.hasPrefix(o)
+}
+
+// Binding binds a chord to a command.
+type Binding struct {
+	Chord   Chord
+	Command string
+	// User is set for bindings read from the user's keymap file.
+	User bool
+}
+
+// Bind binds chord to the command id, in addition to the chords it has.
+func (r *Registry) Bind(chord Chord, id string) {
+	r.bindings = append(r.bindings, Binding{Chord: chord, Command: id})
+}
+
+// Bindings returns every binding, in the order they are tried.
+func (r *Registry) Bindings() []Binding {
+	return append([]Binding(nil), r.bindings...)
+}
+
+// KeysOf returns the chords bound to the command id.
+func (r *Registry) KeysOf(id string) []Chord {
+	var chords []Chord
+	for _, b := range r.bindings {
+		if b.Command == id {
+			chords = append(chords, b.Chord)
+		}
+	}
+	return chords
+}
+
+// context returns the context of the command id.
+func (r *Registry) context(id string) (string, bool) {
+	c, ok := r.commands[id]
+	if !ok {
+		return "", false
+	}
+	return c.Context, true
+}
+
+// isModifier reports whether name is a modifier key, which is pressed on
+// the way to the keys of a chord.
+func isModifier(name key.Name) bool {
+	switch name {
+	case key.NameCtrl, key.NameShift, key.NameAlt, key.NameCommand, key.NameSuper:
+		return true
+	}
+	return false
+}
+
+// HandleKey runs the command of context bound to ev, reporting whether the
+// key was used. A key that starts a longer chord is held until the chord is
+// complete; one that doesn't go on with the chord typed so far drops it.
+// When several commands are bound to the same chord the first one enabled
+// runs.
+func (r *Registry) HandleKey(ev key.Event, context string) bool {
+	if ev.State != key.Press || isModifier(ev.Name) {
+		return false
+	}
+	seq := append(append(Chord(nil), r.pending...), Key{Name: ev.Name, Modifiers: ev.Modifiers})
+	var exact []string
+	for _, b := range r.bindings {
+		if ctx, ok := r.context(b.Command); !ok || ctx != context || !b.Chord.hasPrefix(seq) {
+			continue
+		}
+		if len(b.Chord) > len(seq) {
+			r.pending = seq
+			return true
+		}
+		exact = append(exact, b.Command)
+	}
+	typing := len(r.pending) > 0
+	r.pending = nil
+	for _, id := range exact {
+		if r.Run(id) {
+			return true
+		}
+	}
+	return typing
+}
+
+// Pending reports whether the first keys of a chord have been typed and the
+// next key goes on with it.
+func (r *Registry) Pending() bool {
+	return len(r.pending) > 0
+}
+
+// Filters returns the filters for the keys of the chords of context that
+// can be pressed next, for the widget focus or for any widget when focus is
+// nil.
+func (r *Registry) Filters(context string, focus any) []event.Filter {
+	var filters []event.Filter
+	n := len(r.pending)
+	for _, b := range r.bindings {
+		if ctx, ok := r.context(b.Command); ok && ctx == context && len(b.Chord) > n && b.Chord.hasPrefix(r.pending) {
+			filters = append(filters, b.Chord[n].Filter(focus))
+		}
+	}
+	return filters
+}
+
+// Handle runs the global commands whose keys were pressed. It has to run
+// before the Layout of widgets that would otherwise take these keys.
+func (r *Registry) Handle(gtx layout.Context) {
+	for {
+		filters := r.Filters(Global, nil)
+		if len(filters) == 0 {
+			return
+		}
+		ev, ok := gtx.Event(filters...)
+		if !ok {
+			return
+		}
+		if ke, ok := ev.(key.Event); ok {
+			r.HandleKey(ke, Global)
+		}
+	}
+}
+
+// Conflict is a chord that can't do what its binding says, as another
+// binding takes it first.
+type Conflict struct {
+	// Binding is shadowed by Other, which is bound to the same chord or to
+	// the start of it.
+	Binding Binding
+	Other   Binding
+}
+
+func (c Conflict) String() string {
+	if c.Binding.Chord.equal(c.Other.Chord) {
+		return fmt.Sprintf("%s is bound to both %s and %s", c.Binding.Chord, c.Other.Command, c.Binding.Command)
+	}
+	if len(c.Binding.Chord) < len(c.Other.Chord) {
+		return fmt.Sprintf("%s of %s is the start of %s of %s", c.Binding.Chord, c.Binding.Command, c.Other.Chord, c.Other.Command)
+	}
+	return fmt.Sprintf("%s of %s starts with %s of %s", c.Binding.Chord, c.Binding.Command, c.Other.Chord, c.Other.Command)
+}
+
+// Conflicts lists the bindings that are shadowed by others: those whose
+// chord is also bound to another command, or starts with or is the start
+// of another chord, in the same context or a global one.
+func (r *Registry) Conflicts() []Conflict {
+	var conflicts []Conflict
+	for i, a := range r.bindings {
+		actx, _ := r.context(a.Command)
+		for _, b := range r.bindings[i+1:] {
+			bctx, _ := r.context(b.Command)
+			if a.Command == b.Command || (actx != bctx && actx != Global && bctx != Global) {
+				continue
+			}
+			if !a.Chord.hasPrefix(b.Chord) && !b.Chord.hasPrefix(a.Chord) {
+				continue
+			}
+			// Global keys are handled first. Otherwise a chord that goes on
+			// wins over the start of it, and the earlier binding over a later
+			// one of the same chord.
+			c := Conflict{Binding: b, Other: a}
+			switch {
+			case actx != bctx:
+				if actx != Global {
+					c = Conflict{Binding: a, Other: b}
+				}
+			case len(a.Chord) < len(b.Chord):
+				c = Conflict{Binding: a, Other: b}
+			}
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts
+}
+
+// KeymapEntry is a binding from a keymap file. A Command starting with "-"
+// removes the binding of Key to the rest of it instead, or all of the
+// command's bindings when Key is empty.
+type KeymapEntry struct {
+	Key     string `json:"key"`
+	Command string `json:"command"`
+}
+
+// LoadKeymap reads the keymap file at path, a JSON array of entries such as
+// {"key": "Ctrl+K Ctrl+C", "command": "edit.copy"}. Comments are allowed. A
+// file that doesn't exist is an empty keymap.
+func LoadKeymap(path string) ([]KeymapEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []KeymapEntry
+	if err := json.Unmarshal(libs.StripJSONC(data), &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// ApplyKeymap replaces the bindings with the default chords of the commands
+// changed by entries. Entries that can't be applied are reported in the
+// error and skipped.
+func (r *Registry) ApplyKeymap(entries []KeymapEntry) error {
+	r.bindings, r.pending = nil, nil
+	for _, id := range r.order {
+		r.bindDefaults(*r.commands[id])
+	}
+	var errs []error
+	for _, e := range entries {
+		id, remove := strings.CutPrefix(e.Command, "-")
+		if _, ok := r.commands[id]; !ok {
+			errs = append(errs, fmt.Errorf("unknown command %q", id))
+			continue
+		}
+		var chord Chord
+		if e.Key != "" || !remove {
+			var err error
+			if chord, err = ParseChord(e.Key); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", id, err))
+				continue
+			}
+		}
+		if !remove {
+			// The user's bindings go first, so that they win over the
+			// defaults and later entries over earlier ones.
+			r.bindings = append([]Binding{{Chord: chord, Command: id, User: true}}, r.bindings...)
+			continue
+		}
+		kept := r.bindings[:0]
+		for _, b := range r.bindings {
+			if b.Command != id || (chord != nil && !b.Chord.equal(chord)) {
+				kept = append(kept, b)
+			}
+		}
+		r.bindings = kept
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,217 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gioui.org/io/key"
+)
+
+func press(t *testing.T, r *Registry, s, context string) bool {
+	t.Helper()
+	k, err := ParseKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.HandleKey(key.Event{Name: k.Name, Modifiers: k.Modifiers, State: key.Press}, context)
+}
+
+// newTestRegistry registers commands that record their ID in ran when they
+// run.
+func newTestRegistry(ran *[]string, cmds ...Command) *Registry {
+	r := NewRegistry()
+	for _, c := range cmds {
+		id := c.ID
+		c.Run = func() { *ran = append(*ran, id) }
+		r.Register(c)
+	}
+	return r
+}
+
+func TestParseChord(t *testing.T) {
+	c, err := ParseChord("Ctrl+K  Ctrl+C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c) != 2 || c.String() != "Ctrl+K Ctrl+C" {
+		t.Errorf("ParseChord = %v", c)
+	}
+	for _, in := range []string{"", "  ", "Ctrl+K Bogus+C"} {
+		if _, err := ParseChord(in); err == nil {
+			t.Errorf("ParseChord(%q) didn't fail", in)
+		}
+	}
+}
+
+func TestHandleChord(t *testing.T) {
+	var ran []string
+	r := newTestRegistry(&ran,
+		Command{ID: "edit.comment", Keys: []string{"Ctrl+K Ctrl+C"}, Context: Editor},
+		Command{ID: "edit.cut", Keys: []string{"Ctrl+X"}, Context: Editor},
+	)
+	if !press(t, r, "Ctrl+K", Editor) || !r.Pending() {
+		t.Fatal("the first key of a chord wasn't held")
+	}
+	press(t, r, "Ctrl+C", Editor)
+	if strings.Join(ran, " ") != "edit.comment" || r.Pending() {
+		t.Errorf("ran %v, pending %v, want edit.comment", ran, r.Pending())
+	}
+
+	// A key that doesn't go on with the chord drops it, and is used up.
+	ran = nil
+	press(t, r, "Ctrl+K", Editor)
+	if !press(t, r, "Ctrl+X", Editor) || len(ran) != 0 || r.Pending() {
+		t.Errorf("broken chord ran %v", ran)
+	}
+
+	// Keys only work in their context.
+	if press(t, r, "Ctrl+X", Explorer) || len(ran) != 0 {
+		t.Error("an editor key worked in the file tree")
+	}
+	// Modifiers alone don't break a chord.
+	press(t, r, "Ctrl+K", Editor)
+	r.HandleKey(key.Event{Name: key.NameCtrl, Modifiers: key.ModCtrl, State: key.Press}, Editor)
+	press(t, r, "Ctrl+C", Editor)
+	if strings.Join(ran, " ") != "edit.comment" {
+		t.Errorf("chord with a modifier press in between ran %v", ran)
+	}
+}
+
+func TestHandleKeyFirstEnabled(t *testing.T) {
+	var ran []string
+	findNext := false
+	r := newTestRegistry(&ran,
+		Command{ID: "find.next", Keys: []string{"Enter"}, Context: Editor, Enabled: func() bool { return findNext }},
+		Command{ID: "edit.newline", Keys: []string{"Enter"}, Context: Editor},
+	)
+	press(t, r, "Enter", Editor)
+	findNext = true
+	press(t, r, "Enter", Editor)
+	if strings.Join(ran, " ") != "edit.newline find.next" {
+		t.Errorf("ran %v, want the first enabled command each time", ran)
+	}
+}
+
+func TestApplyKeymap(t *testing.T) {
+	var ran []string
+	r := newTestRegistry(&ran,
+		Command{ID: "file.save", Keys: []string{"Ctrl+S"}},
+		Command{ID: "edit.copy", Keys: []string{"Ctrl+C", "Ctrl+Insert"}, Context: Editor},
+		Command{ID: "edit.paste", Keys: []string{"Ctrl+V"}, Context: Editor},
+	)
+	err := r.ApplyKeymap([]KeymapEntry{
+		{Key: "Ctrl+Alt+S", Command: "file.save"},
+		{Key: "Ctrl+Insert", Command: "-edit.copy"},
+		{Command: "-edit.paste"},
+		{Key: "Ctrl+V", Command: "no.such"},
+		{Key: "Ctrl+Bogus+V", Command: "edit.paste"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "no.such") || !strings.Contains(err.Error(), "Bogus") {
+		t.Errorf("ApplyKeymap error = %v, want the bad entries reported", err)
+	}
+	keys := func(id string) string {
+		var s []string
+		for _, c := range r.KeysOf(id) {
+			s = append(s, c.String())
+		}
+		return strings.Join(s, ", ")
+	}
+	if got := keys("file.save"); got != "Ctrl+Alt+S, Ctrl+S" {
+		t.Errorf("file.save keys = %q", got)
+	}
+	if got := keys("edit.copy"); got != "Ctrl+C" {
+		t.Errorf("edit.copy keys = %q", got)
+	}
+	if got := keys("edit.paste"); got != "" {
+		t.Errorf("edit.paste keys = %q, want none", got)
+	}
+
+	// Applying again starts over from the defaults.
+	if err := r.ApplyKeymap(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := keys("edit.paste"); got != "Ctrl+V" {
+		t.Errorf("edit.paste keys after reset = %q", got)
+	}
+}
+
+func TestUserBindingWins(t *testing.T) {
+	var ran []string
+	r := newTestRegistry(&ran,
+		Command{ID: "edit.copy", Keys: []string{"Ctrl+C"}, Context: Editor},
+		Command{ID: "edit.comment", Context: Editor},
+	)
+	if err := r.ApplyKeymap([]KeymapEntry{{Key: "Ctrl+C", Command: "edit.comment"}}); err != nil {
+		t.Fatal(err)
+	}
+	press(t, r, "Ctrl+C", Editor)
+	if strings.Join(ran, " ") != "edit.comment" {
+		t.Errorf("ran %v, want the user's binding", ran)
+	}
+}
+
+func TestLoadKeymap(t *testing.T) {
+	dir := t.TempDir()
+	entries, err := LoadKeymap(filepath.Join(dir, "missing.json"))
+	if err != nil || entries != nil {
+		t.Errorf("missing keymap = %v, %v, want nothing", entries, err)
+	}
+
+	path := filepath.Join(dir, "keymap.json")
+	os.WriteFile(path, []byte(`[
+		// Comment out lines.
+		{"key": "Ctrl+K Ctrl+C", "command": "edit.comment"},
+		{"command": "-edit.paste"},
+	]`), 0o644)
+	entries, err = LoadKeymap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0] != (KeymapEntry{Key: "Ctrl+K Ctrl+C", Command: "edit.comment"}) ||
+		entries[1] != (KeymapEntry{Command: "-edit.paste"}) {
+		t.Errorf("LoadKeymap = %+v", entries)
+	}
+
+	os.WriteFile(path, []byte(`{"key": "Ctrl+S"}`), 0o644)
+	if _, err := LoadKeymap(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("LoadKeymap of a non-array = %v, want an error naming the file", err)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	var ran []string
+	r := newTestRegistry(&ran,
+		Command{ID: "edit.copy", Keys: []string{"Ctrl+C"}, Context: Editor},
+		Command{ID: "edit.comment", Keys: []string{"Ctrl+K Ctrl+C"}, Context: Editor},
+		Command{ID: "edit.kill", Keys: []string{"Ctrl+K"}, Context: Editor},
+		Command{ID: "tree.copy", Keys: []string{"Ctrl+C"}, Context: Explorer},
+		Command{ID: "file.save", Keys: []string{"Ctrl+S"}},
+		Command{ID: "edit.save", Keys: []string{"Ctrl+S"}, Context: Editor},
+		Command{ID: "edit.copy2", Keys: []string{"Ctrl+C"}, Context: Editor},
+	)
+	var got []string
+	for _, c := range r.Conflicts() {
+		got = append(got, c.String())
+	}
+	// Different contexts other than the global one don't conflict, so
+	// tree.copy is fine.
+	want := []string{
+		"Ctrl+C is bound to both edit.copy and edit.copy2",
+		"Ctrl+K of edit.kill is the start of Ctrl+K Ctrl+C of edit.comment",
+		"Ctrl+S is bound to both file.save and edit.save",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Conflicts() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A global key shadows the longer chord of a context it starts.
+	r = newTestRegistry(&ran,
+		Command{ID: "view.palette", Keys: []string{"Ctrl+K"}},
+		Command{ID: "edit.comment", Keys: []string{"Ctrl+K Ctrl+C"}, Context: Editor},
+	)
+	if c := r.Conflicts(); len(c) != 1 || c[0].String() != "Ctrl+K Ctrl+C of edit.comment starts with Ctrl+K of view.palette" {
+		t.Errorf("Conflicts() = %v", c)
+	}
+}
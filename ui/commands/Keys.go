@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"gioui.org/io/key"
+)
+
+// Key is a key pressed with modifiers, such as Ctrl+Shift+P.
+type Key struct {
+	Name      key.Name
+	Modifiers key.Modifiers
+}
+
+// keyNames are the names accepted for keys whose key.Name is a symbol.
+var keyNames = map[string]key.Name{
+	"enter":     key.NameReturn,
+	"return":    key.NameReturn,
+	"esc":       key.NameEscape,
+	"escape":    key.NameEscape,
+	"tab":       key.NameTab,
+	"space":     key.NameSpace,
+	"backspace": key.NameDeleteBackward,
+	"delete":    key.NameDeleteForward,
+	"left":      key.NameLeftArrow,
+	"right":     key.NameRightArrow,
+	"up":        key.NameUpArrow,
+	"down":      key.NameDownArrow,
+	"home":      key.NameHome,
+	"end":       key.NameEnd,
+	"pageup":    key.NamePageUp,
+	"pagedown":  key.NamePageDown,
+}
+
+// displayNames are how keys named with symbols are written.
+var displayNames = map[key.Name]string{
+	key.NameReturn:         "Enter",
+	key.NameEscape:         "Esc",
+	key.NameDeleteBackward: "Backspace",
+	key.NameDeleteForward:  "Delete",
+	key.NameLeftArrow:      "Left",
+	key.NameRightArrow:     "Right",
+	key.NameUpArrow:        "Up",
+	key.NameDownArrow:      "Down",
+	key.NameHome:           "Home",
+	key.NameEnd:            "End",
+	key.NamePageUp:         "PageUp",
+	key.NamePageDown:       "PageDown",
+}
+
+// ParseKey reads a key written as modifiers and a key name joined by "+",
+// such as "Ctrl+Shift+P" or "Shift+F3". Ctrl stands for the platform's
+// shortcut modifier, which is Command on macOS.
+func ParseKey(s string) (Key, error) {
+	parts := strings.Split(strings.TrimSpace(s), "+")
+	// "Ctrl++" binds the plus key.
+	if strings.HasSuffix(s, "++") {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	var k Key
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if i < len(parts)-1 {
+			switch strings.ToLower(part) {
+			case "ctrl", "mod":
+				k.Modifiers |= key.ModShortcut
+			case "control":
+				// The Control key itself, where it isn't the shortcut
+				// modifier.
+				k.Modifiers |= key.ModCtrl
+			case "shift":
+				k.Modifiers |= key.ModShift
+			case "alt":
+				k.Modifiers |= key.ModAlt
+			case "cmd", "command":
+				k.Modifiers |= key.ModCommand
+			case "super":
+				k.Modifiers |= key.ModSuper
+			default:
+				return Key{}, fmt.Errorf("unknown modifier %q in %q", part, s)
+			}
+			continue
+		}
+		switch name, ok := keyNames[strings.ToLower(part)]; {
+		case ok:
+			k.Name = name
+		case part == "":
+			return Key{}, fmt.Errorf("no key in %q", s)
+		default:
+			k.Name = key.Name(strings.ToUpper(part))
+		}
+	}
+	return k, nil
+}
+
+// Matches reports whether ev is a press of k.
+func (k Key) Matches(ev key.Event) bool {
+	return ev.State == key.Press && ev.Name == k.Name && ev.Modifiers == k.Modifiers
+}
+
+// Filter returns the filter for k, for the widget focus or for any widget
+// when focus is nil.
+func (k Key) Filter(focus any) key.Filter {
+	return key.Filter{Focus: focus, Name: k.Name, Required: k.Modifiers}
+}
+
+func (k Key) String() string {
+	var b strings.Builder
+	mods := k.Modifiers
+	// Ctrl reads back as the shortcut modifier, as ParseKey takes it.
+	if mods.Contain(key.ModShortcut) {
+		b.WriteString("Ctrl+")
+		mods &^= key.ModShortcut
+	}
+	for _, m := range []struct {
+		mod  key.Modifiers
+		name string
+	}{
+		{key.ModCtrl, "Control"},
+		{key.ModCommand, "Cmd"},
+		{key.ModSuper, "Super"},
+		{key.ModAlt, "Alt"},
+		{key.ModShift, "Shift"},
+	} {
+		if mods.Contain(m.mod) {
+			b.WriteString(m.name + "+")
+		}
+	}
+	if name, ok := displayNames[k.Name]; ok {
+		b.WriteString(name)
+	} else {
+		b.WriteString(string(k.Name))
+	}
+	return b.String()
+}
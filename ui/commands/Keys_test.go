@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"testing"
+
+	"gioui.org/io/key"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Key
+		str  string
+	}{
+		{"Ctrl+Shift+P", Key{Name: "P", Modifiers: key.ModShortcut | key.ModShift}, "Ctrl+Shift+P"},
+		{"shift+f3", Key{Name: "F3", Modifiers: key.ModShift}, "Shift+F3"},
+		{"Enter", Key{Name: key.NameReturn}, "Enter"},
+		{"Alt+Left", Key{Name: key.NameLeftArrow, Modifiers: key.ModAlt}, "Alt+Left"},
+		{"Ctrl++", Key{Name: "+", Modifiers: key.ModShortcut}, "Ctrl++"},
+		{" Cmd + s ", Key{Name: "S", Modifiers: key.ModCommand}, "Cmd+S"},
+	}
+	for _, tt := range tests {
+		got, err := ParseKey(tt.in)
+		if err != nil {
+			t.Errorf("ParseKey(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseKey(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if s := got.String(); s != tt.str {
+			t.Errorf("ParseKey(%q).String() = %q, want %q", tt.in, s, tt.str)
+		}
+	}
+
+	// Control is the Control key even where Ctrl means Command, and reads
+	// back as whichever of the two it is.
+	k, err := ParseKey("Control+A")
+	if err != nil || k.Modifiers != key.ModCtrl {
+		t.Errorf("ParseKey(\"Control+A\") = %+v, %v, want the Control modifier", k, err)
+	}
+	if back, _ := ParseKey(k.String()); back != k {
+		t.Errorf("%q reads back as %+v, want %+v", k.String(), back, k)
+	}
+
+	for _, in := range []string{"Hyper+A", "Ctrl+", ""} {
+		if _, err := ParseKey(in); err == nil {
+			t.Errorf("ParseKey(%q) didn't fail", in)
+		}
+	}
+}
+
+func TestKeyMatches(t *testing.T) {
+	k, _ := ParseKey("Ctrl+S")
+	press := key.Event{Name: "S", Modifiers: key.ModShortcut, State: key.Press}
+	if !k.Matches(press) {
+		t.Error("Ctrl+S doesn't match its press")
+	}
+	release := press
+	release.State = key.Release
+	if k.Matches(release) {
+		t.Error("Ctrl+S matches its release")
+	}
+	extra := press
+	extra.Modifiers |= key.ModShift
+	if k.Matches(extra) {
+		t.Error("Ctrl+S matches Ctrl+Shift+S")
+	}
+}
+
+func TestRegistryRun(t *testing.T) {
+	r := NewRegistry()
+	ran, enabled := 0, false
+	r.Register(Command{ID: "a.run", Enabled: func() bool { return enabled }, Run: func() { ran++ }})
+	if r.Run("a.run") || ran != 0 {
+		t.Error("a disabled command ran")
+	}
+	enabled = true
+	if !r.Run("a.run") || ran != 1 {
+		t.Error("an enabled command didn't run")
+	}
+	if r.Run("missing") {
+		t.Error("an unknown command ran")
+	}
+
+	// Registering the same ID again replaces the command in place.
+	r.Register(Command{ID: "b.run"}, Command{ID: "a.run", Title: "Again"})
+	cmds := r.Commands()
+	if len(cmds) != 2 || cmds[0].ID != "a.run" || cmds[0].Title != "Again" {
+		t.Errorf("Commands() = %+v", cmds)
+	}
+}
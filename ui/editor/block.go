@@ -0,0 +1,291 @@
+package editor
+
+import (
+	"image"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/widget/material"
+)
+
+// block is a rectangular selection: the visual columns from that of the
+// anchor to that of the caret, on every line between theirs. Columns count
+// tabs as wide as they are drawn, and go on past the end of short lines.
+//
+// The block is made into one caret on each line. It lasts while the carets
+// and the text are as it left them; once they change some other way the
+// carets go on as they are.
+type block struct {
+	anchorLine, anchorCol int
+	cursorLine, cursorCol int
+
+	doc            *Document
+	version        int
+	cursor, anchor int
+	carets         []caret
+}
+
+func (b *block) top() int    { return min(b.anchorLine, b.cursorLine) }
+func (b *block) bottom() int { return max(b.anchorLine, b.cursorLine) }
+func (b *block) left() int   { return min(b.anchorCol, b.cursorCol) }
+func (b *block) right() int  { return max(b.anchorCol, b.cursorCol) }
+
+// activeBlock returns the block selection, or nil if there is none.
+func (e *Editor) activeBlock() *block {
+	b := e.block
+	if b == nil || b.doc != e.doc || b.version != e.doc.version ||
+		b.cursor != e.cursor || b.anchor != e.anchor || !slices.Equal(b.carets, e.carets) {
+		return nil
+	}
+	return b
+}
+
+// setBlock selects the block from column anchorCol of anchorLine to column
+// cursorCol of cursorLine, with the main caret on cursorLine.
+func (e *Editor) setBlock(anchorLine, anchorCol, cursorLine, cursorCol int) {
+	b := &block{anchorLine: anchorLine, anchorCol: anchorCol, cursorLine: cursorLine, cursorCol: cursorCol}
+	e.carets = nil
+	for line := b.top(); line <= b.bottom(); line++ {
+		start, _ := e.columnOffset(line, b.left())
+		end, _ := e.columnOffset(line, b.right())
+		c := caret{cursor: end, anchor: start}
+		if cursorCol < anchorCol {
+			c = caret{cursor: start, anchor: end}
+		}
+		if line == cursorLine {
+			e.cursor, e.anchor = c.cursor, c.anchor
+		} else {
+			e.carets = append(e.carets, c)
+		}
+	}
+	b.doc, b.version = e.doc, e.doc.version
+	b.cursor, b.anchor = e.cursor, e.anchor
+	b.carets = slices.Clone(e.carets)
+	e.block = b
+	e.history.seal()
+	e.adjustScrollOffset()
+}
+
+// moveBlock moves the caret corner of the block selection by lines and
+// cols, starting one at the main caret if there is none. It may go past the
+// end of the line.
+func (e *Editor) moveBlock(lines, cols int) {
+	b := e.activeBlock()
+	if b == nil {
+		line, col := e.getCursorPosition()
+		vcol := e.visualWidth(e.buf.Slice(e.cursor-col, e.cursor))
+		b = &block{anchorLine: line, anchorCol: vcol, cursorLine: line, cursorCol: vcol}
+	}
+	line := max(0, min(b.cursorLine+lines, e.buf.LineCount()-1))
+	e.setBlock(b.anchorLine, b.anchorCol, line, max(0, b.cursorCol+cols))
+}
+
+// visualWidth returns the number of columns s takes up when drawn.
+func (e *Editor) visualWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		if r == '\t' {
+			n += e.tabWidth
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// columnOffset returns the offset of the rune drawn at visual column col of
+// line, or of the tab that spans it, and how many columns the line ends
+// short of col.
+func (e *Editor) columnOffset(line, col int) (pos, short int) {
+	pos = e.buf.LineStart(line)
+	vcol := 0
+	for _, r := range e.buf.Line(line) {
+		w := 1
+		if r == '\t' {
+			w = e.tabWidth
+		}
+		if vcol+w > col {
+			return pos, 0
+		}
+		vcol += w
+		pos++
+	}
+	return pos, col - vcol
+}
+
+// forceColumn makes visual column col of line start a rune, as Emacs does
+// for rectangles: a tab that spans it is turned into spaces, and a line
+// that ends short of it is padded with spaces. It returns the offset of
+// the column.
+func (e *Editor) forceColumn(line, col int) int {
+	pos, short := e.columnOffset(line, col)
+	if short > 0 {
+		e.replace(pos, pos, strings.Repeat(" ", short), editOther)
+		return pos + short
+	}
+	if pos < e.buf.LineEnd(line) && e.buf.RuneAt(pos) == '\t' {
+		start := e.visualWidth(e.buf.Slice(e.buf.LineStart(line), pos))
+		if start < col {
+			e.replace(pos, pos+1, strings.Repeat(" ", e.tabWidth), editOther)
+			return pos + col - start
+		}
+	}
+	return pos
+}
+
+// replaceColumns puts text in place of the visual columns from from to to
+// of line and returns the offset after it. A line that ends before from is
+// padded up to it, unless there is no text to put there.
+func (e *Editor) replaceColumns(line, from, to int, text string) int {
+	width := e.visualWidth(e.buf.Line(line))
+	if text == "" && (width <= from || to <= from) {
+		pos, _ := e.columnOffset(line, from)
+		return pos
+	}
+	start := e.forceColumn(line, from)
+	end := start
+	if to > from && width > from {
+		end = e.forceColumn(line, min(to, width))
+	}
+	e.replace(start, end, text, editOther)
+	return start + utf8.RuneCountInString(text)
+}
+
+// blockReplace puts text in place of the block selection on each of its
+// lines, leaving an empty block after it. It reports whether there was a
+// block to do so; text that breaks lines is left to the carets.
+func (e *Editor) blockReplace(text string) bool {
+	b := e.activeBlock()
+	if b == nil || strings.Contains(text, "\n") {
+		return false
+	}
+	e.BeginTransaction()
+	for line := b.top(); line <= b.bottom(); line++ {
+		e.replaceColumns(line, b.left(), b.right(), text)
+	}
+	col := b.left() + e.visualWidth(text)
+	e.setBlock(b.anchorLine, col, b.cursorLine, col)
+	e.EndTransaction()
+	return true
+}
+
+// blockDelete deletes the block selection on each of its lines, or the
+// column before or after an empty one, and reports whether there was a
+// block to do so.
+func (e *Editor) blockDelete(backward bool) bool {
+	b := e.activeBlock()
+	if b == nil {
+		return false
+	}
+	left, right := b.left(), b.right()
+	switch {
+	case left < right:
+	case backward && left == 0:
+		return true
+	case backward:
+		left--
+	default:
+		right++
+	}
+	e.BeginTransaction()
+	for line := b.top(); line <= b.bottom(); line++ {
+		e.replaceColumns(line, left, right, "")
+	}
+	e.setBlock(b.anchorLine, left, b.cursorLine, left)
+	e.EndTransaction()
+	return true
+}
+
+// blockPaste puts the lines of text in place of the block selection, one
+// on each of its lines, and leaves a caret after each.
+func (e *Editor) blockPaste(lines []string) {
+	b := e.activeBlock()
+	e.BeginTransaction()
+	var ends []int
+	for i, text := range lines {
+		ends = append(ends, e.replaceColumns(b.top()+i, b.left(), b.right(), text))
+	}
+	e.carets = nil
+	for i, pos := range ends {
+		if b.top()+i == b.cursorLine {
+			e.cursor, e.anchor = pos, pos
+		} else {
+			e.carets = append(e.carets, caret{pos, pos})
+		}
+	}
+	e.EndTransaction()
+	e.adjustScrollOffset()
+}
+
+// visualColumnAt returns the visual column of line whose caret position is
+// closest to x, measured from the start of the text. Past the end of the
+// line the columns go on as wide as spaces.
+func (e *Editor) visualColumnAt(gtx layout.Context, th *material.Theme, line, x int) int {
+	text := e.buf.Line(line)
+	runes := []rune(text)
+	col := e.columnAt(gtx, th, text, x)
+	vcol := e.visualWidth(string(runes[:col]))
+	if col < len(runes) {
+		return vcol
+	}
+	space := measureTextWidth(gtx, th, " ", e.fontSize)
+	if past := x - measureTextWidth(gtx, th, e.expandTabs(text), e.fontSize); past > 0 && space > 0 {
+		vcol += (past + space/2) / space
+	}
+	return vcol
+}
+
+// blockPoint returns the line and visual column nearest to pt in editor
+// coordinates.
+func (e *Editor) blockPoint(gtx layout.Context, th *material.Theme, pt f32.Point) (int, int) {
+	line := e.scrollOffset + int(pt.Y)/e.linePx
+	line = max(0, min(line, e.buf.LineCount()-1))
+	return line, e.visualColumnAt(gtx, th, line, int(pt.X)-e.contentOffset+e.scrollX)
+}
+
+// columnX returns where visual column col of line is drawn, measured from
+// the start of the text.
+func (e *Editor) columnX(gtx layout.Context, th *material.Theme, line, col int) int {
+	expanded := []rune(e.expandTabs(e.buf.Line(line)))
+	if col <= len(expanded) {
+		return measureTextWidth(gtx, th, string(expanded[:col]), e.fontSize)
+	}
+	return measureTextWidth(gtx, th, string(expanded)+strings.Repeat(" ", col-len(expanded)), e.fontSize)
+}
+
+// drawBlockLine paints the part of the block selection on lineNum, past the
+// end of the line too.
+func (e *Editor) drawBlockLine(gtx layout.Context, th *material.Theme, b *block, lineNum, xOffset int) {
+	if lineNum < b.top() || lineNum > b.bottom() || b.left() == b.right() {
+		return
+	}
+	y := (lineNum - e.scrollOffset) * e.linePx
+	paint.FillShape(gtx.Ops,
+		e.Colors.Selection,
+		clip.Rect{
+			Min: image.Point{X: xOffset + e.columnX(gtx, th, lineNum, b.left()), Y: y},
+			Max: image.Point{X: xOffset + e.columnX(gtx, th, lineNum, b.right()), Y: y + e.linePx},
+		}.Op(),
+	)
+}
+
+// drawBlockCarets draws a caret in the caret column of the block selection
+// on each of its lines.
+func (e *Editor) drawBlockCarets(gtx layout.Context, th *material.Theme, b *block, xOffset float32) {
+	for line := max(b.top(), e.scrollOffset); line <= min(b.bottom(), e.scrollOffset+e.visibleLines); line++ {
+		x := int(xOffset) + e.columnX(gtx, th, line, b.cursorCol)
+		y := (line - e.scrollOffset) * e.linePx
+		paint.FillShape(gtx.Ops,
+			e.Colors.Caret,
+			clip.Rect{
+				Min: image.Point{X: x, Y: y},
+				Max: image.Point{X: x + 2, Y: y + e.linePx},
+			}.Op(),
+		)
+	}
+}
@@ -0,0 +1,91 @@
+package editor
+
+import "testing"
+
+// newBlockEditor returns an editor holding text, with tabs four columns
+// wide, and the block from column fromCol of fromLine to column toCol of
+// toLine selected.
+func newBlockEditor(t *testing.T, text string, fromLine, fromCol, toLine, toCol int) *Editor {
+	t.Helper()
+	e := newTestEditor(t, text)
+	e.tabWidth = 4
+	e.setBlock(fromLine, fromCol, toLine, toCol)
+	if e.activeBlock() == nil {
+		t.Fatal("no block selection")
+	}
+	return e
+}
+
+// copied returns what the last copy put on the clipboard.
+func copied(e *Editor) string {
+	return e.kills.at(0)
+}
+
+func TestBlockTyping(t *testing.T) {
+	e := newBlockEditor(t, "abcd\nabcd\nabcd", 0, 1, 2, 3)
+	typeText(e, "x")
+	checkText(t, e, "axd\naxd\naxd")
+	e.Undo()
+	checkText(t, e, "abcd\nabcd\nabcd")
+}
+
+func TestBlockSplitsTab(t *testing.T) {
+	e := newBlockEditor(t, "\tx\n\tx", 0, 2, 1, 2)
+	typeText(e, "y")
+	checkText(t, e, "  y  x\n  y  x")
+}
+
+func TestBlockKeepsTabAtItsStart(t *testing.T) {
+	e := newBlockEditor(t, "\tx\n\tx", 0, 0, 1, 0)
+	typeText(e, "y")
+	checkText(t, e, "y\tx\ny\tx")
+}
+
+func TestBlockPadsShortLines(t *testing.T) {
+	e := newBlockEditor(t, "abcdef\nab\nabcdef", 0, 4, 2, 4)
+	typeText(e, "X")
+	checkText(t, e, "abcdXef\nab  X\nabcdXef")
+}
+
+func TestBlockDeleteLeavesShortLines(t *testing.T) {
+	e := newBlockEditor(t, "abcdef\nab\nabcdef", 0, 3, 2, 5)
+	e.blockDelete(false)
+	checkText(t, e, "abcf\nab\nabcf")
+}
+
+func TestBlockCopy(t *testing.T) {
+	e := newBlockEditor(t, "abcd\nab\nabcd", 0, 1, 2, 3)
+	e.Copy()
+	if got, want := copied(e), "bc\nb\nbc"; got != want {
+		t.Errorf("copied %q, want %q", got, want)
+	}
+}
+
+func TestBlockCopyAfterTyping(t *testing.T) {
+	e := newBlockEditor(t, "abcd\nabcd", 0, 1, 1, 3)
+	typeText(e, "x")
+	e.Copy()
+	if got := copied(e); got != "" {
+		t.Errorf("copying an empty block copied %q", got)
+	}
+}
+
+func TestBlockColumnPaste(t *testing.T) {
+	e := newBlockEditor(t, "abcd\nab\nabcd", 0, 3, 2, 3)
+	e.yank("1\n2\n3\n", 0)
+	checkText(t, e, "abc1d\nab 2\nabc3d")
+	if start, end := e.Selection(); start != 15 || end != 15 {
+		t.Errorf("caret after paste = %d, %d, want 15, 15", start, end)
+	}
+	if len(e.carets) != 2 {
+		t.Errorf("%d carets after paste, want 3", len(e.carets)+1)
+	}
+	e.Undo()
+	checkText(t, e, "abcd\nab\nabcd")
+}
+
+func TestBlockPasteOtherText(t *testing.T) {
+	e := newBlockEditor(t, "abcd\nabcd", 0, 1, 1, 3)
+	e.yank("xyz", 0)
+	checkText(t, e, "axyzd\naxyzd")
+}
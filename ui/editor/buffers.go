@@ -0,0 +1,131 @@
+package editor
+
+// Buffers holds the open documents in tab order, and the order in which they
+// were last used for switching with Ctrl+Tab.
+type Buffers struct {
+	docs []*Document
+	// recent starts with the active document.
+	recent []*Document
+	// cycle is the position in recent while Ctrl+Tab is held, or -1.
+	cycle int
+	// backup is whether the documents keep a backup when saved.
+	backup bool
+}
+
+func NewBuffers() *Buffers {
+	return &Buffers{cycle: -1}
+}
+
+func (b *Buffers) Documents() []*Document {
+	return b.docs
+}
+
+func (b *Buffers) Len() int {
+	return len(b.docs)
+}
+
+// Active returns the document being edited, or nil when there are none.
+func (b *Buffers) Active() *Document {
+	if b.cycle >= 0 {
+		return b.recent[b.cycle]
+	}
+	if len(b.recent) == 0 {
+		return nil
+	}
+	return b.recent[0]
+}
+
+func (b *Buffers) Index(d *Document) int {
+	for i, doc := range b.docs {
+		if doc == d {
+			return i
+		}
+	}
+	return -1
+}
+
+// Find returns the open document of the file at path.
+func (b *Buffers) Find(path string) *Document {
+	for _, d := range b.docs {
+		if d.HasFile() && d.File.FullPath() == path {
+			return d
+		}
+	}
+	return nil
+}
+
+// SetBackup sets whether saving the documents, and those added later, keeps
+// the previous version of their file as a backup.
+func (b *Buffers) SetBackup(on bool) {
+	b.backup = on
+	for _, d := range b.docs {
+		d.File.Backup = on
+	}
+}
+
+// Add opens d in a new tab after the active one and activates it.
+func (b *Buffers) Add(d *Document) {
+	d.File.Backup = b.backup
+	i := b.Index(b.Active()) + 1
+	if i == 0 {
+		i = len(b.docs)
+	}
+	b.docs = append(b.docs[:i], append([]*Document{d}, b.docs[i:]...)...)
+	b.Activate(d)
+}
+
+// Activate makes d the active document and the most recently used one.
+func (b *Buffers) Activate(d *Document) {
+	b.cycle = -1
+	b.recent = append([]*Document{d}, removeDocument(b.recent, d)...)
+}
+
+// Remove closes the tab of d. The previously used document becomes active.
+func (b *Buffers) Remove(d *Document) {
+	b.cycle = -1
+	b.docs = removeDocument(b.docs, d)
+	b.recent = removeDocument(b.recent, d)
+}
+
+// Move puts the tab at from at index to.
+func (b *Buffers) Move(from, to int) {
+	if from == to || from < 0 || to < 0 || from >= len(b.docs) || to >= len(b.docs) {
+		return
+	}
+	d := b.docs[from]
+	b.docs = append(b.docs[:from], b.docs[from+1:]...)
+	b.docs = append(b.docs[:to], append([]*Document{d}, b.docs[to:]...)...)
+}
+
+// Cycle steps through the documents in most recently used order without
+// changing that order, so that repeated Ctrl+Tab presses reach older ones.
+// EndCycle settles on the document reached.
+func (b *Buffers) Cycle(backward bool) {
+	if len(b.recent) < 2 {
+		return
+	}
+	if b.cycle < 0 {
+		b.cycle = 0
+	}
+	if backward {
+		b.cycle = (b.cycle + len(b.recent) - 1) % len(b.recent)
+	} else {
+		b.cycle = (b.cycle + 1) % len(b.recent)
+	}
+}
+
+func (b *Buffers) EndCycle() {
+	if b.cycle >= 0 {
+		b.Activate(b.recent[b.cycle])
+	}
+}
+
+func removeDocument(docs []*Document, d *Document) []*Document {
+	out := docs[:0]
+	for _, doc := range docs {
+		if doc != d {
+			out = append(out, doc)
+		}
+	}
+	return out
+}
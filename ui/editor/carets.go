@@ -0,0 +1,208 @@
+package editor
+
+import (
+	"sort"
+
+	"github.com/vypal/vedit/libs/search"
+)
+
+// caret is one of the carets besides the main one, with the anchor of its
+// selection, as Editor.cursor and Editor.anchor are for the main caret.
+type caret struct {
+	cursor, anchor int
+}
+
+func (c caret) start() int { return min(c.cursor, c.anchor) }
+func (c caret) end() int   { return max(c.cursor, c.anchor) }
+
+// eachCaret runs fn for every caret, from the first in the text to the
+// last, with e.cursor and e.anchor set to it. The changes fn makes are one
+// step in the undo history, which typing or deleting at every caret again
+// adds to, and carets that end up overlapping are merged.
+func (e *Editor) eachCaret(fn func()) {
+	if len(e.carets) == 0 {
+		fn()
+		return
+	}
+	main := caret{e.cursor, e.anchor}
+	all := append(append([]caret(nil), e.carets...), main)
+	sort.Slice(all, func(i, j int) bool { return all[i].start() < all[j].start() })
+	mainAt := 0
+	for i, c := range all {
+		if c == main {
+			mainAt = i
+		}
+	}
+	e.history.begin(e.anchor, e.cursor, true)
+	// While fn runs every caret is in e.carets, so that replace moves those
+	// after the change along with the text.
+	e.carets = all
+	for i := range e.carets {
+		e.cursor, e.anchor = e.carets[i].cursor, e.carets[i].anchor
+		fn()
+		e.carets[i] = caret{e.cursor, e.anchor}
+	}
+	main = e.carets[mainAt]
+	e.carets = append(e.carets[:mainAt], e.carets[mainAt+1:]...)
+	e.cursor, e.anchor = main.cursor, main.anchor
+	e.mergeCarets()
+	e.EndTransaction()
+	e.adjustScrollOffset()
+}
+
+// forEachCaret returns a command that runs fn for every caret.
+func (e *Editor) forEachCaret(fn func()) func() {
+	return func() { e.eachCaret(fn) }
+}
+
+// shiftCarets moves the carets besides the main one along with a change
+// that replaced the text from start to end with n runes. Those inside the
+// replaced text end up after it.
+func (e *Editor) shiftCarets(start, end, n int) {
+	shift := func(pos int) int {
+		switch {
+		case pos >= end:
+			return pos + n - (end - start)
+		case pos > start:
+			return start + n
+		}
+		return pos
+	}
+	for i, c := range e.carets {
+		e.carets[i] = caret{shift(c.cursor), shift(c.anchor)}
+	}
+}
+
+// mergeCarets puts the carets in the order of the text and merges those at
+// the same place, or whose selections overlap, into one. A caret merged
+// with the main one stays the main one.
+func (e *Editor) mergeCarets() {
+	type entry struct {
+		caret
+		main bool
+	}
+	n := e.buf.Len()
+	all := make([]entry, 0, len(e.carets)+1)
+	for _, c := range append(e.carets, caret{e.cursor, e.anchor}) {
+		all = append(all, entry{caret: caret{max(0, min(c.cursor, n)), max(0, min(c.anchor, n))}})
+	}
+	all[len(all)-1].main = true
+	sort.SliceStable(all, func(i, j int) bool { return all[i].start() < all[j].start() })
+	merged := all[:1]
+	for _, c := range all[1:] {
+		last := &merged[len(merged)-1]
+		if c.start() >= last.end() && c.start() != last.start() {
+			merged = append(merged, c)
+			continue
+		}
+		start, end := last.start(), max(last.end(), c.end())
+		if last.cursor < last.anchor {
+			last.caret = caret{cursor: start, anchor: end}
+		} else {
+			last.caret = caret{cursor: end, anchor: start}
+		}
+		last.main = last.main || c.main
+	}
+	e.carets = e.carets[:0]
+	for _, c := range merged {
+		if c.main {
+			e.cursor, e.anchor = c.cursor, c.anchor
+		} else {
+			e.carets = append(e.carets, c.caret)
+		}
+	}
+}
+
+// addCaret adds a caret at pos, selecting from anchor, and makes it the
+// main one.
+func (e *Editor) addCaret(anchor, pos int) {
+	e.carets = append(e.carets, caret{e.cursor, e.anchor})
+	e.cursor, e.anchor = pos, anchor
+	e.mergeCarets()
+	e.history.seal()
+	e.adjustScrollOffset()
+}
+
+// toggleCaret adds a caret at pos, or removes the one that is there unless
+// it is the only one.
+func (e *Editor) toggleCaret(pos int) {
+	for i, c := range e.carets {
+		if c.cursor == pos {
+			e.carets = append(e.carets[:i], e.carets[i+1:]...)
+			return
+		}
+	}
+	if e.cursor == pos && len(e.carets) > 0 {
+		last := e.carets[len(e.carets)-1]
+		e.carets = e.carets[:len(e.carets)-1]
+		e.cursor, e.anchor = last.cursor, last.anchor
+		return
+	}
+	e.addCaret(pos, pos)
+}
+
+// removeCarets leaves only the main caret.
+func (e *Editor) removeCarets() {
+	e.carets = nil
+}
+
+// hasCaret reports whether a caret selects the text from start to end.
+func (e *Editor) hasCaret(start, end int) bool {
+	if s, t := e.Selection(); s == start && t == end {
+		return true
+	}
+	for _, c := range e.carets {
+		if c.start() == start && c.end() == end {
+			return true
+		}
+	}
+	return false
+}
+
+// addNextOccurrence selects the word at the caret when nothing is selected.
+// Otherwise it adds a caret selecting the next occurrence of the selected
+// text after the main caret, wrapping around at the end of the text.
+func (e *Editor) addNextOccurrence() {
+	if !e.hasSelection() {
+		if start, end := e.wordAt(e.cursor); start < end {
+			e.SetSelection(start, end)
+		}
+		return
+	}
+	q, err := search.Compile(e.SelectedText(), search.Options{CaseSensitive: true})
+	if err != nil {
+		return
+	}
+	matches := q.FindAll(e.buf.String())
+	_, end := e.Selection()
+	i := sort.Search(len(matches), func(i int) bool { return matches[i].Start >= end })
+	for k := range matches {
+		m := matches[(i+k)%len(matches)]
+		if !e.hasCaret(m.Start, m.End) {
+			e.addCaret(m.Start, m.End)
+			return
+		}
+	}
+}
+
+// addCaretLine adds a caret on the line above the first caret, or below the
+// last one when down is set, in the same column as far as the line allows.
+func (e *Editor) addCaretLine(down bool) {
+	pos := e.cursor
+	for _, c := range e.carets {
+		if down == (c.cursor > pos) {
+			pos = c.cursor
+		}
+	}
+	line, col := e.buf.Position(pos)
+	if down {
+		line++
+	} else {
+		line--
+	}
+	if line < 0 || line >= e.buf.LineCount() {
+		return
+	}
+	pos = e.buf.Offset(line, col)
+	e.addCaret(pos, pos)
+}
@@ -0,0 +1,113 @@
+package editor
+
+import (
+	"testing"
+
+	"gioui.org/io/key"
+)
+
+// newCaretsEditor returns an editor holding text with a caret selecting
+// every occurrence of the word at the start, as Ctrl+D adds them.
+func newCaretsEditor(t *testing.T, text string, carets int) *Editor {
+	t.Helper()
+	e := newTestEditor(t, text)
+	for i := 0; i < carets; i++ {
+		e.addNextOccurrence()
+	}
+	if len(e.carets) != carets-1 {
+		t.Fatalf("%d carets, want %d", len(e.carets)+1, carets)
+	}
+	return e
+}
+
+func TestCaretsTyping(t *testing.T) {
+	e := newCaretsEditor(t, "foo x foo y foo", 3)
+	typeText(e, "bar")
+	checkText(t, e, "bar x bar y bar")
+	e.Undo()
+	checkText(t, e, "foo x foo y foo")
+	if e.history.CanUndo() {
+		t.Error("typing at several carets took more than one undo")
+	}
+	e.Redo()
+	checkText(t, e, "bar x bar y bar")
+}
+
+func TestCaretsTypingOnLines(t *testing.T) {
+	e := newTestEditor(t, "a\nb\nc")
+	e.addCaretLine(true)
+	e.addCaretLine(true)
+	typeText(e, "xy")
+	checkText(t, e, "xya\nxyb\nxyc")
+	e.Undo()
+	checkText(t, e, "a\nb\nc")
+	if e.history.CanUndo() {
+		t.Error("typing at several carets took more than one undo")
+	}
+}
+
+func TestCaretsDeleting(t *testing.T) {
+	e := newTestEditor(t, "abc\nabc")
+	e.MoveCursor(3)
+	e.addCaretLine(true)
+	for i := 0; i < 2; i++ {
+		e.eachCaret(e.backspace)
+	}
+	checkText(t, e, "a\na")
+	e.Undo()
+	checkText(t, e, "abc\nabc")
+	if e.history.CanUndo() {
+		t.Error("deleting at several carets took more than one undo")
+	}
+}
+
+func TestCaretsKindsAreSeparateSteps(t *testing.T) {
+	e := newTestEditor(t, "a\nb")
+	e.addCaretLine(true)
+	typeText(e, "xy")
+	e.eachCaret(e.backspace)
+	typeText(e, "z")
+	checkText(t, e, "xza\nxzb")
+	if n := undoAll(e); n != 3 {
+		t.Errorf("typing, deleting and typing again took %d undos, want 3", n)
+	}
+	checkText(t, e, "a\nb")
+}
+
+func TestCaretsOtherEditsAreNotMerged(t *testing.T) {
+	e := newTestEditor(t, "a\nb")
+	e.addCaretLine(true)
+	e.Insert("\n")
+	e.Insert("\n")
+	checkText(t, e, "\n\na\n\n\nb")
+	if n := undoAll(e); n != 2 {
+		t.Errorf("two line breaks took %d undos, want 2", n)
+	}
+}
+
+func TestCaretsCopy(t *testing.T) {
+	e := newCaretsEditor(t, "foo x foo", 2)
+	e.Copy()
+	if got, want := e.kills.at(0), "foo\nfoo"; got != want {
+		t.Errorf("copied %q, want %q", got, want)
+	}
+	typeText(e, "a")
+	e.Copy()
+	if got := e.kills.at(0); got != "foo\nfoo" {
+		t.Errorf("copying with nothing selected copied %q", got)
+	}
+}
+
+func TestCaretsComposition(t *testing.T) {
+	e := newTestEditor(t, "a\nb")
+	e.MoveCursor(1)
+	e.addCaretLine(true)
+	// The main caret is the one added, after the b. An input method shows
+	// "k" there and then replaces it with "か".
+	e.handleEdit(key.EditEvent{Range: key.Range{Start: 3, End: 3}, Text: "k"})
+	checkText(t, e, "ak\nbk")
+	e.handleEdit(key.EditEvent{Range: key.Range{Start: 4, End: 5}, Text: "か"})
+	checkText(t, e, "aか\nbか")
+	e.Undo()
+	checkText(t, e, "a\nb")
+}
@@ -0,0 +1,252 @@
+package editor
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/io/clipboard"
+	"gioui.org/io/transfer"
+)
+
+const (
+	clipboardMime = "application/text"
+	killRingSize  = 16
+)
+
+// killRing keeps the most recently copied texts, newest last.
+type killRing struct {
+	entries []string
+}
+
+func (k *killRing) push(text string) {
+	if text == "" {
+		return
+	}
+	if n := len(k.entries); n > 0 && k.entries[n-1] == text {
+		return
+	}
+	k.entries = append(k.entries, text)
+	if len(k.entries) > killRingSize {
+		k.entries = k.entries[len(k.entries)-killRingSize:]
+	}
+}
+
+// at returns the entry i places before the newest one, wrapping around.
+func (k *killRing) at(i int) string {
+	if len(k.entries) == 0 {
+		return ""
+	}
+	n := len(k.entries)
+	return k.entries[n-1-i%n]
+}
+
+// lastKill is the text of the last kill and where it left the caret, for a
+// kill right after it to add to the same kill ring entry.
+type lastKill struct {
+	doc     *Document
+	version int
+	pos     int
+	text    string
+}
+
+// yank remembers the last paste so that it can be cycled through the kill ring.
+type yank struct {
+	start, end int
+	text       string
+	index      int
+}
+
+// clipboardText returns the selection, or the whole caret line including its
+// newline when nothing is selected, together with the range it covers.
+func (e *Editor) clipboardText() (string, int, int) {
+	start, end := e.Selection()
+	if start == end {
+		line, _ := e.getCursorPosition()
+		start = e.buf.LineStart(line)
+		end = min(e.buf.LineEnd(line)+1, e.buf.Len())
+	}
+	return e.buf.Slice(start, end), start, end
+}
+
+// caretsText returns the selections of all carets in the order of the text,
+// one on each line, as a block selection reads. It is empty when none of
+// the carets selects anything, rather than a line break for each.
+func (e *Editor) caretsText() string {
+	all := append([]caret{{e.cursor, e.anchor}}, e.carets...)
+	sort.Slice(all, func(i, j int) bool { return all[i].start() < all[j].start() })
+	texts := make([]string, len(all))
+	empty := true
+	for i, c := range all {
+		texts[i] = e.buf.Slice(c.start(), c.end())
+		empty = empty && texts[i] == ""
+	}
+	if empty {
+		return ""
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Copy copies the selection, or with several carets the selection of each
+// on a line of its own. Several carets that select nothing copy nothing.
+func (e *Editor) Copy() {
+	if len(e.carets) > 0 {
+		e.writeClipboard(e.caretsText())
+		return
+	}
+	text, _, _ := e.clipboardText()
+	e.writeClipboard(text)
+}
+
+func (e *Editor) Cut() {
+	if len(e.carets) > 0 {
+		e.writeClipboard(e.caretsText())
+		if !e.blockReplace("") {
+			e.eachCaret(func() {
+				start, end := e.Selection()
+				e.delete(start, end, editOther)
+			})
+		}
+		return
+	}
+	text, start, end := e.clipboardText()
+	e.writeClipboard(text)
+	e.delete(start, end, editOther)
+}
+
+// Paste requests the system clipboard contents; they are inserted when the
+// transfer arrives during the next Layout.
+func (e *Editor) Paste() {
+	e.commands = append(e.commands, clipboard.ReadCmd{Tag: e})
+}
+
+// PasteCycle replaces the text inserted by the previous paste with the next
+// older kill ring entry. Without a preceding paste it inserts the newest entry.
+func (e *Editor) PasteCycle() {
+	y := e.lastYank
+	if y != nil && e.cursor == y.end && e.buf.Slice(y.start, y.end) == y.text {
+		e.anchor = y.start
+		e.yank(e.kills.at(y.index+1), y.index+1)
+		return
+	}
+	e.yank(e.kills.at(0), 0)
+}
+
+// kill cuts the text from start to end into the kill ring. A kill right
+// after another one, with the caret where that one left it, adds to its
+// entry instead: after it when killing forward, before it when killing
+// backward, so that repeated kills are yanked back as one.
+func (e *Editor) kill(start, end int) {
+	if start >= end {
+		return
+	}
+	text := e.buf.Slice(start, end)
+	if k := e.lastKill; k != nil && k.doc == e.doc && k.version == e.doc.version && k.pos == e.cursor && e.kills.at(0) == k.text {
+		if end <= e.cursor {
+			text += k.text
+		} else {
+			text = k.text + text
+		}
+		e.kills.entries = e.kills.entries[:len(e.kills.entries)-1]
+	}
+	e.delete(start, end, editOther)
+	e.writeClipboard(text)
+	e.lastKill = &lastKill{doc: e.doc, version: e.doc.version, pos: e.cursor, text: text}
+}
+
+// killLine kills the rest of the caret line, along with its line break if
+// nothing but blanks is left of it.
+func (e *Editor) killLine() {
+	line, _ := e.getCursorPosition()
+	end := e.buf.LineEnd(line)
+	if strings.TrimSpace(e.buf.Slice(e.cursor, end)) == "" {
+		end = min(end+1, e.buf.Len())
+	}
+	e.kill(e.cursor, end)
+}
+
+// killWord kills up to the end of the word after the caret, or back to the
+// start of the one before it.
+func (e *Editor) killWord(backward bool) {
+	if backward {
+		e.kill(e.wordLeft(e.cursor), e.cursor)
+	} else {
+		e.kill(e.cursor, e.wordRight(e.cursor))
+	}
+}
+
+// killRegion kills the selection.
+func (e *Editor) killRegion() {
+	e.kill(e.Selection())
+}
+
+// copyRegion copies the selection into the kill ring and deactivates the
+// mark.
+func (e *Editor) copyRegion() {
+	if !e.hasSelection() {
+		return
+	}
+	e.writeClipboard(e.SelectedText())
+	e.mark.active = false
+	e.anchor = e.cursor
+}
+
+func (e *Editor) writeClipboard(text string) {
+	if text == "" {
+		return
+	}
+	e.kills.push(text)
+	e.commands = append(e.commands, clipboard.WriteCmd{
+		Type: clipboardMime,
+		Data: io.NopCloser(strings.NewReader(text)),
+	})
+}
+
+func (e *Editor) handleTransfer(ev transfer.DataEvent) {
+	r := ev.Open()
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return
+	}
+	text := string(content)
+	e.kills.push(text)
+	e.yank(text, 0)
+}
+
+func (e *Editor) yank(text string, index int) {
+	if text == "" {
+		return
+	}
+	if len(e.carets) > 0 {
+		e.yankEach(text)
+		return
+	}
+	e.insert(text, editOther)
+	end := e.cursor
+	e.lastYank = &yank{start: end - utf8.RuneCountInString(text), end: end, text: text, index: index}
+}
+
+// yankEach pastes text at every caret. Text with a line for each caret, as
+// copied from as many, is spread over them a line at each; a block selection
+// takes it column-wise and other text goes whole to each caret.
+func (e *Editor) yankEach(text string) {
+	e.lastYank = nil
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) != len(e.carets)+1 {
+		if !e.blockReplace(text) {
+			e.eachCaret(func() { e.insert(text, editOther) })
+		}
+		return
+	}
+	if e.activeBlock() != nil {
+		e.blockPaste(lines)
+		return
+	}
+	i := 0
+	e.eachCaret(func() {
+		e.insert(lines[i], editOther)
+		i++
+	})
+}
@@ -0,0 +1,104 @@
+package editor
+
+import "github.com/vypal/vedit/ui/commands"
+
+// RegisterCommands adds the editing commands to r and makes the keys bound
+// to commands for the editor work in it. A new editor has its commands in a
+// registry of its own.
+//
+// The commands that move the caret or edit around it do so for every caret,
+// and extend the selection while the mark is active. Those without keys are
+// bound by keymap presets such as Emacs's.
+func (e *Editor) RegisterCommands(r *commands.Registry) {
+	e.actions = r
+	searching := func() bool { return e.find.query != nil }
+	r.Register(
+		commands.Command{ID: "cursor.left", Title: "Move Left", Keys: []string{"Left"}, Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveLeft(e.marking()) })},
+		commands.Command{ID: "cursor.right", Title: "Move Right", Keys: []string{"Right"}, Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveRight(e.marking()) })},
+		commands.Command{ID: "cursor.up", Title: "Move Up", Keys: []string{"Up"}, Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveCursorUp(e.marking()) })},
+		commands.Command{ID: "cursor.down", Title: "Move Down", Keys: []string{"Down"}, Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveCursorDown(e.marking()) })},
+		commands.Command{ID: "cursor.lineStart", Title: "Go to Start of Line", Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveLineStart(e.marking()) })},
+		commands.Command{ID: "cursor.lineEnd", Title: "Go to End of Line", Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveLineEnd(e.marking()) })},
+		commands.Command{ID: "cursor.wordLeft", Title: "Go to Previous Word", Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveCaret(e.wordLeft(e.cursor), e.marking()) })},
+		commands.Command{ID: "cursor.wordRight", Title: "Go to Next Word", Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveCaret(e.wordRight(e.cursor), e.marking()) })},
+		commands.Command{ID: "cursor.fileStart", Title: "Go to Start of File", Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveCaret(0, e.marking()) })},
+		commands.Command{ID: "cursor.fileEnd", Title: "Go to End of File", Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveCaret(e.buf.Len(), e.marking()) })},
+		commands.Command{ID: "select.left", Title: "Select Left", Keys: []string{"Shift+Left"}, Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveLeft(true) })},
+		commands.Command{ID: "select.right", Title: "Select Right", Keys: []string{"Shift+Right"}, Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveRight(true) })},
+		commands.Command{ID: "select.up", Title: "Select Up", Keys: []string{"Shift+Up"}, Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveCursorUp(true) })},
+		commands.Command{ID: "select.down", Title: "Select Down", Keys: []string{"Shift+Down"}, Context: commands.Editor,
+			Run: e.forEachCaret(func() { e.moveCursorDown(true) })},
+		commands.Command{ID: "select.blockLeft", Title: "Column Select Left", Keys: []string{"Shift+Alt+Left"}, Context: commands.Editor,
+			Run: func() { e.moveBlock(0, -1) }},
+		commands.Command{ID: "select.blockRight", Title: "Column Select Right", Keys: []string{"Shift+Alt+Right"}, Context: commands.Editor,
+			Run: func() { e.moveBlock(0, 1) }},
+		commands.Command{ID: "select.blockUp", Title: "Column Select Up", Keys: []string{"Shift+Alt+Up"}, Context: commands.Editor,
+			Run: func() { e.moveBlock(-1, 0) }},
+		commands.Command{ID: "select.blockDown", Title: "Column Select Down", Keys: []string{"Shift+Alt+Down"}, Context: commands.Editor,
+			Run: func() { e.moveBlock(1, 0) }},
+		commands.Command{ID: "edit.newline", Title: "Insert Line Break", Keys: []string{"Enter", "Shift+Enter"}, Context: commands.Editor,
+			Run: func() { e.Insert("\n") }},
+		commands.Command{ID: "edit.tab", Title: "Insert Tab", Keys: []string{"Tab"}, Context: commands.Editor,
+			Run: func() { e.Insert("\t") }},
+		commands.Command{ID: "edit.deleteBackward", Title: "Delete Left", Keys: []string{"Backspace", "Shift+Backspace"}, Context: commands.Editor,
+			Run: func() {
+				if !e.blockDelete(true) {
+					e.eachCaret(e.backspace)
+				}
+			}},
+		commands.Command{ID: "edit.deleteForward", Title: "Delete Right", Keys: []string{"Delete"}, Context: commands.Editor,
+			Run: func() {
+				if !e.blockDelete(false) {
+					e.eachCaret(e.deleteForward)
+				}
+			}},
+		commands.Command{ID: "edit.undo", Title: "Undo", Keys: []string{"Ctrl+Z"}, Context: commands.Editor,
+			Enabled: func() bool { return e.history.CanUndo() }, Run: e.Undo},
+		commands.Command{ID: "edit.redo", Title: "Redo", Keys: []string{"Ctrl+Y", "Ctrl+Shift+Z"}, Context: commands.Editor,
+			Enabled: func() bool { return e.history.CanRedo() }, Run: e.Redo},
+		commands.Command{ID: "edit.cut", Title: "Cut", Keys: []string{"Ctrl+X"}, Context: commands.Editor, Run: e.Cut},
+		commands.Command{ID: "edit.copy", Title: "Copy", Keys: []string{"Ctrl+C"}, Context: commands.Editor, Run: e.Copy},
+		commands.Command{ID: "edit.paste", Title: "Paste", Keys: []string{"Ctrl+V"}, Context: commands.Editor, Run: e.Paste},
+		commands.Command{ID: "edit.pasteCycle", Title: "Paste Older Clipping", Keys: []string{"Ctrl+Shift+V"}, Context: commands.Editor, Run: e.PasteCycle},
+		commands.Command{ID: "edit.selectAll", Title: "Select All", Keys: []string{"Ctrl+A"}, Context: commands.Editor, Run: e.SelectAll},
+		commands.Command{ID: "cursor.addNextOccurrence", Title: "Add Selection to Next Find Match", Keys: []string{"Ctrl+D"}, Context: commands.Editor,
+			Run: e.addNextOccurrence},
+		commands.Command{ID: "cursor.addAbove", Title: "Add Caret Above", Keys: []string{"Ctrl+Alt+Up"}, Context: commands.Editor,
+			Run: func() { e.addCaretLine(false) }},
+		commands.Command{ID: "cursor.addBelow", Title: "Add Caret Below", Keys: []string{"Ctrl+Alt+Down"}, Context: commands.Editor,
+			Run: func() { e.addCaretLine(true) }},
+		commands.Command{ID: "cursor.removeExtra", Title: "Remove Extra Carets", Keys: []string{"Escape"}, Context: commands.Editor,
+			Enabled: func() bool { return len(e.carets) > 0 }, Run: e.removeCarets},
+		commands.Command{ID: "edit.setMark", Title: "Set Mark", Context: commands.Editor, Run: e.setMark},
+		commands.Command{ID: "edit.exchangeMark", Title: "Exchange Caret and Mark", Context: commands.Editor, Run: e.exchangeMark},
+		commands.Command{ID: "edit.cancel", Title: "Deactivate Mark", Context: commands.Editor, Run: e.cancel},
+		commands.Command{ID: "edit.killLine", Title: "Kill to End of Line", Context: commands.Editor, Run: e.killLine},
+		commands.Command{ID: "edit.killWord", Title: "Kill Word", Context: commands.Editor,
+			Run: func() { e.killWord(false) }},
+		commands.Command{ID: "edit.killWordBackward", Title: "Kill Word Backward", Context: commands.Editor,
+			Run: func() { e.killWord(true) }},
+		commands.Command{ID: "edit.killRegion", Title: "Kill Region", Context: commands.Editor, Run: e.killRegion},
+		commands.Command{ID: "edit.copyRegion", Title: "Copy Region", Context: commands.Editor, Run: e.copyRegion},
+		commands.Command{ID: "find.next", Title: "Find Next", Keys: []string{"F3"}, Context: commands.Editor,
+			Enabled: searching, Run: func() { e.FindNext(false) }},
+		commands.Command{ID: "find.previous", Title: "Find Previous", Keys: []string{"Shift+F3"}, Context: commands.Editor,
+			Enabled: searching, Run: func() { e.FindNext(true) }},
+		commands.Command{ID: "find.incremental", Title: "Incremental Search", Context: commands.Editor,
+			Run: func() { e.isearchNext(false) }},
+		commands.Command{ID: "find.incrementalBackward", Title: "Incremental Search Backward", Context: commands.Editor,
+			Run: func() { e.isearchNext(true) }},
+	)
+}
@@ -0,0 +1,202 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vypal/vedit/libs"
+	"github.com/vypal/vedit/libs/buffer"
+	"github.com/vypal/vedit/libs/syntax"
+)
+
+// Document is a text buffer together with the file it is loaded from and
+// saved to. A document without a file name is untitled.
+type Document struct {
+	File    libs.File
+	Buffer  buffer.Buffer
+	History History
+	dirty   bool
+	// deleted is set when the file was deleted while the document was open.
+	deleted bool
+	// version counts the changes made to the buffer.
+	version   int
+	view      view
+	highlight *syntax.Highlighter
+}
+
+// view is where the editor left the document when it switched to another.
+type view struct {
+	cursor, anchor        int
+	scrollOffset, scrollX int
+}
+
+func NewDocument() *Document {
+	return &Document{
+		Buffer:    buffer.NewPieceTable(""),
+		highlight: syntax.NewHighlighter(nil),
+	}
+}
+
+// OpenDocument loads the file at path. A file that doesn't exist yet gives an
+// empty document which creates the file when saved.
+func OpenDocument(path string) (*Document, error) {
+	f, err := libs.NewFileFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	d := &Document{
+		File:      f,
+		Buffer:    buffer.NewPieceTableBytes(f.Contents),
+		highlight: syntax.NewHighlighter(syntax.ForFile(f.Name)),
+	}
+	// The buffer holds the text from now on.
+	d.File.Contents = nil
+	return d, nil
+}
+
+func (d *Document) Save() error {
+	if d.deleted {
+		return fmt.Errorf("%s was deleted; save it under a name instead", d.File.FullPath())
+	}
+	d.File.Contents = []byte(d.Buffer.String())
+	err := d.File.Save()
+	d.File.Contents = nil
+	if err != nil {
+		return err
+	}
+	d.dirty = false
+	return nil
+}
+
+// Reload reads the file again, dropping unsaved changes and the undo
+// history, which no longer fits the text.
+func (d *Document) Reload() error {
+	if err := d.File.Load(); err != nil {
+		return err
+	}
+	text := string(d.File.Contents)
+	d.File.Contents = nil
+	d.replace(0, d.Buffer.Len(), text)
+	d.History.Clear()
+	d.dirty = false
+	n := d.Buffer.Len()
+	d.view.cursor, d.view.anchor = min(d.view.cursor, n), min(d.view.anchor, n)
+	return nil
+}
+
+// SaveAs binds the document to a new path and saves it there.
+func (d *Document) SaveAs(path string) error {
+	f, err := libs.NewFileFromPath(path)
+	if err != nil {
+		return err
+	}
+	f.Backup = d.File.Backup
+	d.File = f
+	d.deleted = false
+	d.detectLanguage()
+	return d.Save()
+}
+
+// Moved follows the document's file after the file or a directory containing
+// it was moved from oldPath to newPath. It reports whether the document was
+// affected.
+func (d *Document) Moved(oldPath, newPath string) bool {
+	rel, ok := d.within(oldPath)
+	if !ok {
+		return false
+	}
+	f, err := libs.NewFileFromPath(filepath.Join(newPath, rel))
+	if err != nil {
+		return false
+	}
+	f.Backup = d.File.Backup
+	d.File = f
+	d.detectLanguage()
+	return true
+}
+
+// Deleted tells the document that the file or directory at path was deleted,
+// and reports whether that was the document's file or contained it. The
+// document keeps its text, but saving it no longer recreates the file: it
+// needs a name again first.
+func (d *Document) Deleted(path string) bool {
+	if _, ok := d.within(path); !ok {
+		return false
+	}
+	d.deleted = true
+	return true
+}
+
+// within returns the path of the document's file relative to path, if the
+// file is at path or below it.
+func (d *Document) within(path string) (string, bool) {
+	if d.Untitled() {
+		return "", false
+	}
+	rel, err := filepath.Rel(path, d.File.FullPath())
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// Language returns the language the document is highlighted as, or nil for
+// plain text.
+func (d *Document) Language() syntax.Language {
+	return d.highlight.Language()
+}
+
+func (d *Document) SetLanguage(lang syntax.Language) {
+	d.highlight.SetLanguage(lang)
+}
+
+// detectLanguage picks the language from the file name when it changes.
+func (d *Document) detectLanguage() {
+	if lang := syntax.ForFile(d.File.Name); lang != d.Language() {
+		d.SetLanguage(lang)
+	}
+}
+
+// replace puts text in place of the range from start to end, returning the
+// text it replaced. Every change to the buffer goes through here.
+func (d *Document) replace(start, end int, text string) string {
+	line, _ := d.Buffer.Position(start)
+	deleted := d.Buffer.Slice(start, end)
+	d.Buffer.Delete(start, end)
+	d.Buffer.Insert(start, text)
+	d.highlight.Edited(line, strings.Count(deleted, "\n"), strings.Count(text, "\n"))
+	d.dirty = true
+	d.version++
+	return deleted
+}
+
+// Dirty reports whether the document has changes that haven't been saved.
+func (d *Document) Dirty() bool {
+	return d.dirty
+}
+
+func (d *Document) Untitled() bool {
+	return d.File.Name == ""
+}
+
+// HasFile reports whether the document can be saved to its file as it is:
+// it has a name and the file wasn't deleted while it was open.
+func (d *Document) HasFile() bool {
+	return !d.Untitled() && !d.deleted
+}
+
+func (d *Document) Title() string {
+	if d.Untitled() {
+		return "Untitled"
+	}
+	if d.deleted {
+		return d.File.Name + " (deleted)"
+	}
+	return d.File.Name
+}
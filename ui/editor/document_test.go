@@ -0,0 +1,81 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDocumentBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBuffers()
+	b.SetBackup(true)
+	d, err := OpenDocument(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Add(d)
+	d.replace(0, d.Buffer.Len(), "new")
+	if err := d.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(path + "~"); err != nil || string(got) != "old" {
+		t.Errorf("backup = %q, %v, want %q", got, err, "old")
+	}
+
+	// Saving under another name keeps making backups there.
+	other := filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(other, []byte("other"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SaveAs(other); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(other + "~"); err != nil || string(got) != "other" {
+		t.Errorf("backup after SaveAs = %q, %v, want %q", got, err, "other")
+	}
+
+	b.SetBackup(false)
+	if d.File.Backup {
+		t.Error("SetBackup(false) left the open document making backups")
+	}
+}
+
+func TestDocumentDeleted(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "a.txt")
+	d := NewDocument()
+	d.File.Name, d.File.Path = "a.txt", filepath.Join(dir, "sub")
+
+	if d.Deleted(filepath.Join(dir, "other")) || d.Deleted(filepath.Join(dir, "sub", "a.txt2")) {
+		t.Fatal("deleting an unrelated path affected the document")
+	}
+	if !d.HasFile() {
+		t.Fatal("document lost its file")
+	}
+	if !d.Deleted(dir) {
+		t.Fatal("deleting the directory holding the file didn't affect the document")
+	}
+	if d.HasFile() {
+		t.Error("document still has its file after it was deleted")
+	}
+	if err := d.Save(); err == nil {
+		t.Error("saving a deleted document recreated its file")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file was recreated: %v", err)
+	}
+
+	// Saving under a name binds the document to a file again.
+	if err := d.SaveAs(filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if !d.HasFile() {
+		t.Error("document has no file after SaveAs")
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"log"
 	"strings"
-	"unicode"
+	"unicode/utf8"
 
 	"gioui.org/font"
 	"gioui.org/io/event"
+	"gioui.org/io/input"
 	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/io/transfer"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
@@ -17,66 +21,240 @@ import (
 	"gioui.org/text"
 	"gioui.org/unit"
 	"gioui.org/widget/material"
+	"github.com/vypal/vedit/libs/buffer"
+	"github.com/vypal/vedit/libs/settings"
+	"github.com/vypal/vedit/ui/commands"
+	"github.com/vypal/vedit/ui/theme"
 	"golang.org/x/image/math/fixed"
 )
 
 type Editor struct {
-	content         []rune
-	cursor          int
-	scrollOffset    int
-	fontSize        unit.Sp
-	lineHeight      unit.Sp
-	textColor       color.NRGBA
-	textColorDarker color.NRGBA
-	bgColor         color.NRGBA
-	lineNumColor    color.NRGBA
-	shaper          *text.Shaper
-	focused         bool
+	// OnSaveAs is called when the document needs a file name to be saved.
+	OnSaveAs func()
+	// OnClose is called to close the document, for Vim's :q. Unsaved
+	// changes have been dealt with by then.
+	OnClose func()
+	// Colors are the colors the editor is drawn with. They may be changed
+	// while the editor is shown.
+	Colors *theme.Theme
+
+	doc           *Document
+	buf           buffer.Buffer
+	cursor        int
+	anchor        int
+	scrollOffset  int
+	fontSize      unit.Sp
+	lineHeight    unit.Sp
+	tabWidth      int
+	gutterPadding unit.Dp
+	shaper        *text.Shaper
+	focused       bool
+	wantFocus     bool
+	history       *History
+	click         clickState
+	contentOffset int
+	viewport      image.Point
+	linePx        int
+	visibleLines  int
+	scrollX       int
+	scrollRest    float32
+	widestLine    int
+	revealCaret   bool
+	vbar          scrollbar
+	ime           imeState
+	kills         killRing
+	lastYank      *yank
+	lastKill      *lastKill
+	mark          mark
+	find          findState
+	isearch       *isearchState
+	lastIsearch   string
+	// message is shown in the status bar until the next key.
+	message string
+	// carets are the carets besides the main one, in the order of the
+	// text. Commands run through eachCaret apply to all of them; the rest
+	// only to the main caret.
+	carets []caret
+	// block is the rectangular selection the carets were last made from.
+	block *block
+	// vim is the state of the Vim layer, or nil while it is off.
+	vim *vimState
+	// actions are the commands whose keys work in the editor.
+	actions *commands.Registry
+	// commands are queued by HandleKey and executed during the next Layout.
+	commands []input.Command
 }
 
 func NewEditor(shaper *text.Shaper) *Editor {
-	return &Editor{
-		content:         []rune{},
-		cursor:          0,
-		fontSize:        unit.Sp(22),
-		lineHeight:      unit.Sp(26),
-		textColor:       color.NRGBA{R: 0xD3, G: 0xD2, B: 0xD1, A: 255},
-		textColorDarker: color.NRGBA{R: 0xA3, G: 0xA4, B: 0xA5, A: 255},
-		bgColor:         color.NRGBA{R: 0x1A, G: 0x1B, B: 0x1B, A: 255},
-		lineNumColor:    color.NRGBA{R: 125, G: 125, B: 125, A: 125},
-		shaper:          shaper,
-		focused:         true,
+	e := &Editor{
+		Colors:    theme.Default(),
+		shaper:    shaper,
+		focused:   true,
+		wantFocus: true,
+	}
+	e.Configure(settings.Default())
+	e.SetDocument(NewDocument())
+	e.RegisterCommands(commands.NewRegistry())
+	return e
+}
+
+// Configure applies the settings that change how text is laid out.
+func (e *Editor) Configure(s settings.Settings) {
+	e.fontSize = unit.Sp(s.FontSize)
+	e.lineHeight = unit.Sp(s.LineHeightSp())
+	e.tabWidth = s.TabWidth
+	e.gutterPadding = unit.Dp(s.GutterPadding)
+	e.revealCaret = true
+	e.SetVim(s.Keybindings == "vim")
+}
+
+// SetDocument shows d in the editor. The caret and scroll position of the
+// previous document are kept with it for when it is shown again.
+func (e *Editor) SetDocument(d *Document) {
+	if e.doc == d {
+		return
+	}
+	// The change typed in insert mode belongs to the undo history of the
+	// document being left.
+	e.vimStop()
+	if e.doc != nil {
+		e.doc.view = view{e.cursor, e.anchor, e.scrollOffset, e.scrollX}
+	}
+	e.doc = d
+	e.buf = d.Buffer
+	e.history = &d.History
+	e.cursor, e.anchor = d.view.cursor, d.view.anchor
+	e.scrollOffset, e.scrollX, e.scrollRest = d.view.scrollOffset, d.view.scrollX, 0
+	e.lastYank = nil
+	e.carets = nil
+	e.isearchEnd(false)
+	if e.vim != nil {
+		e.vimClamp()
+	}
+}
+
+func (e *Editor) Document() *Document {
+	return e.doc
+}
+
+// Status describes what the editor is in the middle of, for a status bar:
+// an incremental search, the mode of the Vim layer or a message, and the
+// keys of the Vim command being typed.
+func (e *Editor) Status() (status, keys string) {
+	switch {
+	case e.isearch != nil:
+		return e.isearch.prompt(), ""
+	case e.vim != nil:
+		return e.vimStatus()
+	}
+	return e.message, ""
+}
+
+// Focus gives the editor keyboard focus during the next Layout.
+func (e *Editor) Focus() {
+	e.wantFocus = true
+}
+
+// Save writes the document to its file, asking for a file name through
+// OnSaveAs if it doesn't have one yet or its file was deleted.
+func (e *Editor) Save() {
+	if !e.doc.HasFile() {
+		if e.OnSaveAs != nil {
+			e.OnSaveAs()
+		}
+		return
+	}
+	if err := e.doc.Save(); err != nil {
+		log.Printf("saving %s: %v", e.doc.Title(), err)
 	}
 }
 
 func (e *Editor) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	e.viewport = gtx.Constraints.Max
+	e.linePx = max(1, gtx.Sp(e.lineHeight))
+	e.visibleLines = max(1, e.viewport.Y/e.linePx)
+
+	area := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
 	event.Op(gtx.Ops, e)
+	pointer.CursorText.Add(gtx.Ops)
+	key.InputHintOp{Tag: e, Hint: key.HintText}.Add(gtx.Ops)
+	area.Pop()
+	if e.wantFocus {
+		// Text input is only delivered to the focused tag.
+		gtx.Execute(key.FocusCmd{Tag: e})
+		e.wantFocus = false
+	}
 	for {
-		ev, ok := gtx.Event(key.Filter{Focus: nil, Optional: key.ModAlt | key.ModCommand | key.ModShift | key.ModSuper | key.ModCtrl})
+		filters := []event.Filter{
+			key.FocusFilter{Target: e},
+			key.Filter{Focus: e, Optional: key.ModAlt | key.ModCommand | key.ModShift | key.ModSuper | key.ModCtrl},
+			transfer.TargetFilter{Target: e, Type: clipboardMime},
+			pointer.Filter{
+				Target:  e,
+				Kinds:   pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel | pointer.Scroll,
+				ScrollX: e.scrollRangeX(),
+				ScrollY: e.scrollRangeY(),
+			},
+		}
+		// Keys such as Tab, which also move the focus, only reach filters
+		// that name them.
+		filters = append(filters, e.actions.Filters(commands.Editor, e)...)
+		ev, ok := gtx.Event(filters...)
 		if !ok {
 			break
 		}
 		switch ev := ev.(type) {
 		case key.FocusEvent:
 			e.focused = ev.Focus
+			e.ime = imeState{}
+			if ev.Focus {
+				gtx.Execute(key.SoftKeyboardCmd{Show: true})
+			}
 		case key.Event:
 			e.HandleKey(ev)
+		case key.EditEvent:
+			e.handleEdit(ev)
+		case key.SnippetEvent:
+			e.updateSnippet(gtx, ev.Start, ev.End)
+		case key.SelectionEvent:
+			e.handleIMESelection(ev)
+		case transfer.DataEvent:
+			e.handleTransfer(ev)
+		case pointer.Event:
+			if ev.Kind == pointer.Scroll {
+				e.handleScroll(ev)
+			} else {
+				e.handlePointer(gtx, th, ev)
+			}
 		}
 	}
+	for _, cmd := range e.commands {
+		gtx.Execute(cmd)
+	}
+	e.commands = e.commands[:0]
 
-	paint.Fill(gtx.Ops, e.bgColor)
+	paint.Fill(gtx.Ops, e.Colors.Background)
 
-	lines := e.getLines()
-	visibleLines := e.getVisibleLines()
+	// One extra line fills the partially visible row at the bottom.
 	startLine := e.scrollOffset
-	endLine := min(startLine+visibleLines, len(lines))
+	endLine := min(startLine+e.visibleLines+1, e.buf.LineCount())
 
 	lineNumWidth := e.drawLineNumbers(gtx, th, startLine, endLine)
 
-	contentOffset := lineNumWidth + 20 // TODO: Maybe make this configurable
-	e.drawContent(gtx, th, lines, startLine, endLine, contentOffset)
+	e.contentOffset = lineNumWidth + gtx.Dp(e.gutterPadding)
+	if e.revealCaret {
+		e.scrollCaretIntoView(gtx, th)
+		e.revealCaret = false
+	}
+
+	content := clip.Rect{Min: image.Point{X: e.contentOffset}, Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	xOffset := e.contentOffset - e.scrollX
+	e.drawContent(gtx, th, startLine, endLine, xOffset)
+	e.drawCursor(gtx, th, float32(xOffset))
+	content.Pop()
 
-	e.drawCursor(gtx, th, float32(contentOffset))
+	e.layoutScrollbar(gtx)
+	e.updateIME(gtx, th)
 
 	return layout.Dimensions{Size: gtx.Constraints.Max}
 }
@@ -86,8 +264,8 @@ func (e *Editor) drawLineNumbers(gtx layout.Context, th *material.Theme, startLi
 	for lineNum := startLine; lineNum < endLine; lineNum++ {
 		lineNumStr := fmt.Sprintf("%d", lineNum+1)
 		lbl := material.Label(th, e.fontSize, lineNumStr)
-		lbl.Color = e.lineNumColor
-		stack := op.Offset(image.Point{Y: int(e.lineHeight) * (lineNum - startLine)}).Push(gtx.Ops)
+		lbl.Color = e.Colors.LineNumber
+		stack := op.Offset(image.Point{Y: e.linePx * (lineNum - startLine)}).Push(gtx.Ops)
 		dims := lbl.Layout(gtx)
 		stack.Pop()
 		maxWidth = max(maxWidth, dims.Size.X)
@@ -95,71 +273,134 @@ func (e *Editor) drawLineNumbers(gtx layout.Context, th *material.Theme, startLi
 	return maxWidth
 }
 
-func (e *Editor) drawContent(gtx layout.Context, th *material.Theme, lines []string, startLine, endLine, xOffset int) {
+func (e *Editor) drawContent(gtx layout.Context, th *material.Theme, startLine, endLine, xOffset int) {
+	// Lines are laid out without wrapping; the view scrolls horizontally instead.
+	lineGtx := gtx
+	lineGtx.Constraints = layout.Constraints{Max: image.Point{X: maxLineWidth, Y: gtx.Constraints.Max.Y}}
+
+	e.widestLine = 0
+	selStart, selEnd := e.shownSelection()
+	matches := e.visibleMatches(startLine, endLine)
+	block := e.activeBlock()
 	for lineNum := startLine; lineNum < endLine; lineNum++ {
-		if lineNum >= len(lines) {
-			break
+		line := e.buf.Line(lineNum)
+		for _, m := range matches {
+			e.drawRange(gtx, th, lineNum, line, m.Start, m.End, xOffset, e.Colors.Match)
 		}
-		line := lines[lineNum]
-		lbl := material.Label(th, e.fontSize, strings.ReplaceAll(line, "\t", "    "))
-		lbl.Color = e.textColorDarker
-
-		// Create a new context with adjusted constraints
-		/* textGtx := gtx
-		textGtx.Constraints = layout.Constraints{
-			Min: image.Point{X: 0, Y: 0},
-			Max: image.Point{X: gtx.Constraints.Max.X - xOffset, Y: int(e.lineHeight)},
-		} */
-
-		stack := op.Offset(image.Point{X: xOffset - gtx.Constraints.Max.X, Y: (lineNum - startLine) * int(e.lineHeight)}).Push(gtx.Ops)
-		lbl.Layout(gtx)
-		stack.Pop()
+		if block != nil {
+			e.drawBlockLine(gtx, th, block, lineNum, xOffset)
+		} else {
+			if selStart < selEnd {
+				e.drawRange(gtx, th, lineNum, line, selStart, selEnd, xOffset, e.Colors.Selection)
+			}
+			for _, c := range e.carets {
+				if c.start() < c.end() {
+					e.drawRange(gtx, th, lineNum, line, c.start(), c.end(), xOffset, e.Colors.Selection)
+				}
+			}
+		}
+		spans := e.doc.highlight.Spans(e.buf, lineNum)
+		width := e.drawLine(lineGtx, th, []rune(line), spans, xOffset, (lineNum-startLine)*e.linePx)
+		e.widestLine = max(e.widestLine, width)
 	}
 }
 
+// drawRange paints the part of the range from selStart to selEnd that lies
+// on lineNum, such as the selection or a search match.
+func (e *Editor) drawRange(gtx layout.Context, th *material.Theme, lineNum int, line string, selStart, selEnd, xOffset int, c color.NRGBA) {
+	lineStart := e.buf.LineStart(lineNum)
+	lineEnd := lineStart + utf8.RuneCountInString(line)
+	if selStart > lineEnd || selEnd <= lineStart {
+		return
+	}
+
+	runes := []rune(line)
+	from := max(selStart, lineStart) - lineStart
+	to := min(selEnd, lineEnd) - lineStart
+	x0 := xOffset + measureTextWidth(gtx, th, e.expandTabs(string(runes[:from])), e.fontSize)
+	x1 := xOffset + measureTextWidth(gtx, th, e.expandTabs(string(runes[:to])), e.fontSize)
+	if selEnd > lineEnd {
+		// Show that the newline is selected too.
+		x1 += measureTextWidth(gtx, th, " ", e.fontSize)
+	}
+
+	y := (lineNum - e.scrollOffset) * e.linePx
+	paint.FillShape(gtx.Ops,
+		c,
+		clip.Rect{
+			Min: image.Point{X: x0, Y: y},
+			Max: image.Point{X: x1, Y: y + e.linePx},
+		}.Op(),
+	)
+}
+
+// drawCursor draws the main caret and the others.
 func (e *Editor) drawCursor(gtx layout.Context, th *material.Theme, xOffset float32) {
-	cursorLine, cursorCol := e.getCursorPosition()
-	if cursorLine < e.scrollOffset || cursorLine >= e.scrollOffset+int(gtx.Constraints.Max.Y/int(e.lineHeight)) {
+	if b := e.activeBlock(); b != nil {
+		e.drawBlockCarets(gtx, th, b, xOffset)
+		return
+	}
+	e.drawCaret(gtx, th, xOffset, e.cursor)
+	for _, c := range e.carets {
+		e.drawCaret(gtx, th, xOffset, c.cursor)
+	}
+}
+
+func (e *Editor) drawCaret(gtx layout.Context, th *material.Theme, xOffset float32, pos int) {
+	cursorLine, cursorCol := e.buf.Position(pos)
+	if cursorLine < e.scrollOffset || cursorLine > e.scrollOffset+e.visibleLines {
 		return // Cursor is not in view
 	}
 
-	lines := e.getLines()
 	cursorX := int(xOffset)
 	cursorXOffset := 0
-	if cursorCol > 0 && cursorLine < len(lines) {
-		line := lines[cursorLine][:cursorCol]
-		lbl := material.Label(th, e.fontSize, strings.ReplaceAll(line, "\t", "    "))
-		lbl.Color = e.textColor
-		stack := op.Offset(image.Point{X: cursorX - gtx.Constraints.Max.X, Y: (cursorLine - e.scrollOffset) * int(e.lineHeight)}).Push(gtx.Ops)
-		lbl.Layout(gtx)
-		cursorXOffset = int(measureTextWidth(th, line, e.fontSize))
-		stack.Pop()
+	if cursorCol > 0 {
+		line := e.buf.Slice(pos-cursorCol, pos)
+		cursorXOffset = measureTextWidth(gtx, th, e.expandTabs(line), e.fontSize)
 	}
 
-	cursorY := (cursorLine - e.scrollOffset) * int(e.lineHeight)
+	cursorY := (cursorLine - e.scrollOffset) * e.linePx
+	width, c := e.caretWidth(gtx, th, pos, cursorCol, cursorXOffset), e.Colors.Caret
+	if e.blockCaret() {
+		// The rune under a block caret shows through.
+		c.A /= 2
+	}
 
-	cursorColor := color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 255}
 	paint.FillShape(gtx.Ops,
-		cursorColor,
+		c,
 		clip.Rect{
-			Min: image.Point{X: cursorX + cursorXOffset - gtx.Constraints.Max.X, Y: cursorY},
-			Max: image.Point{X: cursorX + cursorXOffset - gtx.Constraints.Max.X + 2, Y: cursorY + int(e.lineHeight)},
+			Min: image.Point{X: cursorX + cursorXOffset, Y: cursorY},
+			Max: image.Point{X: cursorX + cursorXOffset + width, Y: cursorY + e.linePx},
 		}.Op(),
 	)
 }
 
-func measureTextWidth(th *material.Theme, str string, size unit.Sp) float32 {
+// measureTextWidth returns the advance of str in pixels when laid out the
+// same way as the labels drawn by the editor.
+func measureTextWidth(gtx layout.Context, th *material.Theme, str string, size unit.Sp) int {
 	sh := th.Shaper
-	sh.Layout(text.Parameters{Font: font.Font{Typeface: th.Face}, PxPerEm: fixed.Int26_6(size)}, strings.NewReader(str))
-	var width float32
+	sh.LayoutString(text.Parameters{
+		Font:     font.Font{Typeface: th.Face},
+		PxPerEm:  fixed.I(gtx.Sp(size)),
+		MaxWidth: maxLineWidth,
+		Locale:   gtx.Locale,
+	}, str)
+	var width fixed.Int26_6
 	for {
 		glyph, ok := sh.NextGlyph()
 		if !ok {
 			break
 		}
-		width += float32(glyph.Bounds.Max.X) - float32(glyph.Bounds.Min.X)
+		width += glyph.Advance
 	}
-	return width / 2
+	return width.Round()
+}
+
+// maxLineWidth keeps measured lines from being wrapped.
+const maxLineWidth = 1 << 24
+
+func (e *Editor) expandTabs(line string) string {
+	return strings.ReplaceAll(line, "\t", strings.Repeat(" ", e.tabWidth))
 }
 
 /* func (e *Editor) drawCursor(gtx layout.Context, th *material.Theme, xOffset float32) {
@@ -187,92 +428,96 @@ func measureTextWidth(th *material.Theme, str string, size unit.Sp) float32 {
 	)
 } */
 
+// Insert puts text in place of the selection of every caret, or of the
+// block selection on each of its lines.
 func (e *Editor) Insert(text string) {
-	runes := []rune(text)
-	e.content = append(e.content[:e.cursor], append(runes, e.content[e.cursor:]...)...)
-	e.cursor += len(runes)
-	if text == "\n" {
-		curLine, _ := e.getCursorPosition()
-		if curLine >= e.scrollOffset+e.getVisibleLines() {
-			e.scrollOffset = curLine - e.getVisibleLines() + 1
-		}
+	if e.blockReplace(text) {
+		return
+	}
+	e.eachCaret(func() { e.insert(text, editOther) })
+}
+
+// insert replaces the selection, if any, with text.
+func (e *Editor) insert(text string, kind editKind) {
+	start, end := e.Selection()
+	if start != end {
+		e.history.seal()
 	}
+	e.replace(start, end, text, kind)
 }
 
+// HandleKey runs the command bound to the key, if any. Every key the editor
+// reacts to, down to the arrows, is bound through its commands, unless an
+// incremental search or the Vim layer takes it first.
 func (e *Editor) HandleKey(ev key.Event) {
 	if !e.focused {
 		return
 	}
+	if ev.State == key.Press {
+		e.message = ""
+	}
+	if e.isearch != nil && e.isearchKey(ev) {
+		return
+	}
+	if e.vim != nil && e.vimKey(ev) {
+		return
+	}
+	e.actions.HandleKey(ev, commands.Editor)
+}
 
-	switch ev.State {
-	case key.Press:
-		switch ev.Name {
-		case key.NameLeftArrow:
-			e.MoveCursor(e.cursor - 1)
-		case key.NameRightArrow:
-			e.MoveCursor(e.cursor + 1)
-		case key.NameUpArrow:
-			e.moveCursorUp()
-		case key.NameDownArrow:
-			e.moveCursorDown()
-		case key.NameReturn:
-			e.Insert("\n")
-		case key.NameDeleteBackward:
-			e.backspace()
-		case key.NameDeleteForward:
-			e.delete()
-		default:
-			text := keyEventToText(ev)
-			if text != "" {
-				e.Insert(text)
-			}
-		}
-	case key.Release:
-		if ev.Name == "Tab" {
-			e.Insert("\t")
-		}
+// moveLeft moves the caret one character left, or to the start of the
+// selection when there is one and it isn't being extended.
+func (e *Editor) moveLeft(extend bool) {
+	if start, _ := e.Selection(); e.hasSelection() && !extend {
+		e.MoveCursor(start)
+	} else {
+		e.moveCaret(e.cursor-1, extend)
 	}
 }
 
-func keyEventToText(ev key.Event) string {
-	if ev.Modifiers == 0 && unicode.IsPrint(rune(ev.Name[0])) {
-		if ev.Name == "Space" {
-			return " "
-		} else if ev.Name == "Shift" {
-			return ""
-		}
-		return string(unicode.ToLower(rune(ev.Name[0])))
-	} else if ev.Modifiers == key.ModShift && unicode.IsPrint(rune(ev.Name[0])) {
-		return string(unicode.ToUpper(rune(ev.Name[0])))
+// moveRight is moveLeft the other way.
+func (e *Editor) moveRight(extend bool) {
+	if _, end := e.Selection(); e.hasSelection() && !extend {
+		e.MoveCursor(end)
+	} else {
+		e.moveCaret(e.cursor+1, extend)
 	}
-	return ""
+}
+
+// moveLineStart moves the caret to the start of its line, and moveLineEnd
+// to the end.
+func (e *Editor) moveLineStart(extend bool) {
+	line, _ := e.getCursorPosition()
+	e.moveCaret(e.buf.LineStart(line), extend)
+}
+
+func (e *Editor) moveLineEnd(extend bool) {
+	line, _ := e.getCursorPosition()
+	e.moveCaret(e.buf.LineEnd(line), extend)
 }
 
 func (e *Editor) MoveCursor(pos int) {
-	e.cursor = max(0, min(pos, len(e.content)))
-	e.adjustScrollOffset()
+	e.moveCaret(pos, false)
 }
 
-func (e *Editor) moveCursorUp() {
+func (e *Editor) moveCursorUp(extend bool) {
 	curLine, curCol := e.getCursorPosition()
 	if curLine > 0 {
-		prevLineStart := e.getLineStart(curLine - 1)
-		prevLineEnd := e.getLineEnd(curLine - 1)
-		e.MoveCursor(prevLineStart + min(curCol, prevLineEnd-prevLineStart))
+		e.moveCaret(e.buf.Offset(curLine-1, curCol), extend)
 	}
 }
 
-func (e *Editor) moveCursorDown() {
+func (e *Editor) moveCursorDown(extend bool) {
 	curLine, curCol := e.getCursorPosition()
-	lines := e.getLines()
-	if curLine < len(lines)-1 {
-		nextLineStart := e.getLineStart(curLine + 1)
-		nextLineEnd := e.getLineEnd(curLine + 1)
-		e.MoveCursor(nextLineStart + min(curCol, nextLineEnd-nextLineStart))
+	if curLine < e.buf.LineCount()-1 {
+		e.moveCaret(e.buf.Offset(curLine+1, curCol), extend)
 	}
 }
 
+// adjustScrollOffset scrolls the caret line into view. The horizontal
+// position is fixed up during the next Layout, once text can be measured.
 func (e *Editor) adjustScrollOffset() {
+	e.revealCaret = true
 	curLine, _ := e.getCursorPosition()
 	visibleLines := e.getVisibleLines()
 
@@ -284,59 +529,49 @@ func (e *Editor) adjustScrollOffset() {
 }
 
 func (e *Editor) getVisibleLines() int {
-	return 20 // TODO: Calculate
-}
-
-func (e *Editor) getLineStart(lineNum int) int {
-	lines := e.getLines()
-	if lineNum < 0 || lineNum >= len(lines) {
-		return 0
-	}
-	return strings.Index(string(e.content), strings.Join(lines[:lineNum], "\n")) + 1
+	return max(1, e.visibleLines)
 }
 
-func (e *Editor) getLineEnd(lineNum int) int {
-	lines := e.getLines()
-	if lineNum < 0 || lineNum >= len(lines) {
-		return 0
-	}
-	return strings.Index(string(e.content), strings.Join(lines[:lineNum+1], "\n"))
+func (e *Editor) getCursorPosition() (int, int) {
+	return e.buf.Position(e.cursor)
 }
 
-func (e *Editor) getLines() []string {
-	return strings.Split(string(e.content), "\n")
+func (e *Editor) Delete(start, end int) {
+	e.delete(start, end, editOther)
 }
 
-func (e *Editor) getCursorPosition() (int, int) {
-	//lines := e.getLines()
-	curLine := 0
-	curCol := 0
-	for _, ch := range e.content[:e.cursor] {
-		if ch == '\n' {
-			curLine++
-			curCol = 0
-		} else {
-			curCol++
-		}
+func (e *Editor) delete(start, end int, kind editKind) {
+	if start < end {
+		e.replace(start, end, "", kind)
 	}
-	return curLine, curCol
 }
 
-func (e *Editor) Delete(start, end int) {
-	if start < end {
-		e.content = append(e.content[:start], e.content[end:]...)
-		e.cursor = start
-	}
+// replace is the single place where the buffer is modified, so that every
+// change ends up in the undo history.
+func (e *Editor) replace(start, end int, text string, kind editKind) {
+	anchor, before := e.anchor, e.cursor
+	deleted := e.doc.replace(start, end, text)
+	e.shiftCarets(start, end, utf8.RuneCountInString(text))
+	e.cursor = start + utf8.RuneCountInString(text)
+	e.anchor = e.cursor
+	e.adjustScrollOffset()
+	e.history.record(editOp{pos: start, deleted: deleted, inserted: text}, kind, anchor, before, e.cursor)
 }
 
 func (e *Editor) backspace() {
-	if e.cursor > 0 {
-		e.Delete(e.cursor-1, e.cursor)
+	if e.hasSelection() {
+		start, end := e.Selection()
+		e.delete(start, end, editOther)
+	} else if e.cursor > 0 {
+		e.delete(e.cursor-1, e.cursor, editDeleting)
 	}
 }
 
-func (e *Editor) delete() {
-	if e.cursor < len(e.content) {
-		e.Delete(e.cursor, e.cursor+1)
+func (e *Editor) deleteForward() {
+	if e.hasSelection() {
+		start, end := e.Selection()
+		e.delete(start, end, editOther)
+	} else if e.cursor < e.buf.Len() {
+		e.delete(e.cursor, e.cursor+1, editDeleting)
 	}
 }
@@ -0,0 +1,145 @@
+package editor
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/vypal/vedit/libs/search"
+)
+
+// findState is the search whose matches the editor highlights. The matches
+// are found again whenever the document changes.
+type findState struct {
+	query   *search.Query
+	matches []search.Match
+	// text is what the matches were found in, which regexp replacements
+	// take their submatches from.
+	text string
+	// doc and version tell whether the matches are still those of the text
+	// being edited.
+	doc     *Document
+	version int
+}
+
+// SetQuery highlights every match of q, or nothing if q is nil.
+func (e *Editor) SetQuery(q *search.Query) {
+	e.find = findState{query: q}
+}
+
+func (e *Editor) Query() *search.Query {
+	return e.find.query
+}
+
+// Matches returns the matches of the query in the document, in order.
+func (e *Editor) Matches() []search.Match {
+	f := &e.find
+	if f.query == nil {
+		return nil
+	}
+	if f.doc != e.doc || f.version != e.doc.version {
+		f.text = e.buf.String()
+		f.matches = f.query.FindAll(f.text)
+		f.doc, f.version = e.doc, e.doc.version
+	}
+	return f.matches
+}
+
+// CurrentMatch returns the index of the match that is selected, or -1 if the
+// selection isn't a match.
+func (e *Editor) CurrentMatch() int {
+	start, end := e.Selection()
+	matches := e.Matches()
+	i := sort.Search(len(matches), func(i int) bool { return matches[i].Start >= start })
+	if i < len(matches) && matches[i].Start == start && matches[i].End == end {
+		return i
+	}
+	return -1
+}
+
+// FindFrom selects the first match that starts at or after pos, wrapping
+// around to the start of the document. It reports whether there was one.
+func (e *Editor) FindFrom(pos int) bool {
+	matches := e.Matches()
+	if len(matches) == 0 {
+		return false
+	}
+	i := sort.Search(len(matches), func(i int) bool { return matches[i].Start >= pos })
+	if i == len(matches) {
+		i = 0
+	}
+	e.selectMatch(matches[i])
+	return true
+}
+
+// FindNext selects the match after the selection, or the one before it
+// when backward is set. The search wraps around the ends of the document.
+func (e *Editor) FindNext(backward bool) bool {
+	start, end := e.Selection()
+	if !backward {
+		return e.FindFrom(end)
+	}
+	matches := e.Matches()
+	if len(matches) == 0 {
+		return false
+	}
+	i := sort.Search(len(matches), func(i int) bool { return matches[i].Start >= start }) - 1
+	if i < 0 {
+		i = len(matches) - 1
+	}
+	e.selectMatch(matches[i])
+	return true
+}
+
+func (e *Editor) selectMatch(m search.Match) {
+	e.SetSelection(m.Start, m.End)
+}
+
+// Replace puts replacement in place of the selected match and selects the
+// next one. If no match is selected it only finds the next one.
+func (e *Editor) Replace(replacement string) bool {
+	i := e.CurrentMatch()
+	if i < 0 {
+		return e.FindNext(false)
+	}
+	m := e.find.matches[i]
+	text := e.find.query.Expand(replacement, e.find.text, m)
+	e.history.seal()
+	e.replace(m.Start, m.End, text, editOther)
+	e.FindFrom(m.Start + utf8.RuneCountInString(text))
+	return true
+}
+
+// ReplaceAll replaces every match as a single undoable step and returns how
+// many there were.
+func (e *Editor) ReplaceAll(replacement string) int {
+	matches := e.Matches()
+	if len(matches) == 0 {
+		return 0
+	}
+	q, text := e.find.query, e.find.text
+	e.BeginTransaction()
+	// From the end, so that the offsets of the matches still to be replaced
+	// don't move.
+	for i := len(matches) - 1; i >= 0; i-- {
+		m := matches[i]
+		e.replace(m.Start, m.End, q.Expand(replacement, text, m), editOther)
+	}
+	e.EndTransaction()
+	return len(matches)
+}
+
+// visibleMatches returns the matches that touch the lines from startLine up
+// to endLine.
+func (e *Editor) visibleMatches(startLine, endLine int) []search.Match {
+	matches := e.Matches()
+	if len(matches) == 0 || startLine >= endLine {
+		return nil
+	}
+	from, to := e.buf.LineStart(startLine), e.buf.LineEnd(endLine-1)
+	i := sort.Search(len(matches), func(i int) bool { return matches[i].End > from })
+	j := sort.Search(len(matches), func(i int) bool { return matches[i].Start > to })
+	if i >= j {
+		return nil
+	}
+	return matches[i:j]
+}
@@ -0,0 +1,39 @@
+package editor
+
+import (
+	"image"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/libs/syntax"
+)
+
+// drawLine draws line at x, y in runs colored by spans and returns its width.
+// Styles only change the color, so that measuring text for the caret and the
+// selection doesn't need to know about them.
+func (e *Editor) drawLine(gtx layout.Context, th *material.Theme, line []rune, spans []syntax.Span, x, y int) int {
+	width := 0
+	run := func(start, end int, kind syntax.Kind) {
+		if start >= end {
+			return
+		}
+		str := e.expandTabs(string(line[start:end]))
+		lbl := material.Label(th, e.fontSize, str)
+		lbl.Color = e.Colors.SyntaxColor(kind)
+		stack := op.Offset(image.Point{X: x + width, Y: y}).Push(gtx.Ops)
+		lbl.Layout(gtx)
+		stack.Pop()
+		width += measureTextWidth(gtx, th, str, e.fontSize)
+	}
+
+	pos := 0
+	for _, s := range spans {
+		start, end := max(s.Start, pos), min(s.End, len(line))
+		run(pos, start, syntax.Plain)
+		run(start, end, s.Kind)
+		pos = max(pos, end)
+	}
+	run(pos, len(line), syntax.Plain)
+	return width
+}
@@ -0,0 +1,170 @@
+package editor
+
+import "unicode/utf8"
+
+type editKind uint8
+
+const (
+	editOther editKind = iota
+	editTyping
+	editDeleting
+)
+
+// editOp replaces deleted with inserted at pos.
+type editOp struct {
+	pos      int
+	deleted  string
+	inserted string
+}
+
+type transaction struct {
+	ops          []editOp
+	kind         editKind
+	anchorBefore int
+	cursorBefore int
+	cursorAfter  int
+}
+
+// History records every change made to an editor's buffer so that it can be
+// undone and redone. Consecutive typing or deleting is merged into a single
+// step until the caret is moved some other way.
+type History struct {
+	undo   []transaction
+	redo   []transaction
+	group  *transaction
+	depth  int
+	sealed bool
+	// merging is whether the open group may be merged with other steps.
+	merging bool
+}
+
+func (h *History) CanUndo() bool {
+	return len(h.undo) > 0
+}
+
+func (h *History) CanRedo() bool {
+	return len(h.redo) > 0
+}
+
+// Clear drops all recorded history.
+func (h *History) Clear() {
+	*h = History{}
+}
+
+// seal stops the next edit from being merged into the previous step.
+func (h *History) seal() {
+	h.sealed = true
+}
+
+// begin opens a group of edits, or nests in the open one. When merge is set
+// the group takes the kind of its edits if they are all of one kind, and is
+// merged with the steps around it like a single edit of that kind would be.
+func (h *History) begin(anchor, cursor int, merge bool) {
+	if h.depth == 0 {
+		h.group = &transaction{anchorBefore: anchor, cursorBefore: cursor}
+		h.merging = merge
+	}
+	h.depth++
+}
+
+func (h *History) end(cursor int) {
+	if h.depth == 0 {
+		return
+	}
+	h.depth--
+	if h.depth > 0 {
+		return
+	}
+	g := h.group
+	h.group = nil
+	if len(g.ops) == 0 {
+		return
+	}
+	g.cursorAfter = cursor
+	h.add(*g)
+}
+
+func (h *History) record(op editOp, kind editKind, anchorBefore, cursorBefore, cursorAfter int) {
+	if g := h.group; g != nil {
+		if !h.merging || (len(g.ops) > 0 && g.kind != kind) {
+			kind = editOther
+		}
+		g.ops = append(g.ops, op)
+		g.kind = kind
+		return
+	}
+	h.add(transaction{
+		ops:          []editOp{op},
+		kind:         kind,
+		anchorBefore: anchorBefore,
+		cursorBefore: cursorBefore,
+		cursorAfter:  cursorAfter,
+	})
+}
+
+// add records t as a new step, or as part of the last one when both are
+// typing or both are deleting and nothing has sealed it since.
+func (h *History) add(t transaction) {
+	if n := len(h.undo); n > 0 && !h.sealed && t.kind != editOther && h.undo[n-1].kind == t.kind {
+		last := &h.undo[n-1]
+		last.ops = append(last.ops, t.ops...)
+		last.cursorAfter = t.cursorAfter
+		h.redo = h.redo[:0]
+		return
+	}
+	h.push(t)
+	h.sealed = t.kind == editOther
+}
+
+func (h *History) push(t transaction) {
+	h.undo = append(h.undo, t)
+	h.redo = h.redo[:0]
+}
+
+// BeginTransaction starts grouping edits so that they are undone as one step.
+// Calls may be nested; the group is closed by the outermost EndTransaction.
+func (e *Editor) BeginTransaction() {
+	e.history.begin(e.anchor, e.cursor, false)
+}
+
+func (e *Editor) EndTransaction() {
+	e.history.end(e.cursor)
+}
+
+// History returns the editor's undo history.
+func (e *Editor) History() *History {
+	return e.history
+}
+
+func (e *Editor) Undo() {
+	h := e.history
+	if h.depth > 0 || len(h.undo) == 0 {
+		return
+	}
+	t := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	for i := len(t.ops) - 1; i >= 0; i-- {
+		op := t.ops[i]
+		e.doc.replace(op.pos, op.pos+utf8.RuneCountInString(op.inserted), op.deleted)
+	}
+	h.redo = append(h.redo, t)
+	h.seal()
+	e.carets = nil
+	e.SetSelection(t.anchorBefore, t.cursorBefore)
+}
+
+func (e *Editor) Redo() {
+	h := e.history
+	if h.depth > 0 || len(h.redo) == 0 {
+		return
+	}
+	t := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	for _, op := range t.ops {
+		e.doc.replace(op.pos, op.pos+utf8.RuneCountInString(op.deleted), op.inserted)
+	}
+	h.undo = append(h.undo, t)
+	h.seal()
+	e.carets = nil
+	e.MoveCursor(t.cursorAfter)
+}
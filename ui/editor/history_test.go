@@ -0,0 +1,179 @@
+package editor
+
+import (
+	"testing"
+
+	"gioui.org/io/key"
+)
+
+// newTestEditor returns an editor holding text with the caret at the start.
+func newTestEditor(t *testing.T, text string) *Editor {
+	t.Helper()
+	e := NewEditor(nil)
+	e.doc.replace(0, 0, text)
+	e.doc.dirty = false
+	return e
+}
+
+// typeText types s one rune at a time, as the keyboard delivers it.
+func typeText(e *Editor, s string) {
+	for _, r := range s {
+		start, end := e.Selection()
+		e.handleEdit(key.EditEvent{Range: key.Range{Start: start, End: end}, Text: string(r)})
+	}
+}
+
+func checkText(t *testing.T, e *Editor, want string) {
+	t.Helper()
+	if got := e.buf.String(); got != want {
+		t.Fatalf("text = %q, want %q", got, want)
+	}
+}
+
+func undoAll(e *Editor) int {
+	n := 0
+	for e.history.CanUndo() {
+		e.Undo()
+		n++
+	}
+	return n
+}
+
+func TestHistoryMergesTyping(t *testing.T) {
+	e := newTestEditor(t, "")
+	typeText(e, "hello")
+	checkText(t, e, "hello")
+	e.Undo()
+	checkText(t, e, "")
+	e.Redo()
+	checkText(t, e, "hello")
+	if e.cursor != 5 {
+		t.Errorf("cursor after redo = %d, want 5", e.cursor)
+	}
+}
+
+func TestHistoryMergesDeleting(t *testing.T) {
+	e := newTestEditor(t, "hello world")
+	e.MoveCursor(11)
+	for i := 0; i < 5; i++ {
+		e.backspace()
+	}
+	checkText(t, e, "hello ")
+	e.Undo()
+	checkText(t, e, "hello world")
+	if e.history.CanUndo() {
+		t.Error("deleting five runes left more than one step")
+	}
+}
+
+func TestHistoryKindsAreSeparateSteps(t *testing.T) {
+	e := newTestEditor(t, "")
+	typeText(e, "abc")
+	e.backspace()
+	typeText(e, "d")
+	checkText(t, e, "abd")
+	if n := undoAll(e); n != 3 {
+		t.Errorf("typing, deleting and typing again took %d undos, want 3", n)
+	}
+	checkText(t, e, "")
+}
+
+func TestHistoryMovingSeals(t *testing.T) {
+	e := newTestEditor(t, "")
+	typeText(e, "ab")
+	e.MoveCursor(0)
+	typeText(e, "c")
+	checkText(t, e, "cab")
+	e.Undo()
+	checkText(t, e, "ab")
+	e.Undo()
+	checkText(t, e, "")
+}
+
+func TestHistoryReplacingSelectionSeals(t *testing.T) {
+	e := newTestEditor(t, "")
+	typeText(e, "foo bar")
+	e.SetSelection(4, 7)
+	typeText(e, "baz")
+	checkText(t, e, "foo baz")
+	e.Undo()
+	checkText(t, e, "foo bar")
+	if start, end := e.Selection(); start != 4 || end != 7 {
+		t.Errorf("selection after undo = %d, %d, want 4, 7", start, end)
+	}
+}
+
+func TestHistoryOtherEditsAreNotMerged(t *testing.T) {
+	e := newTestEditor(t, "")
+	e.Insert("\n")
+	e.Insert("\n")
+	if n := undoAll(e); n != 2 {
+		t.Errorf("two line breaks took %d undos, want 2", n)
+	}
+}
+
+func TestHistoryTransaction(t *testing.T) {
+	e := newTestEditor(t, "one two")
+	e.BeginTransaction()
+	e.Delete(0, 4)
+	e.BeginTransaction()
+	e.MoveCursor(3)
+	e.Insert(" three")
+	e.EndTransaction()
+	if e.history.CanUndo() {
+		t.Fatal("a nested EndTransaction closed the group")
+	}
+	e.EndTransaction()
+	checkText(t, e, "two three")
+
+	e.Undo()
+	checkText(t, e, "one two")
+	if e.history.CanUndo() {
+		t.Error("a transaction took more than one undo")
+	}
+	e.Redo()
+	checkText(t, e, "two three")
+	if e.cursor != 9 {
+		t.Errorf("cursor after redo = %d, want 9", e.cursor)
+	}
+}
+
+func TestHistoryTransactionIsSealed(t *testing.T) {
+	e := newTestEditor(t, "")
+	e.BeginTransaction()
+	e.Insert("a")
+	e.EndTransaction()
+	typeText(e, "b")
+	e.Undo()
+	checkText(t, e, "a")
+}
+
+func TestHistoryEmptyTransaction(t *testing.T) {
+	e := newTestEditor(t, "")
+	e.BeginTransaction()
+	e.EndTransaction()
+	if e.history.CanUndo() {
+		t.Error("an empty transaction was recorded")
+	}
+}
+
+func TestHistoryUndoInsideTransaction(t *testing.T) {
+	e := newTestEditor(t, "")
+	typeText(e, "a")
+	e.BeginTransaction()
+	e.Undo()
+	e.EndTransaction()
+	checkText(t, e, "a")
+}
+
+func TestHistoryEditClearsRedo(t *testing.T) {
+	e := newTestEditor(t, "")
+	typeText(e, "a")
+	e.Undo()
+	typeText(e, "b")
+	if e.history.CanRedo() {
+		t.Error("redo survived a new edit")
+	}
+	e.Redo()
+	checkText(t, e, "b")
+}
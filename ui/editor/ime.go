@@ -0,0 +1,102 @@
+package editor
+
+import (
+	"gioui.org/f32"
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+)
+
+// imeState mirrors what was last reported to the input method, so that
+// commands are only sent when something changed.
+type imeState struct {
+	selection key.Range
+	caret     key.Caret
+	snippet   key.Snippet
+}
+
+// handleEdit applies text coming from the keyboard or an input method.
+// Input methods show their composition text by repeatedly replacing the same
+// range, so the preedit appears inline like any other typed text.
+func (e *Editor) handleEdit(ev key.EditEvent) {
+	if !e.focused {
+		return
+	}
+	if e.isearch != nil {
+		e.isearchType(ev.Text)
+		return
+	}
+	if e.vim != nil && !e.vimEdit(ev) {
+		return
+	}
+	start, end := ev.Range.Start, ev.Range.End
+	if start > end {
+		start, end = end, start
+	}
+	// The input method only knows of the main caret. Each caret has the
+	// same change made around its selection as the range is around the
+	// main selection, so that a composition replaces itself at every caret.
+	s, t := e.Selection()
+	before, after := start-s, end-t
+	if before == 0 && after == 0 && e.blockReplace(ev.Text) {
+		return
+	}
+	e.eachCaret(func() {
+		cs, ct := e.Selection()
+		from := max(0, cs+before)
+		to := max(from, min(ct+after, e.buf.Len()))
+		if from == cs && to == ct && cs != ct {
+			e.history.seal()
+		}
+		e.replace(from, to, ev.Text, editTyping)
+	})
+}
+
+// handleIMESelection lets the input method move the caret. Gio ranges put
+// the caret at Start and the anchor at End.
+func (e *Editor) handleIMESelection(ev key.SelectionEvent) {
+	e.SetSelection(ev.End, ev.Start)
+}
+
+// updateIME reports the selection, caret position and the text around it to
+// the input method.
+func (e *Editor) updateIME(gtx layout.Context, th *material.Theme) {
+	sel := key.Range{Start: e.cursor, End: e.anchor}
+	x, y := e.caretPos(gtx, th)
+	caret := key.Caret{
+		Pos:    f32.Point{X: float32(x), Y: float32(y + e.linePx)},
+		Ascent: float32(e.linePx),
+	}
+	if sel != e.ime.selection || caret != e.ime.caret {
+		e.ime.selection = sel
+		e.ime.caret = caret
+		gtx.Execute(key.SelectionCmd{Tag: e, Range: sel, Caret: caret})
+	}
+	e.updateSnippet(gtx, e.ime.snippet.Start, e.ime.snippet.End)
+}
+
+// updateSnippet sends the text between start and end to the input method if
+// it differs from what it last received.
+func (e *Editor) updateSnippet(gtx layout.Context, start, end int) {
+	if start > end {
+		start, end = end, start
+	}
+	start = max(0, min(start, e.buf.Len()))
+	end = max(0, min(end, e.buf.Len()))
+	snippet := key.Snippet{
+		Range: key.Range{Start: start, End: end},
+		Text:  e.buf.Slice(start, end),
+	}
+	if snippet == e.ime.snippet {
+		return
+	}
+	e.ime.snippet = snippet
+	gtx.Execute(key.SnippetCmd{Tag: e, Snippet: snippet})
+}
+
+// caretPos returns the top left corner of the caret in editor coordinates.
+func (e *Editor) caretPos(gtx layout.Context, th *material.Theme) (int, int) {
+	line, col := e.getCursorPosition()
+	x := e.contentOffset - e.scrollX + measureTextWidth(gtx, th, e.expandTabs(e.buf.Slice(e.cursor-col, e.cursor)), e.fontSize)
+	return x, (line - e.scrollOffset) * e.linePx
+}
@@ -0,0 +1,221 @@
+package editor
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gioui.org/io/key"
+	"github.com/vypal/vedit/libs/search"
+)
+
+// isearchState is an incremental search, which finds the pattern while it
+// is typed.
+type isearchState struct {
+	pattern  string
+	backward bool
+	// failing is set when the pattern wasn't found in the direction of the
+	// search. Searching on from there wraps around.
+	failing bool
+	// origin is the selection from before the search, which Cancel goes
+	// back to.
+	origin [2]int
+	// steps are the states before each key typed into the search, for
+	// Backspace to go back through.
+	steps []isearchStep
+}
+
+type isearchStep struct {
+	pattern           string
+	backward, failing bool
+	anchor, cursor    int
+}
+
+func (s *isearchState) prompt() string {
+	p := "I-search"
+	if s.backward {
+		p += " backward"
+	}
+	if s.failing {
+		p = "Failing " + p
+	}
+	return p + ": " + s.pattern
+}
+
+// isearchNext starts an incremental search, or goes on to the next match of
+// the one going on. Going on with nothing typed searches for the pattern of
+// the last search.
+func (e *Editor) isearchNext(backward bool) {
+	s := e.isearch
+	if s == nil {
+		e.isearch = &isearchState{backward: backward, origin: [2]int{e.anchor, e.cursor}}
+		return
+	}
+	if s.pattern == "" && e.lastIsearch == "" {
+		return
+	}
+	e.isearchPush()
+	start, _ := e.Selection()
+	from := start
+	switch {
+	case s.pattern == "":
+		s.pattern, from = e.lastIsearch, e.cursor
+	case backward != s.backward:
+		// Turning around finds the same match first.
+	case s.failing && backward:
+		from = e.buf.Len()
+	case s.failing:
+		from = 0
+	case backward:
+		from = start - 1
+	default:
+		from = start + 1
+	}
+	s.backward = backward
+	e.isearchFind(from)
+}
+
+// isearchType adds text to the pattern and finds it again from the start
+// of the current match.
+func (e *Editor) isearchType(text string) {
+	s := e.isearch
+	e.isearchPush()
+	from, _ := e.Selection()
+	if s.pattern == "" {
+		from = e.cursor
+	}
+	s.pattern += text
+	e.isearchFind(from)
+}
+
+func (e *Editor) isearchPush() {
+	s := e.isearch
+	s.steps = append(s.steps, isearchStep{s.pattern, s.backward, s.failing, e.anchor, e.cursor})
+}
+
+// isearchFind selects the first match that starts at or after from, or the
+// last one that starts at or before it when searching backward.
+func (e *Editor) isearchFind(from int) {
+	s := e.isearch
+	e.SetQuery(isearchQuery(s.pattern))
+	matches := e.Matches()
+	var i int
+	if s.backward {
+		i = sort.Search(len(matches), func(i int) bool { return matches[i].Start > from }) - 1
+	} else {
+		i = sort.Search(len(matches), func(i int) bool { return matches[i].Start >= from })
+	}
+	s.failing = i < 0 || i >= len(matches)
+	if s.failing {
+		return
+	}
+	m := matches[i]
+	if s.backward {
+		e.SetSelection(m.End, m.Start)
+	} else {
+		e.SetSelection(m.Start, m.End)
+	}
+}
+
+// isearchBack goes back to the state before the last key typed into the
+// search.
+func (e *Editor) isearchBack() {
+	s := e.isearch
+	if len(s.steps) == 0 {
+		return
+	}
+	p := s.steps[len(s.steps)-1]
+	s.steps = s.steps[:len(s.steps)-1]
+	s.pattern, s.backward, s.failing = p.pattern, p.backward, p.failing
+	e.SetSelection(p.anchor, p.cursor)
+	e.SetQuery(isearchQuery(s.pattern))
+}
+
+// isearchQuery returns the query for pattern, or nil if it is empty. It
+// ignores case unless the pattern has capitals.
+func isearchQuery(pattern string) *search.Query {
+	if pattern == "" {
+		return nil
+	}
+	// Plain patterns always compile.
+	q, _ := search.Compile(pattern, search.Options{CaseSensitive: strings.IndexFunc(pattern, unicode.IsUpper) >= 0})
+	return q
+}
+
+// isearchEnd ends the search. The caret stays at the match, and the mark is
+// left where the search started, unless cancel is set, which goes back to
+// the selection from before the search.
+func (e *Editor) isearchEnd(cancel bool) {
+	s := e.isearch
+	if s == nil {
+		return
+	}
+	e.isearch = nil
+	e.SetQuery(nil)
+	if s.pattern != "" {
+		e.lastIsearch = s.pattern
+	}
+	if cancel {
+		e.SetSelection(s.origin[0], s.origin[1])
+		e.message = "Quit"
+		return
+	}
+	e.anchor = e.cursor
+	if e.cursor != s.origin[1] {
+		e.mark = mark{doc: e.doc, pos: s.origin[1], version: e.doc.version}
+		e.message = "Mark saved where search started"
+	}
+}
+
+// isearchKey handles ev during an incremental search, reporting whether it
+// was used. The keys of the search commands go on searching and those of
+// Cancel cancel it; text is typed into the pattern through edit events.
+// Other keys end the search and then do what they do.
+func (e *Editor) isearchKey(ev key.Event) bool {
+	if ev.State != key.Press {
+		return true
+	}
+	switch ev.Name {
+	case key.NameCtrl, key.NameShift, key.NameAlt, key.NameCommand, key.NameSuper:
+		return true
+	}
+	switch {
+	case e.keyOf(ev, "find.incremental"):
+		e.isearchNext(false)
+	case e.keyOf(ev, "find.incrementalBackward"):
+		e.isearchNext(true)
+	case e.keyOf(ev, "edit.cancel"):
+		e.isearchEnd(true)
+	case ev.Modifiers&^key.ModShift != 0:
+		e.isearchEnd(false)
+		return false
+	case ev.Name == key.NameDeleteBackward:
+		e.isearchBack()
+	case ev.Name == key.NameReturn || ev.Name == key.NameEnter || ev.Name == key.NameEscape:
+		e.isearchEnd(false)
+	case ev.Name == key.NameSpace || isTextKey(ev.Name):
+		// Typed through the edit event that follows.
+	default:
+		e.isearchEnd(false)
+		return false
+	}
+	return true
+}
+
+// keyOf reports whether ev is the key of a one key chord bound to the
+// command id.
+func (e *Editor) keyOf(ev key.Event, id string) bool {
+	for _, c := range e.actions.KeysOf(id) {
+		if len(c) == 1 && c[0].Matches(ev) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTextKey reports whether a key named name types a character.
+func isTextKey(name key.Name) bool {
+	r, size := utf8.DecodeRuneInString(string(name))
+	return size > 0 && size == len(name) && unicode.IsGraphic(r)
+}
@@ -0,0 +1,151 @@
+package editor
+
+import (
+	"sort"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+)
+
+// doubleClickDuration is the longest pause between clicks that still counts
+// towards a double or triple click.
+const doubleClickDuration = 400 * time.Millisecond
+
+type clickState struct {
+	dragging  bool
+	dragID    pointer.ID
+	clicks    int
+	lastClick time.Duration
+	lastPos   int
+	// origin is the word or line selected by a double or triple click, which
+	// stays selected while dragging extends the selection by whole units.
+	originStart, originEnd int
+	// block is set by an Alt+click, from whose line and visual column
+	// dragging selects a block.
+	block               bool
+	blockLine, blockCol int
+}
+
+func (e *Editor) handlePointer(gtx layout.Context, th *material.Theme, ev pointer.Event) {
+	c := &e.click
+	switch ev.Kind {
+	case pointer.Press:
+		if ev.Buttons != pointer.ButtonPrimary || c.dragging {
+			break
+		}
+		e.isearchEnd(false)
+		gtx.Execute(key.FocusCmd{Tag: e})
+		pos := e.offsetAt(gtx, th, ev.Position)
+		if ev.Time-c.lastClick < doubleClickDuration && pos == c.lastPos {
+			c.clicks = c.clicks%3 + 1
+		} else {
+			c.clicks = 1
+		}
+		c.lastClick = ev.Time
+		c.lastPos = pos
+		c.dragging = true
+		c.dragID = ev.PointerID
+		c.block = false
+
+		switch {
+		case ev.Modifiers.Contain(key.ModAlt) && !ev.Modifiers.Contain(key.ModShift):
+			// Alt+click adds a caret, and dragging selects a block instead.
+			c.clicks = 1
+			e.toggleCaret(pos)
+			c.block = true
+			c.blockLine, c.blockCol = e.blockPoint(gtx, th, ev.Position)
+			return
+		case c.clicks == 1:
+			e.removeCarets()
+		}
+		switch c.clicks {
+		case 1:
+			e.moveCaret(pos, ev.Modifiers.Contain(key.ModShift))
+			c.originStart, c.originEnd = e.Selection()
+		case 2:
+			c.originStart, c.originEnd = e.wordAt(pos)
+			e.SetSelection(c.originStart, c.originEnd)
+		case 3:
+			c.originStart, c.originEnd = e.lineAt(pos)
+			e.SetSelection(c.originStart, c.originEnd)
+		}
+
+	case pointer.Drag:
+		if !c.dragging || ev.PointerID != c.dragID {
+			break
+		}
+		if c.block {
+			// The caret the click added stays until the drag leaves its
+			// column.
+			line, col := e.blockPoint(gtx, th, ev.Position)
+			b := e.activeBlock()
+			if b == nil && (line != c.blockLine || col != c.blockCol) ||
+				b != nil && (line != b.cursorLine || col != b.cursorCol) {
+				e.setBlock(c.blockLine, c.blockCol, line, col)
+			}
+			if ev.Priority < pointer.Grabbed {
+				gtx.Execute(pointer.GrabCmd{Tag: e, ID: c.dragID})
+			}
+			return
+		}
+		pos := e.offsetAt(gtx, th, ev.Position)
+		start, end := pos, pos
+		switch c.clicks {
+		case 1:
+			e.moveCaret(pos, true)
+			return
+		case 2:
+			start, end = e.wordAt(pos)
+		case 3:
+			start, end = e.lineAt(pos)
+		}
+		if start < c.originStart {
+			e.SetSelection(c.originEnd, start)
+		} else {
+			e.SetSelection(c.originStart, max(end, c.originEnd))
+		}
+		if ev.Priority < pointer.Grabbed {
+			gtx.Execute(pointer.GrabCmd{Tag: e, ID: c.dragID})
+		}
+
+	case pointer.Release, pointer.Cancel:
+		c.dragging = false
+	}
+}
+
+// offsetAt maps a point in editor coordinates to the nearest text offset.
+func (e *Editor) offsetAt(gtx layout.Context, th *material.Theme, pt f32.Point) int {
+	line := e.scrollOffset + int(pt.Y)/e.linePx
+	line = max(0, min(line, e.buf.LineCount()-1))
+	return e.buf.LineStart(line) + e.columnAt(gtx, th, e.buf.Line(line), int(pt.X)-e.contentOffset+e.scrollX)
+}
+
+// columnAt returns the column in line whose caret position is closest to x,
+// measured from the start of the text.
+func (e *Editor) columnAt(gtx layout.Context, th *material.Theme, line string, x int) int {
+	runes := []rune(line)
+	width := func(col int) int {
+		return measureTextWidth(gtx, th, e.expandTabs(string(runes[:col])), e.fontSize)
+	}
+	// The last column whose caret lies at or before x.
+	col := sort.Search(len(runes)+1, func(i int) bool {
+		return width(i) > x
+	}) - 1
+	if col < 0 {
+		return 0
+	}
+	if col < len(runes) && x-width(col) > width(col+1)-x {
+		col++
+	}
+	return col
+}
+
+// lineAt returns the range of the line containing pos, including its newline.
+func (e *Editor) lineAt(pos int) (int, int) {
+	line, _ := e.buf.Position(pos)
+	return e.buf.LineStart(line), min(e.buf.LineEnd(line)+1, e.buf.Len())
+}
@@ -0,0 +1,173 @@
+package editor
+
+import (
+	"image"
+
+	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+const (
+	scrollbarWidth    = unit.Dp(10)
+	scrollbarMinThumb = unit.Dp(24)
+	// caretMargin is the room kept between the caret and the edges of the
+	// view when scrolling horizontally to reveal it.
+	caretMargin = unit.Dp(32)
+)
+
+type scrollbar struct {
+	dragging bool
+	dragID   pointer.ID
+	// grab is the distance between the pointer and the top of the thumb.
+	grab float32
+}
+
+func (e *Editor) maxScrollOffset() int {
+	return max(0, e.buf.LineCount()-1)
+}
+
+func (e *Editor) maxScrollX() int {
+	view := e.viewport.X - e.contentOffset
+	return max(0, e.widestLine-view/2)
+}
+
+func (e *Editor) setScrollOffset(line int) {
+	e.scrollOffset = max(0, min(line, e.maxScrollOffset()))
+}
+
+func (e *Editor) setScrollX(x int) {
+	e.scrollX = max(0, min(x, e.maxScrollX()))
+}
+
+func (e *Editor) scrollRangeX() pointer.ScrollRange {
+	return pointer.ScrollRange{Min: -e.scrollX, Max: e.maxScrollX() - e.scrollX}
+}
+
+func (e *Editor) scrollRangeY() pointer.ScrollRange {
+	return pointer.ScrollRange{
+		Min: -e.scrollOffset*e.linePx - int(e.scrollRest),
+		Max: (e.maxScrollOffset()-e.scrollOffset)*e.linePx - int(e.scrollRest),
+	}
+}
+
+// handleScroll scrolls the view without moving the caret. Vertical scrolling
+// moves by whole lines; the remainder is kept so that slow trackpad gestures
+// still add up.
+func (e *Editor) handleScroll(ev pointer.Event) {
+	d := ev.Scroll
+	if ev.Modifiers.Contain(key.ModShift) && d.X == 0 {
+		d = f32.Point{X: d.Y}
+	}
+
+	e.scrollRest += d.Y
+	lines := int(e.scrollRest / float32(e.linePx))
+	e.scrollRest -= float32(lines * e.linePx)
+	e.setScrollOffset(e.scrollOffset + lines)
+	if e.scrollOffset == 0 || e.scrollOffset == e.maxScrollOffset() {
+		e.scrollRest = 0
+	}
+	e.setScrollX(e.scrollX + int(d.X))
+}
+
+// scrollCaretIntoView scrolls horizontally so that the caret is visible.
+func (e *Editor) scrollCaretIntoView(gtx layout.Context, th *material.Theme) {
+	_, col := e.getCursorPosition()
+	x := measureTextWidth(gtx, th, e.expandTabs(e.buf.Slice(e.cursor-col, e.cursor)), e.fontSize)
+	view := gtx.Constraints.Max.X - e.contentOffset - gtx.Dp(scrollbarWidth)
+	margin := min(gtx.Dp(caretMargin), view/4)
+	if x < e.scrollX+margin {
+		e.scrollX = max(0, x-margin)
+	} else if x > e.scrollX+view-margin {
+		e.scrollX = x - view + margin
+	}
+}
+
+// layoutScrollbar draws the vertical scrollbar along the right edge and lets
+// its thumb be dragged or the track be clicked to jump.
+func (e *Editor) layoutScrollbar(gtx layout.Context) {
+	maxScroll := e.maxScrollOffset()
+	if maxScroll == 0 {
+		return
+	}
+	size := gtx.Constraints.Max
+	width := gtx.Dp(scrollbarWidth)
+	track := image.Rect(size.X-width, 0, size.X, size.Y)
+	total := maxScroll + e.visibleLines
+	thumbH := min(size.Y, max(gtx.Dp(scrollbarMinThumb), size.Y*e.visibleLines/total))
+	thumbY := func() int {
+		return (size.Y - thumbH) * e.scrollOffset / maxScroll
+	}
+
+	area := clip.Rect(track).Push(gtx.Ops)
+	event.Op(gtx.Ops, &e.vbar)
+	pointer.CursorDefault.Add(gtx.Ops)
+	for {
+		ev, ok := gtx.Event(pointer.Filter{
+			Target: &e.vbar,
+			Kinds:  pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel,
+		})
+		if !ok {
+			break
+		}
+		pe, ok := ev.(pointer.Event)
+		if !ok {
+			continue
+		}
+
+		switch pe.Kind {
+		case pointer.Press:
+			if e.vbar.dragging {
+				break
+			}
+			y := pe.Position.Y
+			top := float32(thumbY())
+			if y >= top && y < top+float32(thumbH) {
+				e.vbar.grab = y - top
+			} else {
+				e.vbar.grab = float32(thumbH) / 2
+			}
+			e.vbar.dragging = true
+			e.vbar.dragID = pe.PointerID
+			e.scrollToThumb(y-e.vbar.grab, size.Y-thumbH)
+
+		case pointer.Drag:
+			if !e.vbar.dragging || pe.PointerID != e.vbar.dragID {
+				break
+			}
+			e.scrollToThumb(pe.Position.Y-e.vbar.grab, size.Y-thumbH)
+			if pe.Priority < pointer.Grabbed {
+				gtx.Execute(pointer.GrabCmd{Tag: &e.vbar, ID: e.vbar.dragID})
+			}
+
+		case pointer.Release, pointer.Cancel:
+			e.vbar.dragging = false
+		}
+	}
+	area.Pop()
+
+	y := thumbY()
+	paint.FillShape(gtx.Ops,
+		e.Colors.Scrollbar,
+		clip.Rect{
+			Min: image.Point{X: track.Min.X, Y: y},
+			Max: image.Point{X: track.Max.X, Y: y + thumbH},
+		}.Op(),
+	)
+}
+
+// scrollToThumb scrolls so that the top of the thumb is at y within a track
+// of the given travel.
+func (e *Editor) scrollToThumb(y float32, travel int) {
+	if travel <= 0 {
+		return
+	}
+	ratio := max(0, min(y/float32(travel), 1))
+	e.setScrollOffset(int(ratio*float32(e.maxScrollOffset()) + 0.5))
+}
@@ -0,0 +1,136 @@
+package editor
+
+import "unicode"
+
+// The selection is the range between the anchor, where it was started, and
+// the caret (Editor.cursor). When both are equal nothing is selected.
+
+// Selection returns the selected range with start <= end.
+func (e *Editor) Selection() (start, end int) {
+	return min(e.anchor, e.cursor), max(e.anchor, e.cursor)
+}
+
+// SetSelection selects from anchor to caret and moves the caret into view.
+func (e *Editor) SetSelection(anchor, caret int) {
+	e.moveCaret(caret, false)
+	e.anchor = max(0, min(anchor, e.buf.Len()))
+}
+
+func (e *Editor) SelectAll() {
+	e.carets = nil
+	e.SetSelection(0, e.buf.Len())
+}
+
+func (e *Editor) SelectedText() string {
+	start, end := e.Selection()
+	return e.buf.Slice(start, end)
+}
+
+// wordAt returns the range of the word around pos, or of the single rune at
+// pos when it isn't part of a word.
+func (e *Editor) wordAt(pos int) (int, int) {
+	start, end := pos, pos
+	for start > 0 && isWordChar(e.buf.RuneAt(start-1)) {
+		start--
+	}
+	for end < e.buf.Len() && isWordChar(e.buf.RuneAt(end)) {
+		end++
+	}
+	if start == end && end < e.buf.Len() && e.buf.RuneAt(end) != '\n' {
+		end++
+	}
+	return start, end
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (e *Editor) hasSelection() bool {
+	return e.anchor != e.cursor
+}
+
+// moveCaret moves the caret to pos. Unless extend is set the selection is
+// collapsed onto the new position.
+func (e *Editor) moveCaret(pos int, extend bool) {
+	e.history.seal()
+	e.cursor = max(0, min(pos, e.buf.Len()))
+	if !extend {
+		e.anchor = e.cursor
+	}
+	e.adjustScrollOffset()
+}
+
+// wordLeft returns the start of the word before pos, past any characters
+// between that aren't part of a word.
+func (e *Editor) wordLeft(pos int) int {
+	for pos > 0 && !isWordChar(e.buf.RuneAt(pos-1)) {
+		pos--
+	}
+	for pos > 0 && isWordChar(e.buf.RuneAt(pos-1)) {
+		pos--
+	}
+	return pos
+}
+
+// wordRight is wordLeft the other way: the end of the word after pos.
+func (e *Editor) wordRight(pos int) int {
+	n := e.buf.Len()
+	for pos < n && !isWordChar(e.buf.RuneAt(pos)) {
+		pos++
+	}
+	for pos < n && isWordChar(e.buf.RuneAt(pos)) {
+		pos++
+	}
+	return pos
+}
+
+// mark is the other end of the region for the Emacs-style commands. While
+// it is active the caret motions extend the selection from it. It stops
+// being active when the text changes or the selection is put elsewhere.
+type mark struct {
+	doc     *Document
+	pos     int
+	version int
+	active  bool
+}
+
+// marking reports whether the mark is active.
+func (e *Editor) marking() bool {
+	m := e.mark
+	return m.active && m.doc == e.doc && m.version == e.doc.version && m.pos == e.anchor
+}
+
+// setMark puts the mark at the caret and activates it. Setting it again
+// without moving deactivates it.
+func (e *Editor) setMark() {
+	if e.marking() && e.anchor == e.cursor {
+		e.mark.active = false
+		e.message = "Mark deactivated"
+		return
+	}
+	e.anchor = e.cursor
+	e.mark = mark{doc: e.doc, pos: e.cursor, version: e.doc.version, active: true}
+	e.message = "Mark set"
+}
+
+// exchangeMark swaps the caret and the mark, selecting the region between
+// them.
+func (e *Editor) exchangeMark() {
+	if e.mark.doc != e.doc {
+		e.message = "No mark set in this buffer"
+		return
+	}
+	pos := e.cursor
+	e.SetSelection(pos, e.mark.pos)
+	e.mark = mark{doc: e.doc, pos: e.anchor, version: e.doc.version, active: true}
+}
+
+// cancel deactivates the mark, drops the extra carets and collapses the
+// selection onto the caret.
+func (e *Editor) cancel() {
+	e.mark.active = false
+	e.carets = nil
+	e.anchor = e.cursor
+	e.message = "Quit"
+}
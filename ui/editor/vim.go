@@ -0,0 +1,1041 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/ui/commands"
+)
+
+// The Vim layer is an optional set of modes on top of the editor, turned on
+// with SetVim. It reads commands from key events alone, so that feeding a
+// sequence of events to HandleKey edits the text the way the same keys do
+// in Vim. Keys it has no use for go on to the editor's commands.
+
+type vimMode uint8
+
+const (
+	vimNormal vimMode = iota
+	vimInsert
+	vimVisual
+	vimVisualLine
+	vimCommandLine
+)
+
+func (m vimMode) String() string {
+	return [...]string{"NORMAL", "INSERT", "VISUAL", "VISUAL LINE", "COMMAND"}[m]
+}
+
+// Keys without a rune of their own are read as runes from the private use
+// area, so that commands can be matched as strings.
+const (
+	vimEscape rune = 0xE000 + iota
+	vimEnter
+	vimBackspace
+	vimDelete
+	vimTab
+	vimLeft
+	vimRight
+	vimUp
+	vimDown
+	vimHome
+	vimEnd
+	vimLastKey
+)
+
+func isVimKey(r rune) bool {
+	return r >= vimEscape && r < vimLastKey
+}
+
+type vimRegister struct {
+	text     string
+	linewise bool
+}
+
+// vimInput is something typed in insert mode: text, or a key run through
+// the editor's commands, such as Enter.
+type vimInput struct {
+	text string
+	key  key.Event
+}
+
+// vimChange is the last change to the text, for . to repeat: the command
+// and what was typed in insert mode after it.
+type vimChange struct {
+	cmd   vimCommand
+	input []vimInput
+}
+
+type vimState struct {
+	mode vimMode
+	// keys are those of the command typed so far.
+	keys []rune
+	// visual is where the visual selection started; it runs to the caret.
+	visual int
+	// marks are the first and last line of the last visual selection, which
+	// ex commands address as '< and '>.
+	marks     [2]int
+	registers map[rune]vimRegister
+	cmdline   []rune
+	// message is shown instead of the mode until the next key.
+	message string
+	// column is the column j and k keep to, or -1 for the end of the line.
+	column int
+	// find is the last f, F, t or T motion and its rune, for ; and ,.
+	find [2]rune
+	// pattern is the last pattern of :s, for when it is left out.
+	pattern string
+
+	change *vimChange
+	// recording is the change being typed in insert mode.
+	recording *vimChange
+	replaying bool
+	// typed is what was typed since insert mode was entered. It is
+	// inserted repeat times in all, on lines of their own for o and O.
+	typed   []vimInput
+	repeat  int
+	newline bool
+
+	// echo is the rune of the last key pressed. The key's edit event, when
+	// the platform sends one, comes before the next key: in insert mode its
+	// text is typed, in the other modes it is dropped. When none comes the
+	// key's rune is typed instead.
+	echo       rune
+	echoInsert bool
+}
+
+// vimCommand is a normal or visual mode command taken apart.
+type vimCommand struct {
+	register rune
+	// count is 0 when none was typed.
+	count int
+	// op is the operator, such as 'd', or 0.
+	op rune
+	// name is the command, or the motion or text object op applies to. A
+	// doubled operator, such as dd, has op as its name and covers lines.
+	name string
+}
+
+// SetVim turns the Vim layer on or off. It starts in normal mode.
+func (e *Editor) SetVim(on bool) {
+	if on == (e.vim != nil) {
+		return
+	}
+	if !on {
+		e.vimStop()
+		e.vim = nil
+		return
+	}
+	e.vim = &vimState{registers: make(map[rune]vimRegister)}
+	e.vimClamp()
+}
+
+// Vim reports whether the Vim layer is on.
+func (e *Editor) Vim() bool {
+	return e.vim != nil
+}
+
+// vimStatus describes the Vim layer for a status bar: the mode, the command
+// line or a message, and the keys of the command being typed.
+func (e *Editor) vimStatus() (status, keys string) {
+	v := e.vim
+	switch {
+	case v.mode == vimCommandLine:
+		status = ":" + string(v.cmdline)
+	case v.message != "":
+		status = v.message
+	default:
+		status = "-- " + v.mode.String() + " --"
+	}
+	for _, r := range v.keys {
+		if !isVimKey(r) {
+			keys += string(r)
+		}
+	}
+	return status, keys
+}
+
+// vimStop leaves insert mode, if the editor is in it, before the Vim layer
+// is turned off or another document is shown.
+func (e *Editor) vimStop() {
+	v := e.vim
+	if v == nil {
+		return
+	}
+	if v.mode == vimInsert {
+		e.EndTransaction()
+	}
+	v.mode, v.keys, v.cmdline, v.recording = vimNormal, nil, nil, nil
+}
+
+// vimRune reads the key of ev as a rune. Letters are lower case unless
+// Shift is held; Gio already names other keys by what Shift makes of them.
+func vimRune(ev key.Event) (rune, bool) {
+	switch ev.Name {
+	case key.NameEscape:
+		return vimEscape, true
+	case key.NameReturn, key.NameEnter:
+		return vimEnter, true
+	case key.NameDeleteBackward:
+		return vimBackspace, true
+	case key.NameDeleteForward:
+		return vimDelete, true
+	case key.NameTab:
+		return vimTab, true
+	case key.NameLeftArrow:
+		return vimLeft, true
+	case key.NameRightArrow:
+		return vimRight, true
+	case key.NameUpArrow:
+		return vimUp, true
+	case key.NameDownArrow:
+		return vimDown, true
+	case key.NameHome:
+		return vimHome, true
+	case key.NameEnd:
+		return vimEnd, true
+	case key.NameSpace:
+		return ' ', true
+	case key.NamePageUp, key.NamePageDown, key.NameCommand:
+		return 0, false
+	}
+	r, size := utf8.DecodeRuneInString(string(ev.Name))
+	if size == 0 || size != len(ev.Name) || !unicode.IsGraphic(r) {
+		return 0, false
+	}
+	if !ev.Modifiers.Contain(key.ModShift) {
+		r = unicode.ToLower(r)
+	}
+	return r, true
+}
+
+// vimKey handles ev in the Vim layer, reporting whether it was used.
+func (e *Editor) vimKey(ev key.Event) bool {
+	v := e.vim
+	if ev.State != key.Press || e.actions.Pending() {
+		return false
+	}
+	e.vimFlush()
+	r, ok := vimRune(ev)
+	mods := ev.Modifiers &^ key.ModShift
+	if ok && mods == key.ModCtrl && r == '[' {
+		r, mods = vimEscape, 0
+	}
+	if !ok || mods != 0 {
+		if !ok && v.mode != vimInsert {
+			// Modifiers on their own, and keys Vim has no use for.
+			return false
+		}
+		v.message = ""
+		switch {
+		case v.mode == vimInsert:
+			return e.vimInsertCommand(ev)
+		case ok && mods == key.ModCtrl && r == 'r' && v.mode != vimCommandLine:
+			c, _, _ := parseVim(v.keys, false)
+			v.keys = v.keys[:0]
+			for i := 0; i < max(1, c.count); i++ {
+				e.Redo()
+			}
+			e.vimClamp()
+			return true
+		}
+		v.keys = v.keys[:0]
+		return false
+	}
+	v.message = ""
+	if !isVimKey(r) {
+		v.echo, v.echoInsert = r, v.mode == vimInsert
+	}
+	switch v.mode {
+	case vimInsert:
+		if r == vimEscape {
+			e.vimLeaveInsert()
+		} else if isVimKey(r) {
+			e.vimInsertCommand(ev)
+		}
+	case vimCommandLine:
+		e.vimCommandLineKey(r)
+	default:
+		e.vimNormalKey(r)
+	}
+	return true
+}
+
+// vimFlush types the rune of the last key pressed in insert mode if no edit
+// event brought its text.
+func (e *Editor) vimFlush() {
+	v := e.vim
+	if v.echo != 0 && v.echoInsert && v.mode == vimInsert {
+		e.vimType(vimInput{text: string(v.echo)})
+	}
+	v.echo = 0
+}
+
+// vimEdit reports whether the edit event ev should be applied, which it
+// only is in insert mode.
+func (e *Editor) vimEdit(ev key.EditEvent) bool {
+	v := e.vim
+	echo, insert := v.echo, v.echoInsert
+	v.echo = 0
+	if v.mode != vimInsert || (echo != 0 && !insert) {
+		return false
+	}
+	v.typed = append(v.typed, vimInput{text: ev.Text})
+	return true
+}
+
+// vimInsertCommand runs the editor's command for a key pressed in insert
+// mode, such as Enter or Backspace.
+func (e *Editor) vimInsertCommand(ev key.Event) bool {
+	if e.actions.HandleKey(ev, commands.Editor) {
+		e.vim.typed = append(e.vim.typed, vimInput{key: ev})
+	}
+	return true
+}
+
+// vimType types in, remembering it for repeats.
+func (e *Editor) vimType(in vimInput) {
+	e.vimReplay(in)
+	e.vim.typed = append(e.vim.typed, in)
+}
+
+func (e *Editor) vimReplay(in vimInput) {
+	if in.text != "" {
+		e.insert(in.text, editTyping)
+		return
+	}
+	e.actions.HandleKey(in.key, commands.Editor)
+}
+
+// vimStartInsert enters insert mode. What is typed is inserted repeat times
+// in all, on new lines when newline is set.
+func (e *Editor) vimStartInsert(repeat int, newline bool) {
+	v := e.vim
+	v.mode, v.typed, v.repeat, v.newline = vimInsert, nil, repeat, newline
+}
+
+// vimLeaveInsert goes back to normal mode, completing the change that
+// entered insert mode.
+func (e *Editor) vimLeaveInsert() {
+	v := e.vim
+	for i := 1; i < v.repeat; i++ {
+		if v.newline {
+			e.insert("\n", editOther)
+		}
+		for _, in := range v.typed {
+			e.vimReplay(in)
+		}
+	}
+	if v.recording != nil {
+		v.recording.input = v.typed
+		v.change, v.recording = v.recording, nil
+	}
+	v.mode = vimNormal
+	e.EndTransaction()
+	if line := e.lineOf(e.cursor); e.cursor > e.buf.LineStart(line) {
+		e.moveCaret(e.cursor-1, false)
+	}
+	e.vimClamp()
+}
+
+// vimClamp keeps the caret on a character in normal mode, as there is no
+// place for it after the last one.
+func (e *Editor) vimClamp() {
+	if e.vim.mode != vimNormal {
+		return
+	}
+	e.cursor = min(e.cursor, e.lastColumn(e.lineOf(e.cursor)))
+	e.anchor = e.cursor
+}
+
+// vimNormalKey adds r to the command typed in normal or visual mode and
+// runs the command once it is complete.
+func (e *Editor) vimNormalKey(r rune) {
+	v := e.vim
+	visual := v.mode == vimVisual || v.mode == vimVisualLine
+	if r == vimEscape {
+		if visual {
+			e.vimLeaveVisual()
+		}
+		v.keys = v.keys[:0]
+		return
+	}
+	if r == vimDelete && len(v.keys) == 0 {
+		r = 'x'
+	}
+	if v.mode == vimNormal && e.hasSelection() {
+		// Text selected with the mouse is taken up as a visual selection.
+		start, end := e.Selection()
+		v.mode, v.visual = vimVisual, start
+		e.cursor = max(start, end-1)
+		e.anchor = e.cursor
+		visual = true
+	}
+	v.keys = append(v.keys, r)
+	c, done, ok := parseVim(v.keys, visual)
+	if ok && !done {
+		return
+	}
+	v.keys = v.keys[:0]
+	if ok {
+		e.vimExecute(c)
+	}
+}
+
+// parseVim reads keys as a command. done is false while more keys are
+// needed; ok is false when no command starts with keys.
+func parseVim(keys []rune, visual bool) (c vimCommand, done, ok bool) {
+	i := 0
+	if len(keys) > 0 && keys[0] == '"' {
+		if len(keys) < 2 {
+			return c, false, true
+		}
+		c.register, i = keys[1], 2
+	}
+	count := func() int {
+		n := 0
+		for i < len(keys) && keys[i] >= '0' && keys[i] <= '9' && (n > 0 || keys[i] != '0') {
+			n = n*10 + int(keys[i]-'0')
+			i++
+		}
+		return n
+	}
+	c.count = count()
+	if i == len(keys) {
+		return c, false, true
+	}
+	if !visual && strings.ContainsRune("dcy<>", keys[i]) {
+		c.op = keys[i]
+		i++
+		if n := count(); n > 0 {
+			c.count = max(1, c.count) * n
+		}
+		if i == len(keys) {
+			return c, false, true
+		}
+		if keys[i] == c.op {
+			c.name = string(c.op)
+			return c, true, true
+		}
+	}
+	need := 1
+	switch keys[i] {
+	case 'f', 'F', 't', 'T', 'r', 'g', 'Z':
+		need = 2
+	case 'i', 'a':
+		if c.op != 0 || visual {
+			need = 2
+		}
+	}
+	if len(keys)-i < need {
+		return c, false, true
+	}
+	c.name = string(keys[i:])
+	return c, true, true
+}
+
+// isVimChange reports whether c changes the text, for . to repeat it.
+func isVimChange(c vimCommand) bool {
+	if c.op != 0 {
+		return c.op != 'y'
+	}
+	switch c.name {
+	case "x", "X", "D", "C", "s", "S", "p", "P", "J", "gJ", "~", "i", "a", "I", "A", "o", "O":
+		return true
+	}
+	return strings.HasPrefix(c.name, "r") && len(c.name) > 1
+}
+
+// vimExecute runs a complete command. Changes are undone as one step,
+// together with what is typed in insert mode after them.
+func (e *Editor) vimExecute(c vimCommand) {
+	v := e.vim
+	visual := v.mode == vimVisual || v.mode == vimVisualLine
+	if c.name == "u" && !visual {
+		for i := 0; i < max(1, c.count); i++ {
+			e.Undo()
+		}
+		e.vimClamp()
+		return
+	}
+	if !visual && !v.replaying && isVimChange(c) {
+		v.recording = &vimChange{cmd: c}
+	}
+	e.BeginTransaction()
+	switch {
+	case visual:
+		if !e.vimVisualCommand(c) {
+			e.vimMove(c)
+		}
+	case c.op != 0:
+		e.vimOperator(c)
+	case !e.vimCommand(c):
+		e.vimMove(c)
+	}
+	if v.mode == vimInsert {
+		// The change goes on until insert mode is left.
+		return
+	}
+	e.EndTransaction()
+	if v.recording != nil {
+		v.change, v.recording = v.recording, nil
+	}
+	switch c.name {
+	case "j", "k", string(vimUp), string(vimDown):
+	case "$", string(vimEnd):
+		v.column = -1
+	default:
+		_, v.column = e.buf.Position(e.cursor)
+	}
+	e.vimClamp()
+}
+
+// vimMove runs c as a motion, moving the caret or the end of the visual
+// selection.
+func (e *Editor) vimMove(c vimCommand) {
+	if t, ok := e.vimMotion(c.name, c.count, 0, e.cursor); ok {
+		e.moveCaret(t.pos, false)
+	}
+}
+
+// vimCommand runs the normal mode commands that aren't motions, reporting
+// whether c was one.
+func (e *Editor) vimCommand(c vimCommand) bool {
+	v := e.vim
+	n := max(1, c.count)
+	line := e.lineOf(e.cursor)
+	// Commands that are short for an operator with a motion.
+	short := map[string]vimCommand{
+		"x": {op: 'd', name: "l"},
+		"X": {op: 'd', name: "h"},
+		"D": {op: 'd', name: "$"},
+		"C": {op: 'c', name: "$"},
+		"s": {op: 'c', name: "l"},
+		"S": {op: 'c', name: "c"},
+		"Y": {op: 'y', name: "y"},
+	}
+	if s, ok := short[c.name]; ok {
+		s.register, s.count = c.register, c.count
+		e.vimOperator(s)
+		return true
+	}
+	switch c.name {
+	case "i":
+	case "a":
+		if e.cursor < e.buf.LineEnd(line) {
+			e.moveCaret(e.cursor+1, false)
+		}
+	case "I":
+		e.moveCaret(e.firstNonBlank(line), false)
+	case "A":
+		e.moveCaret(e.buf.LineEnd(line), false)
+	case "o":
+		end := e.buf.LineEnd(line)
+		e.replace(end, end, "\n"+e.indentation(line), editOther)
+		e.vimStartInsert(n, true)
+		return true
+	case "O":
+		start, indent := e.buf.LineStart(line), e.indentation(line)
+		e.replace(start, start, indent+"\n", editOther)
+		e.moveCaret(start+utf8.RuneCountInString(indent), false)
+		e.vimStartInsert(n, true)
+		return true
+	case "p", "P":
+		e.vimPut(c.register, n, c.name == "P")
+		return true
+	case "J", "gJ":
+		e.vimJoin(line, max(2, n), c.name == "J")
+		return true
+	case "~":
+		end := min(e.cursor+n, e.buf.LineEnd(line))
+		start := e.cursor
+		e.replace(start, end, toggleCase(e.buf.Slice(start, end)), editOther)
+		return true
+	case ".":
+		e.vimRepeat(c.count)
+		return true
+	case "v", "V":
+		v.visual, v.mode = e.cursor, vimVisual
+		if c.name == "V" {
+			v.mode = vimVisualLine
+		}
+		return true
+	case ":":
+		e.vimStartCommandLine("")
+		return true
+	case "ZZ":
+		e.vimEx("x")
+		return true
+	case "ZQ":
+		e.vimEx("q!")
+		return true
+	default:
+		if r := []rune(c.name); len(r) == 2 && r[0] == 'r' {
+			e.vimReplaceRunes(r[1], n)
+			return true
+		}
+		return false
+	}
+	e.vimStartInsert(n, false)
+	return true
+}
+
+// indentation returns the blanks at the start of line.
+func (e *Editor) indentation(line int) string {
+	return e.buf.Slice(e.buf.LineStart(line), e.firstNonBlank(line))
+}
+
+// vimOperator applies the operator of c to the text its motion moves over.
+func (e *Editor) vimOperator(c vimCommand) {
+	var rg vimRange
+	switch {
+	case c.name == string(c.op):
+		line := e.lineOf(e.cursor)
+		rg = e.linesRange(line, min(line+max(1, c.count)-1, max(line, e.lastLine())))
+	case c.name[0] == 'i' || c.name[0] == 'a':
+		obj, ok := e.vimObject(c.name, e.cursor)
+		if !ok {
+			return
+		}
+		rg = obj
+	default:
+		t, ok := e.vimMotion(c.name, c.count, c.op, e.cursor)
+		if !ok {
+			return
+		}
+		rg = e.targetRange(e.cursor, t)
+	}
+	e.vimApply(c.op, c.register, rg)
+}
+
+func (e *Editor) linesRange(first, last int) vimRange {
+	return vimRange{start: e.buf.LineStart(first), end: e.buf.LineEnd(last), linewise: true}
+}
+
+// targetRange returns the text between from and the target of a motion.
+func (e *Editor) targetRange(from int, t vimTarget) vimRange {
+	start, end := min(from, t.pos), max(from, t.pos)
+	if t.linewise {
+		return e.linesRange(e.lineOf(start), e.lineOf(end))
+	}
+	if t.inclusive {
+		end = min(end+1, e.buf.Len())
+	}
+	return vimRange{start: start, end: end}
+}
+
+// vimApply applies the operator op to rg, keeping the text it removes or
+// copies in register reg.
+func (e *Editor) vimApply(op, reg rune, rg vimRange) {
+	text := e.buf.Slice(rg.start, rg.end)
+	if rg.linewise {
+		text += "\n"
+	}
+	first := e.lineOf(rg.start)
+	switch op {
+	case 'y':
+		e.vim.store(e, reg, text, rg.linewise, true)
+		if !rg.linewise {
+			e.moveCaret(rg.start, false)
+		} else if line, col := e.buf.Position(e.cursor); line != first {
+			e.moveCaret(e.buf.Offset(first, col), false)
+		}
+	case 'd':
+		e.vim.store(e, reg, text, rg.linewise, false)
+		e.vimDelete(rg)
+	case 'c':
+		e.vim.store(e, reg, text, rg.linewise, false)
+		if rg.linewise {
+			e.replace(rg.start, rg.end, e.indentation(first), editOther)
+		} else {
+			e.delete(rg.start, rg.end, editOther)
+			e.moveCaret(rg.start, false)
+		}
+		e.vimStartInsert(1, false)
+	case '>', '<':
+		e.vimShift(first, e.lineOf(max(rg.start, rg.end-1)), op == '<', 1)
+	case '~', 'u', 'U':
+		switch op {
+		case '~':
+			text = toggleCase(text)
+		case 'u':
+			text = strings.ToLower(text)
+		default:
+			text = strings.ToUpper(text)
+		}
+		if rg.linewise {
+			text = strings.TrimSuffix(text, "\n")
+		}
+		e.replace(rg.start, rg.end, text, editOther)
+		e.moveCaret(rg.start, false)
+	}
+}
+
+// vimDelete deletes rg. Whole lines are taken with their line break.
+func (e *Editor) vimDelete(rg vimRange) {
+	if !rg.linewise {
+		e.delete(rg.start, rg.end, editOther)
+		e.moveCaret(rg.start, false)
+		return
+	}
+	line := e.lineOf(rg.start)
+	start, end := rg.start, rg.end
+	if end < e.buf.Len() {
+		end++
+	} else if start > 0 {
+		start--
+	}
+	e.delete(start, end, editOther)
+	e.moveCaret(e.firstNonBlank(min(line, e.lastLine())), false)
+}
+
+// vimShift indents the lines from first to last by a tab count times, or
+// takes that much indentation away when left is set. Empty lines are left
+// alone.
+func (e *Editor) vimShift(first, last int, left bool, count int) {
+	for line := first; line <= last; line++ {
+		start := e.buf.LineStart(line)
+		if e.buf.LineEnd(line) == start {
+			continue
+		}
+		if !left {
+			e.replace(start, start, strings.Repeat("\t", count), editOther)
+			continue
+		}
+		end := start
+		for i := 0; i < count; i++ {
+			// A tab, or up to a tab's width of spaces.
+			if e.runeAt(end) == '\t' {
+				end++
+				continue
+			}
+			for n := 0; n < e.tabWidth && e.runeAt(end) == ' '; n++ {
+				end++
+			}
+		}
+		e.delete(start, end, editOther)
+	}
+	e.moveCaret(e.firstNonBlank(first), false)
+}
+
+// vimJoin joins count lines from line on. J puts a space in between, unless
+// the next line is empty or starts with a closing parenthesis, and drops
+// the next line's indentation; gJ leaves both alone.
+func (e *Editor) vimJoin(line, count int, spaces bool) {
+	pos := e.cursor
+	for i := 1; i < count; i++ {
+		end := e.buf.LineEnd(line)
+		if end >= e.buf.Len() {
+			break
+		}
+		next, sep := end+1, ""
+		if spaces {
+			for next < e.buf.Len() && isBlank(e.buf.RuneAt(next)) {
+				next++
+			}
+			if r := e.runeAt(next); r != '\n' && r != ')' && end > e.buf.LineStart(line) && !isBlank(e.runeAt(end-1)) {
+				sep = " "
+			}
+		}
+		e.replace(end, next, sep, editOther)
+		pos = end
+	}
+	e.moveCaret(pos, false)
+}
+
+// vimReplaceRunes puts r in place of the count runes from the caret on.
+// Enter splits the line instead.
+func (e *Editor) vimReplaceRunes(r rune, count int) {
+	end := e.cursor + count
+	if end > e.buf.LineEnd(e.lineOf(e.cursor)) || (isVimKey(r) && r != vimEnter) {
+		return
+	}
+	if r == vimEnter {
+		e.replace(e.cursor, end, "\n", editOther)
+		return
+	}
+	start := e.cursor
+	e.replace(start, end, strings.Repeat(string(r), count), editOther)
+	e.moveCaret(end-1, false)
+}
+
+// vimPut pastes register reg count times after the caret, or before it when
+// before is set. Lines are put below or above the caret line.
+func (e *Editor) vimPut(reg rune, count int, before bool) {
+	v := e.vim
+	line := e.lineOf(e.cursor)
+	if reg == '+' || reg == '*' {
+		// The system clipboard arrives during the next Layout.
+		if !before && e.cursor < e.buf.LineEnd(line) {
+			e.moveCaret(e.cursor+1, false)
+		}
+		e.Paste()
+		return
+	}
+	r, ok := v.register(reg)
+	if !ok {
+		v.message = "Nothing in register " + string(vimRegisterName(reg))
+		return
+	}
+	e.putRegister(r, count, before)
+}
+
+// putRegister pastes the contents of r as p or P would.
+func (e *Editor) putRegister(r vimRegister, count int, before bool) {
+	line := e.lineOf(e.cursor)
+	text := strings.Repeat(r.text, count)
+	if r.linewise {
+		var pos int
+		if before {
+			pos = e.buf.LineStart(line)
+			e.replace(pos, pos, text, editOther)
+		} else {
+			end := e.buf.LineEnd(line)
+			e.replace(end, end, "\n"+strings.TrimSuffix(text, "\n"), editOther)
+			pos = end + 1
+		}
+		e.moveCaret(e.firstNonBlank(e.lineOf(pos)), false)
+		return
+	}
+	pos := e.cursor
+	if !before && pos < e.buf.LineEnd(line) {
+		pos++
+	}
+	e.replace(pos, pos, text, editOther)
+	e.moveCaret(pos+utf8.RuneCountInString(text)-1, false)
+}
+
+func vimRegisterName(reg rune) rune {
+	if reg == 0 {
+		return '"'
+	}
+	return reg
+}
+
+// register returns the contents of register reg. Upper case names are
+// the same registers as lower case ones.
+func (v *vimState) register(reg rune) (vimRegister, bool) {
+	r, ok := v.registers[unicode.ToLower(vimRegisterName(reg))]
+	return r, ok && r.text != ""
+}
+
+// store keeps text that was yanked or deleted in register reg. Upper case
+// names append to the register. Without a name text also goes to register
+// 0 when yanked, or shifts through registers 1 to 9 when deleted; the
+// unnamed register always gets it. The black hole register _ keeps
+// nothing, and + and * are the system clipboard.
+func (v *vimState) store(e *Editor, reg rune, text string, linewise, yank bool) {
+	r := vimRegister{text: text, linewise: linewise}
+	switch {
+	case reg == '_':
+		return
+	case reg == '+' || reg == '*':
+		e.writeClipboard(text)
+	case reg >= 'A' && reg <= 'Z':
+		old := v.registers[unicode.ToLower(reg)]
+		if old.linewise && !linewise {
+			r.text += "\n"
+		}
+		r = vimRegister{text: old.text + r.text, linewise: old.linewise || linewise}
+		v.registers[unicode.ToLower(reg)] = r
+	case reg >= 'a' && reg <= 'z':
+		v.registers[reg] = r
+	case yank:
+		v.registers['0'] = r
+	default:
+		for i := '9'; i > '1'; i-- {
+			v.registers[i] = v.registers[i-1]
+		}
+		v.registers['1'] = r
+	}
+	v.registers['"'] = r
+}
+
+// vimRepeat repeats the last change, with count in place of its own if one
+// is given.
+func (e *Editor) vimRepeat(count int) {
+	v := e.vim
+	if v.change == nil {
+		return
+	}
+	if count > 0 {
+		v.change.cmd.count = count
+	}
+	ch := *v.change
+	v.replaying = true
+	e.vimExecute(ch.cmd)
+	if v.mode == vimInsert {
+		for _, in := range ch.input {
+			e.vimType(in)
+		}
+		e.vimLeaveInsert()
+	}
+	v.replaying = false
+}
+
+// visualRange returns the visually selected text, which includes the rune
+// under the caret.
+func (e *Editor) visualRange() vimRange {
+	v := e.vim
+	start, end := min(v.visual, e.cursor), max(v.visual, e.cursor)
+	if v.mode == vimVisualLine {
+		return e.linesRange(e.lineOf(start), e.lineOf(end))
+	}
+	return vimRange{start: start, end: min(end+1, e.buf.Len())}
+}
+
+// vimLeaveVisual goes back to normal mode, remembering the lines that were
+// selected.
+func (e *Editor) vimLeaveVisual() {
+	v := e.vim
+	v.marks = [2]int{e.lineOf(min(v.visual, e.cursor)), e.lineOf(max(v.visual, e.cursor))}
+	v.mode = vimNormal
+}
+
+// vimVisualCommand runs the commands that work on the visual selection,
+// reporting whether c was one.
+func (e *Editor) vimVisualCommand(c vimCommand) bool {
+	v := e.vim
+	rg := e.visualRange()
+	lines := e.linesRange(e.lineOf(rg.start), e.lineOf(max(rg.start, rg.end-1)))
+	ops := map[string]rune{
+		"d": 'd', "x": 'd', "y": 'y', "c": 'c', "s": 'c', ">": '>', "<": '<', "~": '~', "u": 'u', "U": 'U',
+	}
+	lineOps := map[string]rune{"D": 'd', "X": 'd', "Y": 'y', "C": 'c', "S": 'c', "R": 'c'}
+	if op, ok := ops[c.name]; ok {
+		e.vimLeaveVisual()
+		e.vimApply(op, c.register, rg)
+		return true
+	}
+	if op, ok := lineOps[c.name]; ok {
+		e.vimLeaveVisual()
+		e.vimApply(op, c.register, lines)
+		return true
+	}
+	switch c.name {
+	case "J", "gJ":
+		e.vimLeaveVisual()
+		first := e.lineOf(lines.start)
+		e.vimJoin(first, max(2, e.lineOf(lines.end)-first+1), c.name == "J")
+	case "p", "P":
+		// The register is read before the selection it replaces is
+		// deleted into the unnamed register.
+		r, ok := v.register(c.register)
+		e.vimLeaveVisual()
+		if !ok {
+			v.message = "Nothing in register " + string(vimRegisterName(c.register))
+			break
+		}
+		e.vimApply('d', 0, rg)
+		// Lines go where the selection was: above the line after it, or
+		// below the last line when that was deleted.
+		before := !r.linewise || (rg.linewise && (rg.end < e.buf.Len() || rg.start == 0))
+		e.putRegister(r, 1, before)
+	case "o":
+		v.visual, e.cursor = e.cursor, v.visual
+		e.anchor = e.cursor
+	case "v", "V":
+		mode := vimVisual
+		if c.name == "V" {
+			mode = vimVisualLine
+		}
+		if v.mode == mode {
+			e.vimLeaveVisual()
+		} else {
+			v.mode = mode
+		}
+	case ":":
+		e.vimLeaveVisual()
+		e.vimStartCommandLine("'<,'>")
+	default:
+		if r := []rune(c.name); len(r) == 2 && (r[0] == 'i' || r[0] == 'a') {
+			if obj, ok := e.vimObject(c.name, e.cursor); ok && obj.end > obj.start {
+				v.visual = obj.start
+				e.moveCaret(obj.end-1, false)
+			}
+			return true
+		}
+		if r := []rune(c.name); len(r) == 2 && r[0] == 'r' && !isVimKey(r[1]) {
+			e.vimLeaveVisual()
+			var b strings.Builder
+			for _, c := range e.buf.Slice(rg.start, rg.end) {
+				if c != '\n' {
+					c = r[1]
+				}
+				b.WriteRune(c)
+			}
+			e.replace(rg.start, rg.end, b.String(), editOther)
+			e.moveCaret(rg.start, false)
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// vimStartCommandLine enters command-line mode with text typed already.
+func (e *Editor) vimStartCommandLine(text string) {
+	v := e.vim
+	v.mode, v.cmdline = vimCommandLine, []rune(text)
+}
+
+// vimCommandLineKey edits the command line. Enter runs it.
+func (e *Editor) vimCommandLineKey(r rune) {
+	v := e.vim
+	switch r {
+	case vimEscape:
+		v.mode, v.cmdline = vimNormal, nil
+	case vimEnter:
+		cmd := string(v.cmdline)
+		v.mode, v.cmdline = vimNormal, nil
+		e.vimEx(cmd)
+	case vimBackspace:
+		if len(v.cmdline) == 0 {
+			v.mode = vimNormal
+			return
+		}
+		v.cmdline = v.cmdline[:len(v.cmdline)-1]
+	default:
+		if !isVimKey(r) {
+			v.cmdline = append(v.cmdline, r)
+		}
+	}
+	e.vimClamp()
+}
+
+// shownSelection returns the range drawn as selected. In Vim's visual modes
+// that is the visual selection, which includes the rune under the caret.
+func (e *Editor) shownSelection() (int, int) {
+	v := e.vim
+	if v == nil || (v.mode != vimVisual && v.mode != vimVisualLine) {
+		return e.Selection()
+	}
+	rg := e.visualRange()
+	if rg.linewise {
+		rg.end = min(rg.end+1, e.buf.Len())
+	}
+	return rg.start, rg.end
+}
+
+// blockCaret reports whether the caret is drawn as a block over the rune
+// under it, as it is outside Vim's insert mode.
+func (e *Editor) blockCaret() bool {
+	return e.vim != nil && e.vim.mode != vimInsert
+}
+
+// caretWidth returns how wide the caret is drawn at column col of its line,
+// whose text up to the caret is x pixels wide.
+func (e *Editor) caretWidth(gtx layout.Context, th *material.Theme, pos, col, x int) int {
+	if !e.blockCaret() {
+		return 2
+	}
+	if e.runeAt(pos) == '\n' {
+		return measureTextWidth(gtx, th, " ", e.fontSize)
+	}
+	line := e.buf.Slice(pos-col, pos+1)
+	return max(2, measureTextWidth(gtx, th, e.expandTabs(line), e.fontSize)-x)
+}
@@ -0,0 +1,107 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"gioui.org/io/key"
+)
+
+// vimKeys presses the keys of s in turn, as the keyboard names them. <Esc>
+// and <CR> stand for Escape and Enter.
+func vimKeys(e *Editor, s string) {
+	for s != "" {
+		var ev key.Event
+		switch {
+		case strings.HasPrefix(s, "<Esc>"):
+			ev, s = key.Event{Name: key.NameEscape}, s[len("<Esc>"):]
+		case strings.HasPrefix(s, "<CR>"):
+			ev, s = key.Event{Name: key.NameReturn}, s[len("<CR>"):]
+		default:
+			r := []rune(s)[0]
+			s = s[len(string(r)):]
+			ev = key.Event{Name: key.Name(string(unicode.ToUpper(r)))}
+			if r == ' ' {
+				ev.Name = key.NameSpace
+			}
+			if unicode.IsUpper(r) {
+				ev.Modifiers = key.ModShift
+			}
+		}
+		ev.State = key.Press
+		e.HandleKey(ev)
+	}
+}
+
+func TestVim(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		keys   string
+		want   string
+		cursor int
+	}{
+		{"x", "abc", "x", "bc", 0},
+		{"x with count", "abcdef", "3x", "def", 0},
+		{"w and dw", "one two three", "wdw", "one three", 4},
+		{"d with count and motion", "one two three four", "d2w", "three four", 0},
+		{"count on operator and motion", "a b c d e f", "2d2w", "e f", 0},
+		{"de", "one two", "de", " two", 0},
+		{"d$", "one two\nthree", "wd$", "one \nthree", 3},
+		{"dd", "one\ntwo\nthree", "jdd", "one\nthree", 4},
+		{"dd with count", "one\ntwo\nthree\nfour", "2dd", "three\nfour", 0},
+		{"dj", "one\ntwo\nthree", "dj", "three", 0},
+		{"G", "one\ntwo\nthree", "Gx", "one\ntwo\nhree", 8},
+		{"gg", "one\ntwo\nthree", "Gggx", "ne\ntwo\nthree", 0},
+		{"count G", "one\ntwo\nthree", "2Gx", "one\nwo\nthree", 4},
+		{"Gdd after final newline", "line1\nline2\nline3\n", "Gdd", "line1\nline2\n", 6},
+		{"j stops at last line", "line1\nline2\n", "jjdd", "line1\n", 0},
+		{"dd with count past the end", "a\nb\nc\n", "j5dd", "a\n", 0},
+		{"dG", "a\nb\nc\n", "jdG", "a\n", 0},
+		{"cw", "one two", "cwsix<Esc>", "six two", 2},
+		{"ciw", "foo(bar)", "fbci(x<Esc>", "foo(x)", 4},
+		{"o", "one\nthree", "otwo<Esc>", "one\ntwo\nthree", 6},
+		{"A", "one", "A two<Esc>", "one two", 6},
+		{"insert with count", "x", "3ia<Esc>", "aaax", 2},
+		{"yank and put", "one\ntwo", "yyjp", "one\ntwo\none", 8},
+		{"put before", "one\ntwo", "jyykP", "two\none\ntwo", 0},
+		{"named register", "one\ntwo\nthree", "\"ayyjdd\"ap", "one\nthree\none", 10},
+		{"appending register", "a\nb\nc", "\"ayyj\"Ayyj\"ap", "a\nb\nc\na\nb", 6},
+		{"delete keeps yank register", "one\ntwo", "yyjdd\"0P", "one\none", 0},
+		{"black hole register", "one\ntwo", "yyj\"_ddp", "one\none", 4},
+		{"dot repeats x", "abcdef", "x..", "def", 0},
+		{"dot repeats dw", "a b c d", "dw.", "c d", 0},
+		{"dot takes a new count", "a b c d e", "dw3.", "e", 0},
+		{"dot repeats insert", "x\ny", "Ai<Esc>j.", "xi\nyi", 4},
+		{"dot repeats dd", "1\n2\n3\n4", "dd.", "3\n4", 0},
+		{"ex substitute", "foo foo\nfoo", ":%s/foo/bar/<CR>", "bar foo\nbar", 8},
+		{"ex substitute everywhere", "foo foo\nfoo", ":%s/foo/bar/g<CR>", "bar bar\nbar", 8},
+		{"ex substitute after final newline", "a\na\n", ":%s/a/b/<CR>", "b\nb\n", 2},
+		{"ex delete last line", "a\nb\n", ":$d<CR>", "a\n", 0},
+		{"undo", "one two", "dwdwu", "two", 0},
+		{"undo insert", "one", "Atwo<Esc>u", "one", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEditor(t, tt.text)
+			e.SetVim(true)
+			vimKeys(e, tt.keys)
+			checkText(t, e, tt.want)
+			if e.cursor != tt.cursor {
+				t.Errorf("cursor = %d, want %d", e.cursor, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestVimVisual(t *testing.T) {
+	e := newTestEditor(t, "one two three")
+	e.SetVim(true)
+	vimKeys(e, "wvey")
+	if got := e.vim.registers['"'].text; got != "two" {
+		t.Errorf("yanked %q, want %q", got, "two")
+	}
+	vimKeys(e, "Vd")
+	checkText(t, e, "")
+}
@@ -0,0 +1,364 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vypal/vedit/libs/search"
+)
+
+// vimEx runs an ex command, typed after a colon in Vim's command-line mode.
+// It understands a range of lines, then :w, :q, :wq, :x, :s, :d, :y and
+// :noh, or a line number on its own to go to.
+func (e *Editor) vimEx(cmd string) {
+	v := e.vim
+	from, to, rest, given, ok := e.exRange(strings.TrimSpace(cmd))
+	if !ok {
+		v.message = "Invalid range"
+		return
+	}
+	rest = strings.TrimSpace(rest)
+	i := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsLetter(r) })
+	if i < 0 {
+		i = len(rest)
+	}
+	name, args := rest[:i], rest[i:]
+	bang := strings.HasPrefix(args, "!")
+	if bang {
+		args = args[1:]
+	}
+	switch name {
+	case "":
+		if given {
+			e.moveCaret(e.firstNonBlank(to), false)
+		}
+	case "w", "write":
+		e.Save()
+		if !e.doc.Dirty() && e.doc.HasFile() {
+			v.message = fmt.Sprintf("%q written", e.doc.Title())
+		}
+	case "q", "quit":
+		if e.doc.Dirty() && !bang {
+			v.message = "No write since last change (add ! to override)"
+			return
+		}
+		e.vimClose()
+	case "wq", "x", "xit", "exit":
+		if name == "wq" || e.doc.Dirty() {
+			e.Save()
+		}
+		if !e.doc.Dirty() {
+			e.vimClose()
+		}
+	case "s", "substitute":
+		e.vimSubstitute(from, to, args)
+	case "d", "delete", "y", "yank":
+		rg := e.linesRange(from, to)
+		reg := rune(0)
+		if r, _ := utf8.DecodeRuneInString(strings.TrimSpace(args)); r != utf8.RuneError {
+			reg = r
+		}
+		e.BeginTransaction()
+		e.vimApply(rune(name[0]), reg, rg)
+		e.EndTransaction()
+	case "noh", "nohlsearch":
+		e.SetQuery(nil)
+	default:
+		v.message = "Not an editor command: " + strings.TrimSpace(cmd)
+	}
+	e.vimClamp()
+}
+
+func (e *Editor) vimClose() {
+	if e.OnClose != nil {
+		e.OnClose()
+	}
+}
+
+// exRange reads the range of lines at the start of cmd: % for every line,
+// or one or two addresses separated by a comma. Addresses are line numbers,
+// . for the caret line, $ for the last line and '< and '> for the lines of
+// the last visual selection, each optionally followed by +n or -n. Without
+// a range the caret line is used and given is false.
+func (e *Editor) exRange(cmd string) (from, to int, rest string, given, ok bool) {
+	cur, last := e.lineOf(e.cursor), e.lastLine()
+	if strings.HasPrefix(cmd, "%") {
+		return 0, last, cmd[1:], true, true
+	}
+	s := cmd
+	address := func() (int, bool) {
+		line := 0
+		switch {
+		case s == "":
+			return 0, false
+		case s[0] == '.':
+			line, s = cur, s[1:]
+		case s[0] == '$':
+			line, s = last, s[1:]
+		case strings.HasPrefix(s, "'<"):
+			line, s = e.vim.marks[0], s[2:]
+		case strings.HasPrefix(s, "'>"):
+			line, s = e.vim.marks[1], s[2:]
+		case s[0] >= '0' && s[0] <= '9':
+			n, rest := leadingNumber(s)
+			line, s = n-1, rest
+		case s[0] == '+' || s[0] == '-':
+			line = cur
+		default:
+			return 0, false
+		}
+		for len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+			sign := 1
+			if s[0] == '-' {
+				sign = -1
+			}
+			n, rest := leadingNumber(s[1:])
+			if rest == s[1:] {
+				n = 1
+			}
+			line, s = line+sign*n, rest
+		}
+		return max(0, min(line, last)), true
+	}
+	from, found := address()
+	if !found {
+		return cur, cur, cmd, false, true
+	}
+	to = from
+	if strings.HasPrefix(s, ",") {
+		s = s[1:]
+		if to, found = address(); !found {
+			return 0, 0, "", false, false
+		}
+	}
+	return min(from, to), max(from, to), s, true, true
+}
+
+// leadingNumber reads the digits at the start of s.
+func leadingNumber(s string) (int, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	n, _ := strconv.Atoi(s[:i])
+	return n, s[i:]
+}
+
+// vimSubstitute runs :s/pattern/replacement/flags over the lines from from
+// to to. With the g flag every match on a line is replaced, otherwise only
+// the first; i and I make the pattern ignore case or not.
+func (e *Editor) vimSubstitute(from, to int, args string) {
+	v := e.vim
+	delim, size := utf8.DecodeRuneInString(args)
+	if size == 0 || unicode.IsLetter(delim) || unicode.IsDigit(delim) || unicode.IsSpace(delim) || delim == '\\' || delim == '"' {
+		v.message = "Usage: s/pattern/replacement/flags"
+		return
+	}
+	parts := splitEx(args[size:], delim)
+	pattern := parts[0]
+	if pattern == "" {
+		pattern = v.pattern
+	}
+	if pattern == "" {
+		v.message = "No previous pattern"
+		return
+	}
+	v.pattern = pattern
+	var replacement, flags string
+	if len(parts) > 1 {
+		replacement = parts[1]
+	}
+	if len(parts) > 2 {
+		flags = parts[2]
+	}
+	opts := search.Options{Regexp: true, CaseSensitive: true}
+	global := false
+	for _, f := range flags {
+		switch f {
+		case 'g':
+			global = true
+		case 'i':
+			opts.CaseSensitive = false
+		case 'I':
+			opts.CaseSensitive = true
+		}
+	}
+	q, err := search.Compile(vimPattern(pattern), opts)
+	if err != nil {
+		v.message = "Invalid pattern: " + pattern
+		return
+	}
+	replacement = vimReplacement(replacement)
+
+	text := e.buf.String()
+	lo, hi := e.buf.LineStart(from), e.buf.LineEnd(to)
+	var matches []search.Match
+	lines := 0
+	lastLine := -1
+	for _, m := range q.FindAll(text) {
+		if m.Start < lo {
+			continue
+		}
+		if m.Start > hi {
+			break
+		}
+		line := e.lineOf(m.Start)
+		if line == lastLine && !global {
+			continue
+		}
+		if line != lastLine {
+			lines++
+		}
+		matches = append(matches, m)
+		lastLine = line
+	}
+	if len(matches) == 0 {
+		v.message = "Pattern not found: " + pattern
+		return
+	}
+	texts := make([]string, len(matches))
+	// The last match moves by what the replacements before it add.
+	shift := 0
+	for i, m := range matches {
+		texts[i] = q.Expand(replacement, text, m)
+		if i < len(matches)-1 {
+			shift += utf8.RuneCountInString(texts[i]) - (m.End - m.Start)
+		}
+	}
+	e.BeginTransaction()
+	for i := len(matches) - 1; i >= 0; i-- {
+		e.replace(matches[i].Start, matches[i].End, texts[i], editOther)
+	}
+	e.EndTransaction()
+	e.moveCaret(e.firstNonBlank(e.lineOf(matches[len(matches)-1].Start+shift)), false)
+	v.message = fmt.Sprintf("%s on %s", plural(len(matches), "substitution"), plural(lines, "line"))
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// splitEx splits s at the occurrences of delim that aren't escaped with a
+// backslash, into at most three parts. Escaped delimiters lose their
+// backslash; other escapes are kept.
+func splitEx(s string, delim rune) []string {
+	var parts []string
+	var b strings.Builder
+	r := []rune(s)
+	for i := 0; i < len(r); i++ {
+		switch {
+		case r[i] == '\\' && i+1 < len(r):
+			if r[i+1] != delim {
+				b.WriteRune('\\')
+			}
+			i++
+			b.WriteRune(r[i])
+		case r[i] == delim && len(parts) < 2:
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteRune(r[i])
+		}
+	}
+	return append(parts, b.String())
+}
+
+// vimPattern turns a pattern in Vim's magic syntax into Go's: (, ), |, +, ?,
+// { and } are literal unless escaped, \< and \> match at word boundaries
+// and \= is ?. Escapes such as \d and \s mean what they do in Go.
+func vimPattern(p string) string {
+	var b strings.Builder
+	r := []rune(p)
+	inClass, inBrace := false, false
+	for i := 0; i < len(r); i++ {
+		c := r[i]
+		switch {
+		case inClass:
+			if c == '\\' && i+1 < len(r) {
+				b.WriteRune(c)
+				i++
+				c = r[i]
+			} else if c == ']' {
+				inClass = false
+			}
+			b.WriteRune(c)
+		case c == '\\' && i+1 < len(r):
+			i++
+			switch d := r[i]; d {
+			case '(', ')', '|', '+', '?':
+				b.WriteRune(d)
+			case '{':
+				inBrace = true
+				b.WriteRune(d)
+			case '=':
+				b.WriteRune('?')
+			case '<', '>':
+				b.WriteString(`\b`)
+			default:
+				b.WriteRune('\\')
+				b.WriteRune(d)
+			}
+		case c == '}' && inBrace:
+			inBrace = false
+			b.WriteRune(c)
+		case strings.ContainsRune("()|+?{}", c):
+			b.WriteRune('\\')
+			b.WriteRune(c)
+		case c == '[':
+			inClass = true
+			b.WriteRune(c)
+			// A ] right after [ or [^ is part of the class.
+			if i+1 < len(r) && r[i+1] == '^' {
+				i++
+				b.WriteRune(r[i])
+			}
+			if i+1 < len(r) && r[i+1] == ']' {
+				i++
+				b.WriteString(`\]`)
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// vimReplacement turns the replacement of :s from Vim's syntax, where & and
+// \0 stand for the match and \1 to \9 for submatches, into the syntax of
+// search.Query.Expand. \r and \n break the line.
+func vimReplacement(s string) string {
+	var b strings.Builder
+	r := []rune(s)
+	for i := 0; i < len(r); i++ {
+		c := r[i]
+		switch {
+		case c == '\\' && i+1 < len(r):
+			i++
+			switch d := r[i]; {
+			case d >= '0' && d <= '9':
+				fmt.Fprintf(&b, "${%c}", d)
+			case d == 'n' || d == 'r':
+				b.WriteRune('\n')
+			case d == 't':
+				b.WriteRune('\t')
+			case d == '$':
+				b.WriteString("$$")
+			default:
+				b.WriteRune(d)
+			}
+		case c == '&':
+			b.WriteString("${0}")
+		case c == '$':
+			b.WriteString("$$")
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,497 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+)
+
+// vimTarget is where a Vim motion takes the caret. An operator applied with
+// the motion covers the text from the caret up to pos, including pos when
+// inclusive is set, or every line in between when linewise is set.
+type vimTarget struct {
+	pos       int
+	linewise  bool
+	inclusive bool
+}
+
+// vimRange is the text an operator applies to. A linewise range runs from
+// the start of its first line to the end of its last one, without the last
+// line break.
+type vimRange struct {
+	start, end int
+	linewise   bool
+}
+
+// runeAt returns the rune at pos, or a line break past the end of the text.
+func (e *Editor) runeAt(pos int) rune {
+	if pos < 0 || pos >= e.buf.Len() {
+		return '\n'
+	}
+	return e.buf.RuneAt(pos)
+}
+
+func (e *Editor) lineOf(pos int) int {
+	line, _ := e.buf.Position(pos)
+	return line
+}
+
+// firstNonBlank returns the offset of the first rune of line that isn't a
+// space or a tab.
+func (e *Editor) firstNonBlank(line int) int {
+	pos, end := e.buf.LineStart(line), e.buf.LineEnd(line)
+	for pos < end && isBlank(e.buf.RuneAt(pos)) {
+		pos++
+	}
+	return pos
+}
+
+// lastColumn returns the offset of the last rune of line, where the caret
+// stays in normal mode, or its start when the line is empty.
+func (e *Editor) lastColumn(line int) int {
+	return max(e.buf.LineStart(line), e.buf.LineEnd(line)-1)
+}
+
+// lastLine returns the last line Vim commands address. The empty line after
+// a final line break is not one of them, just as Vim doesn't show it.
+func (e *Editor) lastLine() int {
+	last := e.buf.LineCount() - 1
+	if last > 0 && e.buf.LineStart(last) == e.buf.Len() {
+		last--
+	}
+	return last
+}
+
+func isBlank(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// vimClass sorts runes for word motions: blanks and line breaks, then
+// punctuation, then word characters. For WORDs all but blanks are alike.
+func vimClass(r rune, big bool) int {
+	switch {
+	case isBlank(r) || r == '\n':
+		return 0
+	case big || isWordChar(r):
+		return 2
+	default:
+		return 1
+	}
+}
+
+func (e *Editor) classAt(pos int, big bool) int {
+	return vimClass(e.runeAt(pos), big)
+}
+
+// wordForward returns the start of the next word after pos. An empty line
+// counts as a word.
+func (e *Editor) wordForward(pos int, big bool) int {
+	n := e.buf.Len()
+	if c := e.classAt(pos, big); c != 0 {
+		for pos < n && e.classAt(pos, big) == c {
+			pos++
+		}
+	}
+	for pos < n {
+		r := e.buf.RuneAt(pos)
+		if r == '\n' {
+			pos++
+			if pos < n && e.buf.RuneAt(pos) == '\n' {
+				return pos
+			}
+			continue
+		}
+		if !isBlank(r) {
+			break
+		}
+		pos++
+	}
+	return pos
+}
+
+// wordEnd returns the last rune of the word that ends after pos.
+func (e *Editor) wordEnd(pos int, big bool) int {
+	n := e.buf.Len()
+	pos++
+	for pos < n && e.classAt(pos, big) == 0 {
+		pos++
+	}
+	if pos >= n {
+		return max(0, n-1)
+	}
+	c := e.classAt(pos, big)
+	for pos+1 < n && e.classAt(pos+1, big) == c {
+		pos++
+	}
+	return pos
+}
+
+// wordBackward returns the start of the word before pos.
+func (e *Editor) wordBackward(pos int, big bool) int {
+	if pos <= 0 {
+		return 0
+	}
+	pos--
+	for pos > 0 && e.classAt(pos, big) == 0 {
+		if e.runeAt(pos) == '\n' && e.runeAt(pos-1) == '\n' {
+			return pos
+		}
+		pos--
+	}
+	c := e.classAt(pos, big)
+	for pos > 0 && e.classAt(pos-1, big) == c {
+		pos--
+	}
+	return pos
+}
+
+// wordEndBackward returns the last rune of the word before the one at pos.
+func (e *Editor) wordEndBackward(pos int, big bool) int {
+	if c := e.classAt(pos, big); c != 0 {
+		for pos > 0 && e.classAt(pos, big) == c {
+			pos--
+		}
+	}
+	for pos > 0 && e.classAt(pos, big) == 0 {
+		pos--
+	}
+	return pos
+}
+
+// findInLine finds the count-th occurrence of r after pos on its line, or
+// before it when backward is set. For t and T motions till stops next to it.
+func (e *Editor) findInLine(pos int, r rune, count int, backward, till bool) (int, bool) {
+	line := e.lineOf(pos)
+	start, end := e.buf.LineStart(line), e.buf.LineEnd(line)
+	step := 1
+	if backward {
+		step = -1
+	}
+	p := pos
+	if till {
+		// Repeating t with ; would otherwise stay where it is.
+		p += step
+	}
+	for found := 0; found < count; {
+		p += step
+		if p < start || p >= end {
+			return pos, false
+		}
+		if e.buf.RuneAt(p) == r {
+			found++
+		}
+	}
+	if till {
+		p -= step
+	}
+	return p, true
+}
+
+// matchBracket finds the bracket that pairs with the first one at or after
+// pos on its line.
+func (e *Editor) matchBracket(pos int) (int, bool) {
+	const pairs = "()[]{}"
+	end := e.buf.LineEnd(e.lineOf(pos))
+	for ; pos < end; pos++ {
+		r := e.buf.RuneAt(pos)
+		for i, b := range pairs {
+			if r != b {
+				continue
+			}
+			if i%2 == 0 {
+				return e.closingBracket(pos+1, b, rune(pairs[i+1]))
+			}
+			return e.openingBracket(pos-1, rune(pairs[i-1]), b)
+		}
+	}
+	return 0, false
+}
+
+// closingBracket finds the close bracket that isn't matched by an open one
+// from pos on.
+func (e *Editor) closingBracket(pos int, open, close rune) (int, bool) {
+	depth := 0
+	for n := e.buf.Len(); pos < n; pos++ {
+		switch e.buf.RuneAt(pos) {
+		case open:
+			depth++
+		case close:
+			if depth == 0 {
+				return pos, true
+			}
+			depth--
+		}
+	}
+	return 0, false
+}
+
+// openingBracket finds the open bracket that isn't matched by a close one
+// from pos back.
+func (e *Editor) openingBracket(pos int, open, close rune) (int, bool) {
+	depth := 0
+	for ; pos >= 0; pos-- {
+		switch e.buf.RuneAt(pos) {
+		case close:
+			depth++
+		case open:
+			if depth == 0 {
+				return pos, true
+			}
+			depth--
+		}
+	}
+	return 0, false
+}
+
+// vimMotion works out where the motion name takes the caret from pos. count
+// is 0 when none was typed. op is the operator the motion is for, or 0 when
+// it only moves the caret.
+func (e *Editor) vimMotion(name string, count int, op rune, pos int) (vimTarget, bool) {
+	v := e.vim
+	n := max(1, count)
+	line := e.lineOf(pos)
+	last := e.lastLine()
+	switch name {
+	case "h", string(vimLeft), string(vimBackspace):
+		return vimTarget{pos: max(e.buf.LineStart(line), pos-n)}, true
+	case "l", " ", string(vimRight):
+		limit := e.buf.LineEnd(line)
+		if op == 0 {
+			limit = e.lastColumn(line)
+		}
+		return vimTarget{pos: min(pos+n, limit)}, true
+	case "j", string(vimDown), "k", string(vimUp):
+		to := line + n
+		if name == "k" || name == string(vimUp) {
+			to = line - n
+		}
+		if to < 0 || to > last {
+			return vimTarget{}, false
+		}
+		col := v.column
+		if col < 0 {
+			col = e.buf.LineEnd(to) - e.buf.LineStart(to)
+		}
+		p := e.buf.Offset(to, col)
+		if op == 0 {
+			p = min(p, e.lastColumn(to))
+		}
+		return vimTarget{pos: p, linewise: true}, true
+	case "+", string(vimEnter), "-":
+		to := line + n
+		if name == "-" {
+			to = line - n
+		}
+		if to < 0 || to > last {
+			return vimTarget{}, false
+		}
+		return vimTarget{pos: e.firstNonBlank(to), linewise: true}, true
+	case "0", string(vimHome):
+		return vimTarget{pos: e.buf.LineStart(line)}, true
+	case "^":
+		return vimTarget{pos: e.firstNonBlank(line)}, true
+	case "$", string(vimEnd):
+		to := min(line+n-1, last)
+		if op != 0 {
+			// Up to the line break, which is left alone.
+			return vimTarget{pos: e.buf.LineEnd(to)}, true
+		}
+		return vimTarget{pos: e.lastColumn(to), inclusive: true}, true
+	case "w", "W":
+		big := name == "W"
+		if op == 'c' && e.classAt(pos, big) != 0 {
+			// cw changes up to the end of the word, like ce.
+			end := "e"
+			if big {
+				end = "E"
+			}
+			return e.vimMotion(end, count, op, pos-1)
+		}
+		p := pos
+		for i := 0; i < n; i++ {
+			p = e.wordForward(p, big)
+		}
+		if to := e.lineOf(p); op != 0 && to > line && p <= e.firstNonBlank(to) {
+			// An operator stops at the end of the line of the last word
+			// moved over, rather than taking the line break along.
+			p = max(pos, e.buf.LineEnd(to-1))
+		}
+		return vimTarget{pos: p}, true
+	case "e", "E":
+		p := pos
+		for i := 0; i < n; i++ {
+			p = e.wordEnd(p, name == "E")
+		}
+		return vimTarget{pos: p, inclusive: true}, true
+	case "b", "B":
+		p := pos
+		for i := 0; i < n; i++ {
+			p = e.wordBackward(p, name == "B")
+		}
+		return vimTarget{pos: p}, true
+	case "ge", "gE":
+		p := pos
+		for i := 0; i < n; i++ {
+			p = e.wordEndBackward(p, name == "gE")
+		}
+		return vimTarget{pos: p, inclusive: true}, true
+	case "G", "gg":
+		to := last
+		if name == "gg" {
+			to = 0
+		}
+		if count > 0 {
+			to = min(count-1, last)
+		}
+		return vimTarget{pos: e.firstNonBlank(to), linewise: true}, true
+	case "%":
+		p, ok := e.matchBracket(pos)
+		return vimTarget{pos: p, inclusive: true}, ok
+	case ";", ",":
+		if v.find[0] == 0 {
+			return vimTarget{}, false
+		}
+		kind := v.find[0]
+		if name == "," {
+			kind = map[rune]rune{'f': 'F', 'F': 'f', 't': 'T', 'T': 't'}[kind]
+		}
+		return e.vimFind(kind, v.find[1], n, pos)
+	}
+	if len(name) > 1 && (name[0] == 'f' || name[0] == 'F' || name[0] == 't' || name[0] == 'T') {
+		kind, r := rune(name[0]), []rune(name)[1]
+		v.find = [2]rune{kind, r}
+		return e.vimFind(kind, r, n, pos)
+	}
+	return vimTarget{}, false
+}
+
+// vimFind runs an f, F, t or T motion for r.
+func (e *Editor) vimFind(kind, r rune, count, pos int) (vimTarget, bool) {
+	p, ok := e.findInLine(pos, r, count, kind == 'F' || kind == 'T', kind == 't' || kind == 'T')
+	return vimTarget{pos: p, inclusive: kind == 'f' || kind == 't'}, ok
+}
+
+// vimObject returns the range of the text object name, such as "iw" or
+// "a(", around pos.
+func (e *Editor) vimObject(name string, pos int) (vimRange, bool) {
+	r := []rune(name)
+	if len(r) != 2 {
+		return vimRange{}, false
+	}
+	inner := r[0] == 'i'
+	switch r[1] {
+	case 'w', 'W':
+		return e.wordObject(pos, inner, r[1] == 'W'), true
+	case '"', '\'', '`':
+		return e.quoteObject(pos, r[1], inner)
+	}
+	// Each pair of brackets with the names it goes by.
+	for _, p := range []string{"()b", "[]", "{}B", "<>"} {
+		if strings.ContainsRune(p, r[1]) {
+			return e.bracketObject(pos, rune(p[0]), rune(p[1]), inner)
+		}
+	}
+	return vimRange{}, false
+}
+
+// wordObject selects the word, or the run of blanks, at pos. Around a word
+// the blanks after it are taken too, or those before it if there are none.
+func (e *Editor) wordObject(pos int, inner, big bool) vimRange {
+	line := e.lineOf(pos)
+	ls, le := e.buf.LineStart(line), e.buf.LineEnd(line)
+	class := func(p int) int {
+		if isBlank(e.runeAt(p)) {
+			return 0
+		}
+		return vimClass(e.runeAt(p), big)
+	}
+	c := class(pos)
+	start, end := pos, pos
+	for start > ls && class(start-1) == c {
+		start--
+	}
+	for end < le && class(end) == c {
+		end++
+	}
+	if inner || c == 0 {
+		return vimRange{start: start, end: end}
+	}
+	blanks := end
+	for blanks < le && isBlank(e.buf.RuneAt(blanks)) {
+		blanks++
+	}
+	if blanks > end {
+		return vimRange{start: start, end: blanks}
+	}
+	for start > ls && isBlank(e.buf.RuneAt(start-1)) {
+		start--
+	}
+	return vimRange{start: start, end: end}
+}
+
+// quoteObject selects the quoted text on the line of pos that pos is in, or
+// else the first one after it.
+func (e *Editor) quoteObject(pos int, q rune, inner bool) (vimRange, bool) {
+	line := e.lineOf(pos)
+	var quotes []int
+	for p, end := e.buf.LineStart(line), e.buf.LineEnd(line); p < end; p++ {
+		if e.buf.RuneAt(p) == q && (p == 0 || e.buf.RuneAt(p-1) != '\\') {
+			quotes = append(quotes, p)
+		}
+	}
+	for i := 0; i+1 < len(quotes); i += 2 {
+		open, close := quotes[i], quotes[i+1]
+		if pos > close {
+			continue
+		}
+		if inner {
+			return vimRange{start: open + 1, end: close}, true
+		}
+		return vimRange{start: open, end: close + 1}, true
+	}
+	return vimRange{}, false
+}
+
+// bracketObject selects the innermost pair of brackets around pos. Inside
+// a block that starts and ends on lines of its own only the lines between
+// are taken.
+func (e *Editor) bracketObject(pos int, open, close rune, inner bool) (vimRange, bool) {
+	from := pos
+	if e.runeAt(pos) == close {
+		from--
+	}
+	start, ok := e.openingBracket(from, open, close)
+	if e.runeAt(pos) == open {
+		start, ok = pos, true
+	}
+	if !ok {
+		return vimRange{}, false
+	}
+	end, ok := e.closingBracket(start+1, open, close)
+	if !ok {
+		return vimRange{}, false
+	}
+	if !inner {
+		return vimRange{start: start, end: end + 1}, true
+	}
+	openLine, closeLine := e.lineOf(start), e.lineOf(end)
+	if e.runeAt(start+1) == '\n' && e.firstNonBlank(closeLine) == end && closeLine > openLine {
+		if closeLine-openLine < 2 {
+			return vimRange{start: start + 1, end: start + 1}, true
+		}
+		return e.linesRange(openLine+1, closeLine-1), true
+	}
+	return vimRange{start: start + 1, end: end}, true
+}
+
+// toggleCase swaps the case of the letters in s.
+func toggleCase(s string) string {
+	r := []rune(s)
+	for i, c := range r {
+		if unicode.IsUpper(c) {
+			r[i] = unicode.ToLower(c)
+		} else {
+			r[i] = unicode.ToUpper(c)
+		}
+	}
+	return string(r)
+}
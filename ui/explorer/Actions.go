@@ -0,0 +1,270 @@
+package explorer
+
+import (
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+
+	"gioui.org/f32"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"github.com/vypal/vedit/libs"
+	"github.com/vypal/vedit/ui/commands"
+	"github.com/vypal/vedit/ui/widgets"
+)
+
+// dragThreshold is how far the pointer has to move before a press on a row
+// turns into dragging it.
+const dragThreshold = unit.Dp(6)
+
+type dragState struct {
+	id       pointer.ID
+	from     string
+	start    f32.Point
+	pressed  bool
+	dragging bool
+	// target is the directory the dragged entry would be dropped into.
+	target string
+}
+
+// rowAt returns the row under y, or -1 when there isn't one.
+func (x *Explorer) rowAt(y float32) int {
+	if x.rowHeight == 0 {
+		return -1
+	}
+	i := x.list.Position.First + (int(y)+x.list.Position.Offset)/x.rowHeight
+	if y < 0 || i >= len(x.rows) {
+		return -1
+	}
+	return i
+}
+
+// dirOf returns the directory that row i stands for when used as a target:
+// the directory itself, or the one containing a file. Outside of the rows it
+// is the root.
+func (x *Explorer) dirOf(i int) string {
+	if i < 0 {
+		return x.Root.FullPath()
+	}
+	if r := x.rows[i]; r.dir != nil {
+		return r.path
+	}
+	return filepath.Dir(x.rows[i].path)
+}
+
+func (x *Explorer) handlePointer(gtx layout.Context, ev pointer.Event) {
+	d := &x.drag
+	switch ev.Kind {
+	case pointer.Press:
+		i := x.rowAt(ev.Position.Y)
+		if ev.Buttons == pointer.ButtonSecondary {
+			x.showMenu(gtx, i, ev.Position)
+			return
+		}
+		if ev.Buttons != pointer.ButtonPrimary || i < 0 || d.pressed {
+			return
+		}
+		*d = dragState{id: ev.PointerID, from: x.rows[i].path, start: ev.Position, pressed: true}
+
+	case pointer.Drag:
+		if !d.pressed || ev.PointerID != d.id {
+			return
+		}
+		if !d.dragging {
+			dist := ev.Position.Sub(d.start)
+			if dist.X*dist.X+dist.Y*dist.Y < float32(gtx.Dp(dragThreshold)*gtx.Dp(dragThreshold)) {
+				return
+			}
+			d.dragging = true
+		}
+		d.target = x.dirOf(x.rowAt(ev.Position.Y))
+		if ev.Priority < pointer.Grabbed {
+			gtx.Execute(pointer.GrabCmd{Tag: x, ID: d.id})
+		}
+
+	case pointer.Release:
+		if d.dragging && ev.PointerID == d.id {
+			x.move(d.from, d.target)
+		}
+		*d = dragState{}
+
+	case pointer.Cancel:
+		*d = dragState{}
+	}
+}
+
+// RegisterCommands adds the commands of the file tree to r, which act on the
+// selected entry. Their keys work while the tree has the focus.
+func (x *Explorer) RegisterCommands(r *commands.Registry) {
+	x.commands = r
+	x.menu.Commands = r
+	selected := func() bool { return x.selectedIndex() >= 0 }
+	r.Register(
+		commands.Command{ID: "explorer.newFile", Title: "New File…", Context: commands.Explorer, Run: func() {
+			x.newFile(x.dirOf(x.selectedIndex()))
+		}},
+		commands.Command{ID: "explorer.newFolder", Title: "New Folder…", Context: commands.Explorer, Run: func() {
+			x.newDirectory(x.dirOf(x.selectedIndex()))
+		}},
+		commands.Command{ID: "explorer.open", Title: "Open Selected File", Context: commands.Explorer,
+			Enabled: func() bool {
+				i := x.selectedIndex()
+				return i >= 0 && x.rows[i].dir == nil
+			},
+			Run: func() { x.activate(x.rows[x.selectedIndex()]) },
+		},
+		commands.Command{ID: "explorer.rename", Title: "Rename…", Keys: []string{"F2"}, Context: commands.Explorer,
+			Enabled: selected, Run: func() { x.rename(x.selected) }},
+		commands.Command{ID: "explorer.duplicate", Title: "Duplicate…", Context: commands.Explorer,
+			Enabled: selected, Run: func() { x.duplicate(x.selected) }},
+		commands.Command{ID: "explorer.move", Title: "Move…", Context: commands.Explorer,
+			Enabled: selected, Run: func() { x.askMove(x.selected) }},
+		commands.Command{ID: "explorer.trash", Title: "Move to Trash", Keys: []string{"Delete"}, Context: commands.Explorer,
+			Enabled: selected, Run: func() { x.trash(x.selected) }},
+		commands.Command{ID: "explorer.refresh", Title: "Refresh File Tree", Context: commands.Explorer, Run: x.Refresh},
+		commands.Command{ID: "explorer.toggleHidden", Title: "Show or Hide Hidden Files", Keys: []string{"Ctrl+H"}, Context: commands.Explorer, Run: func() {
+			x.SetShowHidden(!x.Options.ShowHidden)
+		}},
+	)
+}
+
+func (x *Explorer) showMenu(gtx layout.Context, i int, pos f32.Point) {
+	var items []widgets.MenuItem
+	// The commands act on the entry clicked, or on the root outside of the
+	// entries.
+	x.selected = ""
+	if i >= 0 {
+		x.selected = x.rows[i].path
+	}
+	if i < 0 || x.rows[i].dir != nil {
+		items = append(items,
+			widgets.MenuItem{Label: "New File…", Command: "explorer.newFile"},
+			widgets.MenuItem{Label: "New Folder…", Command: "explorer.newFolder"},
+		)
+	}
+	if i >= 0 {
+		if x.rows[i].dir == nil {
+			items = append(items, widgets.MenuItem{Label: "Open", Command: "explorer.open"})
+		}
+		items = append(items,
+			widgets.MenuItem{Label: "Rename…", Command: "explorer.rename"},
+			widgets.MenuItem{Label: "Duplicate…", Command: "explorer.duplicate"},
+			widgets.MenuItem{Label: "Move…", Command: "explorer.move"},
+			widgets.MenuItem{Label: "Move to Trash", Command: "explorer.trash"},
+		)
+	}
+	items = append(items, widgets.MenuItem{Label: "Refresh", Command: "explorer.refresh"})
+	x.menu.ShowAt(image.Pt(int(pos.X), int(pos.Y)), items)
+}
+
+func (x *Explorer) ask(label, text string, submit func(string)) {
+	if x.Ask != nil {
+		x.Ask(label, text, submit)
+	}
+}
+
+func (x *Explorer) newFile(dir string) {
+	x.ask("New file:", "", func(name string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			log.Printf("creating %s: %v", path, err)
+			return
+		}
+		if err := libs.CreateFile(path); err != nil {
+			log.Printf("creating %s: %v", path, err)
+			return
+		}
+		x.changed(path)
+		if x.OnOpen != nil {
+			x.OnOpen(path)
+		}
+	})
+}
+
+func (x *Explorer) newDirectory(dir string) {
+	x.ask("New folder:", "", func(name string) {
+		path := filepath.Join(dir, name)
+		if err := libs.CreateDirectory(path); err != nil {
+			log.Printf("creating %s: %v", path, err)
+			return
+		}
+		x.changed(path)
+	})
+}
+
+func (x *Explorer) rename(path string) {
+	x.ask("Rename to:", filepath.Base(path), func(name string) {
+		dst, err := libs.Rename(path, name)
+		if err != nil {
+			log.Printf("renaming %s: %v", path, err)
+			return
+		}
+		x.moved(path, dst)
+	})
+}
+
+func (x *Explorer) duplicate(path string) {
+	x.ask("Duplicate as:", filepath.Base(path), func(name string) {
+		dst := filepath.Join(filepath.Dir(path), name)
+		if err := libs.Copy(path, dst); err != nil {
+			log.Printf("copying %s: %v", path, err)
+			return
+		}
+		x.changed(dst)
+	})
+}
+
+func (x *Explorer) askMove(path string) {
+	x.ask("Move to:", path, func(dst string) {
+		if !filepath.IsAbs(dst) {
+			dst = filepath.Join(filepath.Dir(path), dst)
+		}
+		if err := libs.Move(path, dst); err != nil {
+			log.Printf("moving %s: %v", path, err)
+			return
+		}
+		x.moved(path, dst)
+	})
+}
+
+// move moves the entry at path into the directory dir.
+func (x *Explorer) move(path, dir string) {
+	dst := filepath.Join(dir, filepath.Base(path))
+	if dst == path {
+		return
+	}
+	if err := libs.Move(path, dst); err != nil {
+		log.Printf("moving %s: %v", path, err)
+		return
+	}
+	x.moved(path, dst)
+}
+
+func (x *Explorer) trash(path string) {
+	if err := libs.Trash(path); err != nil {
+		log.Printf("moving %s to the trash: %v", path, err)
+		return
+	}
+	x.Refresh()
+	if x.OnDeleted != nil {
+		x.OnDeleted(path)
+	}
+}
+
+func (x *Explorer) moved(from, to string) {
+	if x.expanded[from] {
+		x.expanded[to] = true
+	}
+	x.changed(to)
+	if x.OnRenamed != nil {
+		x.OnRenamed(from, to)
+	}
+}
+
+// changed rereads the tree after path was created or changed and selects it.
+func (x *Explorer) changed(path string) {
+	x.Refresh()
+	x.Select(path)
+}
@@ -0,0 +1,352 @@
+package explorer
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+
+	"gioui.org/gesture"
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/libs"
+	"github.com/vypal/vedit/ui/commands"
+	"github.com/vypal/vedit/ui/theme"
+	"github.com/vypal/vedit/ui/widgets"
+)
+
+const indentWidth = unit.Dp(14)
+
+// Explorer shows a directory as a tree. Directories are read when they are
+// first expanded.
+type Explorer struct {
+	Root    *libs.Directory
+	Options libs.ScanOptions
+	Theme   *material.Theme
+	Colors  *theme.Theme
+	// OnOpen is called with the path of a file the user opened.
+	OnOpen func(path string)
+	// OnRenamed is called after a file or directory was renamed or moved.
+	OnRenamed func(oldPath, newPath string)
+	// OnDeleted is called after a file or directory was moved to the trash.
+	OnDeleted func(path string)
+	// Ask prompts the user for a line of text, such as a new file name.
+	Ask func(label, text string, submit func(text string))
+
+	expanded map[string]bool
+	selected string
+	clicks   map[string]*gesture.Click
+	list     widget.List
+	rows     []row
+	// reveal scrolls the selected row into view during the next Layout.
+	reveal    bool
+	rowHeight int
+	drag      dragState
+	menu      widgets.Menu
+	commands  *commands.Registry
+}
+
+// row is one visible line of the tree.
+type row struct {
+	depth int
+	path  string
+	name  string
+	dir   *libs.Directory
+}
+
+func New(root *libs.Directory, th *material.Theme) *Explorer {
+	x := &Explorer{
+		Root:     root,
+		Options:  libs.ScanOptions{UseGitIgnore: true},
+		Theme:    th,
+		Colors:   theme.Default(),
+		expanded: map[string]bool{},
+		clicks:   map[string]*gesture.Click{},
+	}
+	x.list.Axis = layout.Vertical
+	x.expanded[root.FullPath()] = true
+	return x
+}
+
+// SetRoot shows the tree of another directory.
+func (x *Explorer) SetRoot(root *libs.Directory) {
+	x.Root = root
+	x.expanded = map[string]bool{root.FullPath(): true}
+	x.clicks = map[string]*gesture.Click{}
+	x.selected = ""
+	x.list.Position = layout.Position{}
+}
+
+// SetShowHidden shows or hides dotfiles and rereads the open directories.
+func (x *Explorer) SetShowHidden(show bool) {
+	x.Options.ShowHidden = show
+	x.Refresh()
+}
+
+// Refresh rereads every expanded directory.
+func (x *Explorer) Refresh() {
+	x.clicks = map[string]*gesture.Click{}
+	x.refresh(x.Root)
+}
+
+func (x *Explorer) refresh(d *libs.Directory) {
+	if !d.Loaded() {
+		return
+	}
+	if err := d.Load(x.Options); err != nil {
+		log.Printf("reading %s: %v", d.FullPath(), err)
+		return
+	}
+	for i := range d.Directories {
+		x.refresh(&d.Directories[i])
+	}
+}
+
+// Select highlights path and expands the directories leading to it.
+func (x *Explorer) Select(path string) {
+	root := x.Root.FullPath()
+	for dir := filepath.Dir(path); strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		x.expanded[dir] = true
+		if dir == root {
+			break
+		}
+	}
+	x.selected = path
+	x.reveal = true
+}
+
+func (x *Explorer) toggle(r row) {
+	if x.expanded[r.path] {
+		delete(x.expanded, r.path)
+		return
+	}
+	x.expanded[r.path] = true
+}
+
+func (x *Explorer) activate(r row) {
+	if r.dir != nil {
+		x.toggle(r)
+		return
+	}
+	if x.OnOpen != nil {
+		x.OnOpen(r.path)
+	}
+}
+
+// buildRows flattens the expanded part of the tree, loading directories that
+// were expanded but not read yet.
+func (x *Explorer) buildRows() {
+	x.rows = x.rows[:0]
+	var walk func(d *libs.Directory, depth int)
+	walk = func(d *libs.Directory, depth int) {
+		if !d.Loaded() {
+			if err := d.Load(x.Options); err != nil {
+				log.Printf("reading %s: %v", d.FullPath(), err)
+				return
+			}
+		}
+		for i := range d.Directories {
+			sub := &d.Directories[i]
+			r := row{depth: depth, path: sub.FullPath(), name: sub.Name, dir: sub}
+			x.rows = append(x.rows, r)
+			if x.expanded[r.path] {
+				walk(sub, depth+1)
+			}
+		}
+		for i := range d.Files {
+			f := &d.Files[i]
+			x.rows = append(x.rows, row{depth: depth, path: f.FullPath(), name: f.Name})
+		}
+	}
+	walk(x.Root, 0)
+}
+
+func (x *Explorer) selectedIndex() int {
+	for i, r := range x.rows {
+		if r.path == x.selected {
+			return i
+		}
+	}
+	return -1
+}
+
+// parentIndex returns the row of the directory containing row i.
+func (x *Explorer) parentIndex(i int) int {
+	for j := i - 1; j >= 0; j-- {
+		if x.rows[j].depth < x.rows[i].depth {
+			return j
+		}
+	}
+	return -1
+}
+
+func (x *Explorer) handleKey(ev key.Event) {
+	if ev.State != key.Press {
+		return
+	}
+	if x.commands != nil && x.commands.HandleKey(ev, commands.Explorer) {
+		return
+	}
+	if len(x.rows) == 0 {
+		return
+	}
+	i := x.selectedIndex()
+	if i < 0 {
+		x.Select(x.rows[0].path)
+		return
+	}
+	r := x.rows[i]
+	switch ev.Name {
+	case key.NameUpArrow:
+		i = max(0, i-1)
+	case key.NameDownArrow:
+		i = min(len(x.rows)-1, i+1)
+	case key.NameHome:
+		i = 0
+	case key.NameEnd:
+		i = len(x.rows) - 1
+	case key.NameRightArrow:
+		if r.dir == nil {
+			break
+		}
+		if !x.expanded[r.path] {
+			x.toggle(r)
+		} else if i+1 < len(x.rows) && x.rows[i+1].depth > r.depth {
+			i++
+		}
+	case key.NameLeftArrow:
+		if r.dir != nil && x.expanded[r.path] {
+			x.toggle(r)
+		} else if p := x.parentIndex(i); p >= 0 {
+			i = p
+		}
+	case key.NameReturn, key.NameSpace:
+		x.activate(r)
+	}
+	x.Select(x.rows[i].path)
+}
+
+func (x *Explorer) Layout(gtx layout.Context) layout.Dimensions {
+	paint.Fill(gtx.Ops, x.Colors.Panel)
+	x.buildRows()
+
+	area := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	event.Op(gtx.Ops, x)
+	filters := []event.Filter{
+		key.FocusFilter{Target: x},
+		key.Filter{Focus: x, Name: key.NameUpArrow},
+		key.Filter{Focus: x, Name: key.NameDownArrow},
+		key.Filter{Focus: x, Name: key.NameLeftArrow},
+		key.Filter{Focus: x, Name: key.NameRightArrow},
+		key.Filter{Focus: x, Name: key.NameHome},
+		key.Filter{Focus: x, Name: key.NameEnd},
+		key.Filter{Focus: x, Name: key.NameReturn},
+		key.Filter{Focus: x, Name: key.NameSpace},
+		pointer.Filter{Target: x, Kinds: pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel},
+	}
+	if x.commands != nil {
+		filters = append(filters, x.commands.Filters(commands.Explorer, x)...)
+	}
+	for {
+		ev, ok := gtx.Event(filters...)
+		if !ok {
+			break
+		}
+		switch ev := ev.(type) {
+		case key.Event:
+			x.handleKey(ev)
+		case pointer.Event:
+			x.handlePointer(gtx, ev)
+		}
+	}
+	x.buildRows()
+
+	if x.reveal {
+		x.reveal = false
+		if i := x.selectedIndex(); i >= 0 {
+			pos := &x.list.Position
+			if i < pos.First {
+				pos.First, pos.Offset = i, 0
+			} else if pos.Count > 0 && i >= pos.First+pos.Count-1 {
+				pos.First, pos.Offset = i-pos.Count+2, 0
+			}
+		}
+	}
+
+	dims := material.List(x.Theme, &x.list).Layout(gtx, len(x.rows), func(gtx layout.Context, i int) layout.Dimensions {
+		return x.layoutRow(gtx, x.rows[i])
+	})
+	area.Pop()
+	x.menu.Colors = x.Colors
+	x.menu.Layout(gtx, x.Theme)
+	return dims
+}
+
+func (x *Explorer) layoutRow(gtx layout.Context, r row) layout.Dimensions {
+	click := x.clicks[r.path]
+	if click == nil {
+		click = new(gesture.Click)
+		x.clicks[r.path] = click
+	}
+	for {
+		ev, ok := click.Update(gtx.Source)
+		if !ok {
+			break
+		}
+		switch ev.Kind {
+		case gesture.KindPress:
+			gtx.Execute(key.FocusCmd{Tag: x})
+			x.selected = r.path
+		case gesture.KindClick:
+			x.activate(r)
+		}
+	}
+
+	m := op.Record(gtx.Ops)
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	dims := layout.Inset{
+		Left:   indentWidth*unit.Dp(r.depth) + unit.Dp(6),
+		Top:    unit.Dp(2),
+		Bottom: unit.Dp(2),
+	}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				glyph, c := iconFor(r.name, r.dir != nil, x.expanded[r.path])
+				lbl := material.Label(x.Theme, unit.Sp(13), glyph)
+				lbl.Color = c
+				gtx.Constraints.Min.X = gtx.Sp(unit.Sp(20))
+				return lbl.Layout(gtx)
+			}),
+			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Label(x.Theme, unit.Sp(14), r.name)
+				lbl.Color = x.Colors.PanelText
+				lbl.MaxLines = 1
+				return lbl.Layout(gtx)
+			}),
+		)
+	})
+	content := m.Stop()
+	x.rowHeight = dims.Size.Y
+
+	rect := clip.Rect{Max: dims.Size}
+	switch {
+	case x.drag.dragging && r.path == x.drag.target:
+		paint.FillShape(gtx.Ops, x.Colors.Drop, rect.Op())
+	case r.path == x.selected:
+		paint.FillShape(gtx.Ops, x.Colors.ListSelection, rect.Op())
+	case click.Hovered():
+		paint.FillShape(gtx.Ops, x.Colors.Hover, rect.Op())
+	}
+	content.Add(gtx.Ops)
+
+	defer rect.Push(gtx.Ops).Pop()
+	click.Add(gtx.Ops)
+	return dims
+}
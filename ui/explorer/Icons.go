@@ -0,0 +1,43 @@
+package explorer
+
+import (
+	"image/color"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	folderColor  = color.NRGBA{R: 0xDC, G: 0xB6, B: 0x7A, A: 0xFF}
+	defaultColor = color.NRGBA{R: 0x8A, G: 0x8A, B: 0x8A, A: 0xFF}
+)
+
+// fileColors gives files a colored marker by type.
+var fileColors = map[string]color.NRGBA{
+	".go":   {R: 0x00, G: 0xAD, B: 0xD8, A: 0xFF},
+	".mod":  {R: 0x00, G: 0xAD, B: 0xD8, A: 0xFF},
+	".sum":  {R: 0x00, G: 0x7D, B: 0x9C, A: 0xFF},
+	".md":   {R: 0x51, G: 0x9A, B: 0xBA, A: 0xFF},
+	".json": {R: 0xCB, G: 0xCB, B: 0x41, A: 0xFF},
+	".yaml": {R: 0xA0, G: 0x74, B: 0xC4, A: 0xFF},
+	".yml":  {R: 0xA0, G: 0x74, B: 0xC4, A: 0xFF},
+	".toml": {R: 0x9C, G: 0x41, B: 0x21, A: 0xFF},
+	".sh":   {R: 0x4D, G: 0x5A, B: 0x5E, A: 0xFF},
+	".txt":  {R: 0xAA, G: 0xAA, B: 0xAA, A: 0xFF},
+	".png":  {R: 0xA0, G: 0x74, B: 0xC4, A: 0xFF},
+	".jpg":  {R: 0xA0, G: 0x74, B: 0xC4, A: 0xFF},
+	".svg":  {R: 0xFF, G: 0xB1, B: 0x3B, A: 0xFF},
+}
+
+// iconFor returns the glyph and color shown before an entry of the tree.
+func iconFor(name string, isDir, expanded bool) (string, color.NRGBA) {
+	if isDir {
+		if expanded {
+			return "▼", folderColor
+		}
+		return "►", folderColor
+	}
+	if c, ok := fileColors[strings.ToLower(filepath.Ext(name))]; ok {
+		return "●", c
+	}
+	return "○", defaultColor
+}
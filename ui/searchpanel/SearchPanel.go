@@ -0,0 +1,614 @@
+// Package searchpanel is the "Find in Files" panel, which searches every
+// file of the project and can replace across them.
+package searchpanel
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gioui.org/gesture"
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/libs"
+	"github.com/vypal/vedit/libs/search"
+	"github.com/vypal/vedit/ui/theme"
+	"github.com/vypal/vedit/ui/widgets"
+)
+
+// maxHits stops a search once it found this many matches.
+const maxHits = 10000
+
+// Panel searches the files below Root. Results come in while the search is
+// still running and are grouped by file. Replacing first shows what would
+// change in every file, and only writes the files the user keeps checked.
+type Panel struct {
+	Root    string
+	Options libs.ScanOptions
+	Theme   *material.Theme
+	Colors  *theme.Theme
+	// OnOpen is called with the file and the range of a hit the user
+	// clicked, in runes.
+	OnOpen func(path string, start, end int)
+	// OnReplaced is called with the files that were rewritten.
+	OnReplaced func(paths []string)
+	// Unsaved reports whether a file has changes in the editor that aren't
+	// saved. Such files are left unchecked when replacing.
+	Unsaved func(path string) bool
+	// Invalidate asks for a new frame. It is called from the goroutines
+	// searching as results come in.
+	Invalidate func()
+
+	query, replace widget.Editor
+	opts           search.Options
+	caseBtn        widget.Clickable
+	wordBtn        widget.Clickable
+	regexpBtn      widget.Clickable
+	previewBtn     widget.Clickable
+	applyBtn       widget.Clickable
+	cancelBtn      widget.Clickable
+	list           widget.List
+	clicks         map[rowKey]*gesture.Click
+	collapsed      map[string]bool
+	focus          bool
+
+	// searched is the query of the results shown.
+	searched *search.Query
+	results  []search.FileResult
+	hits     int
+	status   string
+	// notice is shown after the status until the next search.
+	notice string
+	cancel context.CancelFunc
+
+	// The search goroutines leave their results here for the next Layout.
+	mu        sync.Mutex
+	pending   []search.FileResult
+	running   bool
+	searchErr error
+	// generation tells the results of the current search from those of
+	// searches that were replaced by it.
+	generation int
+
+	// changes are shown instead of the results while a replace is reviewed.
+	changes    []change
+	previewing bool
+}
+
+type change struct {
+	search.Change
+	keep widget.Bool
+	// note explains why the change is unchecked.
+	note string
+}
+
+type rowKind uint8
+
+const (
+	fileRow rowKind = iota
+	hitRow
+	changeRow
+	diffRow
+)
+
+// row is one line of the list. file and item index into the results or the
+// changes.
+type row struct {
+	kind    rowKind
+	file    int
+	item    int
+	text    string
+	removed bool
+}
+
+type rowKey struct {
+	path string
+	item int
+}
+
+func New(th *material.Theme) *Panel {
+	p := &Panel{
+		Theme:     th,
+		Colors:    theme.Default(),
+		Options:   libs.ScanOptions{UseGitIgnore: true},
+		clicks:    map[rowKey]*gesture.Click{},
+		collapsed: map[string]bool{},
+	}
+	p.query.SingleLine, p.query.Submit = true, true
+	p.replace.SingleLine, p.replace.Submit = true, true
+	p.list.Axis = layout.Vertical
+	return p
+}
+
+// Show focuses the search field, searching for pattern unless it is empty.
+func (p *Panel) Show(pattern string) {
+	p.focus = true
+	if pattern != "" {
+		p.query.SetText(pattern)
+		p.Search()
+	}
+	p.query.SetCaret(p.query.Len(), 0)
+}
+
+// Search starts searching for the text in the search field, stopping the
+// search that is running.
+func (p *Panel) Search() {
+	p.stop()
+	p.results, p.hits = nil, 0
+	p.previewing, p.changes = false, nil
+	p.searched, p.notice = nil, ""
+	p.clicks = map[rowKey]*gesture.Click{}
+	pattern := p.query.Text()
+	if pattern == "" {
+		p.status = ""
+		return
+	}
+	q, err := search.Compile(pattern, p.opts)
+	if err != nil {
+		p.status = err.Error()
+		return
+	}
+	p.searched = q
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.mu.Lock()
+	p.generation++
+	gen := p.generation
+	p.pending, p.running, p.searchErr = nil, true, nil
+	p.mu.Unlock()
+
+	root, opts := p.Root, p.Options
+	go func() {
+		err := search.SearchFiles(ctx, root, opts, q, runtime.NumCPU(), func(r search.FileResult) {
+			p.mu.Lock()
+			if gen == p.generation {
+				p.pending = append(p.pending, r)
+			}
+			p.mu.Unlock()
+			p.invalidate()
+		})
+		p.mu.Lock()
+		if gen == p.generation {
+			p.running = false
+			if err != context.Canceled {
+				p.searchErr = err
+			}
+		}
+		p.mu.Unlock()
+		p.invalidate()
+	}()
+}
+
+func (p *Panel) stop() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	p.mu.Lock()
+	p.generation++
+	p.running = false
+	p.mu.Unlock()
+}
+
+func (p *Panel) invalidate() {
+	if p.Invalidate != nil {
+		p.Invalidate()
+	}
+}
+
+// collect takes the results found since the last frame, keeping the files
+// sorted by path.
+func (p *Panel) collect() {
+	p.mu.Lock()
+	pending, running, err := p.pending, p.running, p.searchErr
+	p.pending = nil
+	p.mu.Unlock()
+
+	for _, r := range pending {
+		i := sort.Search(len(p.results), func(i int) bool { return p.results[i].Path >= r.Path })
+		p.results = append(p.results, search.FileResult{})
+		copy(p.results[i+1:], p.results[i:])
+		p.results[i] = r
+		p.hits += len(r.Hits)
+	}
+	limited := p.hits >= maxHits
+	if limited && running {
+		p.stop()
+		running = false
+	}
+
+	switch {
+	case p.searched == nil:
+	case err != nil:
+		p.status = err.Error()
+	case p.hits == 0 && running:
+		p.status = "Searching…"
+	case p.hits == 0:
+		p.status = "No results"
+	default:
+		p.status = fmt.Sprintf("%d results in %d files", p.hits, len(p.results))
+		if running {
+			p.status = "Searching… " + p.status
+		} else if limited {
+			p.status += " (stopped at the limit)"
+		}
+	}
+	if p.notice != "" {
+		p.status += " - " + p.notice
+	}
+}
+
+// preview works out what replacing in every file found so far would do.
+func (p *Panel) preview() {
+	if p.searched == nil || len(p.results) == 0 {
+		return
+	}
+	p.changes = p.changes[:0]
+	replacement := p.replace.Text()
+	for _, r := range p.results {
+		text, err := search.ReadText(r.Path)
+		if err != nil {
+			continue
+		}
+		c := change{Change: p.searched.Replace(r.Path, text, replacement)}
+		if c.Count == 0 {
+			continue
+		}
+		c.keep.Value = true
+		if p.Unsaved != nil && p.Unsaved(r.Path) {
+			c.keep.Value = false
+			c.note = "unsaved in the editor"
+		}
+		p.changes = append(p.changes, c)
+	}
+	p.previewing = true
+	p.list.Position = layout.Position{}
+	p.status = fmt.Sprintf("Replace in %d files?", len(p.changes))
+}
+
+// apply writes the changes that are checked.
+func (p *Panel) apply() {
+	var written []string
+	var failed []string
+	for i := range p.changes {
+		c := &p.changes[i]
+		if !c.keep.Value {
+			continue
+		}
+		if err := c.Write(); err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		written = append(written, c.Path)
+	}
+	if len(written) > 0 && p.OnReplaced != nil {
+		p.OnReplaced(written)
+	}
+	p.Search()
+	if len(failed) > 0 {
+		p.notice = strings.Join(failed, "; ")
+	}
+}
+
+func (p *Panel) update(gtx layout.Context) {
+	for {
+		ev, ok := p.query.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, ok := ev.(widget.SubmitEvent); ok {
+			p.Search()
+		}
+	}
+	for {
+		ev, ok := p.replace.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, ok := ev.(widget.SubmitEvent); ok {
+			p.preview()
+		}
+	}
+	for _, t := range []struct {
+		btn *widget.Clickable
+		opt *bool
+	}{
+		{&p.caseBtn, &p.opts.CaseSensitive},
+		{&p.wordBtn, &p.opts.WholeWord},
+		{&p.regexpBtn, &p.opts.Regexp},
+	} {
+		if t.btn.Clicked(gtx) {
+			*t.opt = !*t.opt
+			if p.query.Text() != "" {
+				p.Search()
+			}
+		}
+	}
+	if p.previewBtn.Clicked(gtx) {
+		p.preview()
+	}
+	if p.applyBtn.Clicked(gtx) {
+		p.apply()
+	}
+	if p.cancelBtn.Clicked(gtx) {
+		p.previewing = false
+		p.collect()
+	}
+}
+
+func (p *Panel) Layout(gtx layout.Context) layout.Dimensions {
+	paint.Fill(gtx.Ops, p.Colors.Panel)
+	if p.focus {
+		// Asked for again until the field has it, as widgets laid out later
+		// may take the focus in the same frame.
+		if gtx.Focused(&p.query) {
+			p.focus = false
+		} else {
+			gtx.Execute(key.FocusCmd{Tag: &p.query})
+			gtx.Execute(op.InvalidateCmd{})
+		}
+	}
+	p.update(gtx)
+	if !p.previewing {
+		p.collect()
+	}
+	rows := p.rows()
+
+	th, c := p.Theme, p.Colors
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						return widgets.TextField(gtx, th, c, &p.query, "Search")
+					}),
+					layout.Rigid(widgets.TextButton(th, c, &p.caseBtn, "Aa", p.opts.CaseSensitive)),
+					layout.Rigid(widgets.TextButton(th, c, &p.wordBtn, "ab", p.opts.WholeWord)),
+					layout.Rigid(widgets.TextButton(th, c, &p.regexpBtn, ".*", p.opts.Regexp)),
+				)
+			})
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Left: unit.Dp(4), Right: unit.Dp(4), Bottom: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						return widgets.TextField(gtx, th, c, &p.replace, "Replace")
+					}),
+					layout.Rigid(widgets.TextButton(th, c, &p.previewBtn, "Replace…", false)),
+				)
+			})
+		}),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return layout.Inset{Left: unit.Dp(6), Right: unit.Dp(4), Bottom: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				children := []layout.FlexChild{
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						lbl := material.Body2(th, p.status)
+						lbl.Color = c.LineNumber
+						return lbl.Layout(gtx)
+					}),
+				}
+				if p.previewing {
+					children = append(children,
+						layout.Rigid(widgets.TextButton(th, c, &p.applyBtn, "Replace", false)),
+						layout.Rigid(widgets.TextButton(th, c, &p.cancelBtn, "Cancel", false)),
+					)
+				}
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+			})
+		}),
+		layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+			return material.List(th, &p.list).Layout(gtx, len(rows), func(gtx layout.Context, i int) layout.Dimensions {
+				return p.layoutRow(gtx, rows[i])
+			})
+		}),
+	)
+}
+
+// rows flattens the results, or the changes while previewing, into lines.
+func (p *Panel) rows() []row {
+	var rows []row
+	if p.previewing {
+		for i, c := range p.changes {
+			rows = append(rows, row{kind: changeRow, file: i})
+			for _, h := range c.Hunks {
+				for _, l := range h.Old {
+					rows = append(rows, row{kind: diffRow, file: i, text: l, removed: true})
+				}
+				for _, l := range h.New {
+					rows = append(rows, row{kind: diffRow, file: i, text: l})
+				}
+			}
+		}
+		return rows
+	}
+	for i, r := range p.results {
+		rows = append(rows, row{kind: fileRow, file: i})
+		if p.collapsed[r.Path] {
+			continue
+		}
+		for j := range r.Hits {
+			rows = append(rows, row{kind: hitRow, file: i, item: j})
+		}
+	}
+	return rows
+}
+
+func (p *Panel) click(key rowKey) *gesture.Click {
+	c := p.clicks[key]
+	if c == nil {
+		c = new(gesture.Click)
+		p.clicks[key] = c
+	}
+	return c
+}
+
+// relative shortens path to be relative to the root.
+func (p *Panel) relative(path string) string {
+	if rel, err := filepath.Rel(p.Root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+func (p *Panel) layoutRow(gtx layout.Context, r row) layout.Dimensions {
+	switch r.kind {
+	case fileRow:
+		return p.layoutFile(gtx, r)
+	case hitRow:
+		return p.layoutHit(gtx, r)
+	case changeRow:
+		return p.layoutChange(gtx, r)
+	default:
+		return p.layoutDiff(gtx, r)
+	}
+}
+
+func (p *Panel) layoutFile(gtx layout.Context, r row) layout.Dimensions {
+	res := p.results[r.file]
+	click := p.click(rowKey{path: res.Path, item: -1})
+	for {
+		ev, ok := click.Update(gtx.Source)
+		if !ok {
+			break
+		}
+		if ev.Kind == gesture.KindClick {
+			p.collapsed[res.Path] = !p.collapsed[res.Path]
+		}
+	}
+	mark := "▼"
+	if p.collapsed[res.Path] {
+		mark = "►"
+	}
+	text := fmt.Sprintf("%s %s (%d)", mark, p.relative(res.Path), len(res.Hits))
+	return p.clickable(gtx, click, func(gtx layout.Context) layout.Dimensions {
+		return layout.Inset{Left: unit.Dp(6), Top: unit.Dp(3), Bottom: unit.Dp(3)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Body2(p.Theme, text)
+			lbl.Color = p.Colors.PanelText
+			lbl.MaxLines = 1
+			return lbl.Layout(gtx)
+		})
+	})
+}
+
+func (p *Panel) layoutHit(gtx layout.Context, r row) layout.Dimensions {
+	res := p.results[r.file]
+	hit := res.Hits[r.item]
+	click := p.click(rowKey{path: res.Path, item: r.item})
+	for {
+		ev, ok := click.Update(gtx.Source)
+		if !ok {
+			break
+		}
+		if ev.Kind == gesture.KindClick && p.OnOpen != nil {
+			p.OnOpen(res.Path, hit.Start, hit.End)
+		}
+	}
+
+	// Tabs are shown as single spaces to keep the columns.
+	line := []rune(strings.ReplaceAll(hit.Text, "\t", " "))
+	indent := 0
+	for indent < hit.Col && line[indent] == ' ' {
+		indent++
+	}
+	before := string(line[indent:hit.Col])
+	match := string(line[hit.Col:hit.EndCol])
+	after := string(line[hit.EndCol:])
+
+	return p.clickable(gtx, click, func(gtx layout.Context) layout.Dimensions {
+		return layout.Inset{Left: unit.Dp(20), Top: unit.Dp(2), Bottom: unit.Dp(2)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			label := func(text string, c paintColor) layout.Widget {
+				return func(gtx layout.Context) layout.Dimensions {
+					lbl := material.Body2(p.Theme, text)
+					lbl.Color = c.fg
+					lbl.MaxLines = 1
+					m := op.Record(gtx.Ops)
+					dims := lbl.Layout(gtx)
+					call := m.Stop()
+					if c.bg.A != 0 {
+						paint.FillShape(gtx.Ops, c.bg, clip.Rect{Max: dims.Size}.Op())
+					}
+					call.Add(gtx.Ops)
+					return dims
+				}
+			}
+			text := paintColor{fg: p.Colors.PanelText}
+			return layout.Flex{Alignment: layout.Baseline}.Layout(gtx,
+				layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(36))
+					return label(strconv.Itoa(hit.Line+1), paintColor{fg: p.Colors.LineNumber})(gtx)
+				}),
+				layout.Rigid(label(before, text)),
+				layout.Rigid(label(match, paintColor{fg: p.Colors.PanelText, bg: p.Colors.Match})),
+				layout.Flexed(1, label(after, text)),
+			)
+		})
+	})
+}
+
+func (p *Panel) layoutChange(gtx layout.Context, r row) layout.Dimensions {
+	c := &p.changes[r.file]
+	text := fmt.Sprintf("%s (%d)", p.relative(c.Path), c.Count)
+	if c.note != "" {
+		text += " - " + c.note
+	}
+	return layout.Inset{Left: unit.Dp(2), Top: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		box := material.CheckBox(p.Theme, &c.keep, text)
+		box.Color = p.Colors.PanelText
+		box.IconColor = p.Colors.PanelText
+		box.TextSize = unit.Sp(14)
+		return box.Layout(gtx)
+	})
+}
+
+func (p *Panel) layoutDiff(gtx layout.Context, r row) layout.Dimensions {
+	mark, bg := "+ ", p.Colors.Added
+	if r.removed {
+		mark, bg = "- ", p.Colors.Removed
+	}
+	m := op.Record(gtx.Ops)
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	dims := layout.Inset{Left: unit.Dp(20)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		lbl := material.Body2(p.Theme, mark+strings.ReplaceAll(r.text, "\t", "    "))
+		lbl.Color = p.Colors.PanelText
+		lbl.MaxLines = 1
+		return lbl.Layout(gtx)
+	})
+	call := m.Stop()
+	paint.FillShape(gtx.Ops, bg, clip.Rect{Max: dims.Size}.Op())
+	call.Add(gtx.Ops)
+	return dims
+}
+
+type paintColor struct {
+	fg, bg color.NRGBA
+}
+
+// clickable lays out w as a full width row that reacts to click.
+func (p *Panel) clickable(gtx layout.Context, click *gesture.Click, w layout.Widget) layout.Dimensions {
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	m := op.Record(gtx.Ops)
+	dims := w(gtx)
+	call := m.Stop()
+	rect := clip.Rect{Max: image.Pt(gtx.Constraints.Max.X, dims.Size.Y)}
+	if click.Hovered() {
+		paint.FillShape(gtx.Ops, p.Colors.Hover, rect.Op())
+	}
+	call.Add(gtx.Ops)
+	defer rect.Push(gtx.Ops).Pop()
+	click.Add(gtx.Ops)
+	return layout.Dimensions{Size: rect.Max, Baseline: dims.Baseline}
+}
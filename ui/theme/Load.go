@@ -0,0 +1,131 @@
+package theme
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vypal/vedit/libs"
+	"github.com/vypal/vedit/libs/syntax"
+)
+
+// themeFile is Vedit's own theme format, written as JSON or TOML:
+//
+//	name = "My Theme"
+//	[colors]
+//	background = "#1e1e1e"
+//	[syntax]
+//	keyword = "#569cd6"
+//
+// Colors that are left out keep their value from the default theme.
+type themeFile struct {
+	Name   string            `json:"name" toml:"name"`
+	Colors map[string]string `json:"colors" toml:"colors"`
+	Syntax map[string]string `json:"syntax" toml:"syntax"`
+}
+
+// Load reads a theme file. Besides Vedit's own JSON and TOML themes it
+// imports VS Code color themes and TextMate .tmTheme files.
+func Load(path string) (*Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var t *Theme
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		t, err = loadTOML(data)
+	case ".tmtheme", ".plist", ".xml":
+		t, err = loadTextMate(data)
+	case ".json", ".jsonc":
+		data = libs.StripJSONC(data)
+		if isVSCodeTheme(data) {
+			t, err = loadVSCode(path, data)
+		} else {
+			t, err = loadJSON(data)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown theme format", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if t.Name == "" {
+		t.Name = name
+	}
+	return t, nil
+}
+
+func loadJSON(data []byte) (*Theme, error) {
+	var f themeFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	return f.theme()
+}
+
+func loadTOML(data []byte) (*Theme, error) {
+	var f themeFile
+	md, err := toml.Decode(string(data), &f)
+	if err != nil {
+		return nil, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown key %q", undecoded[0].String())
+	}
+	return f.theme()
+}
+
+func (f *themeFile) theme() (*Theme, error) {
+	t := Default()
+	t.Name = f.Name
+	var errs []error
+	for _, key := range sortedKeys(f.Colors) {
+		field, ok := colorFields[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown color %q", key))
+			continue
+		}
+		c, err := ParseColor(f.Colors[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("color %q: %w", key, err))
+			continue
+		}
+		*field(t) = c
+	}
+	for _, key := range sortedKeys(f.Syntax) {
+		kind, ok := syntax.KindByName(key)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown syntax kind %q", key))
+			continue
+		}
+		c, err := ParseColor(f.Syntax[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("syntax %q: %w", key, err))
+			continue
+		}
+		t.Syntax[kind] = c
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return t, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,155 @@
+// Package theme holds the colors shared by the editor and the rest of the
+// window, and loads them from theme files.
+package theme
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/libs/syntax"
+)
+
+type Theme struct {
+	Name string
+
+	// Editor colors.
+	Background color.NRGBA
+	Foreground color.NRGBA
+	LineNumber color.NRGBA
+	Selection  color.NRGBA
+	Match      color.NRGBA
+	Caret      color.NRGBA
+	Scrollbar  color.NRGBA
+
+	// Colors of the panels around the editor: the toolbar, the file tree,
+	// the tab bar and popups.
+	Panel         color.NRGBA
+	PanelText     color.NRGBA
+	Split         color.NRGBA
+	ActiveTab     color.NRGBA
+	ListSelection color.NRGBA
+	Hover         color.NRGBA
+	Drop          color.NRGBA
+	Popup         color.NRGBA
+
+	// Backgrounds of the lines a diff adds and removes.
+	Added   color.NRGBA
+	Removed color.NRGBA
+
+	// Syntax colors of highlighted code. Kinds that are missing use
+	// Foreground.
+	Syntax map[syntax.Kind]color.NRGBA
+}
+
+// Default returns the built-in dark theme.
+func Default() *Theme {
+	return &Theme{
+		Name:          "Vedit Dark",
+		Background:    rgb(0x1A1B1B),
+		Foreground:    rgb(0xA3A4A5),
+		LineNumber:    color.NRGBA{R: 125, G: 125, B: 125, A: 125},
+		Selection:     color.NRGBA{R: 0x5A, G: 0x72, B: 0xB2, A: 0x66},
+		Match:         color.NRGBA{R: 0xEA, G: 0x5C, B: 0x00, A: 0x55},
+		Caret:         rgb(0xFFFFFF),
+		Scrollbar:     color.NRGBA{R: 0x5A, G: 0x5B, B: 0x5C, A: 0xAA},
+		Panel:         rgb(0x1A1B1B),
+		PanelText:     rgb(0xCCCCCC),
+		Split:         rgb(0x5A72B2),
+		ActiveTab:     rgb(0x1E1F20),
+		ListSelection: rgb(0x2A3A5A),
+		Hover:         rgb(0x252627),
+		Drop:          rgb(0x2F4F3A),
+		Popup:         rgb(0x252626),
+		Added:         color.NRGBA{R: 0x37, G: 0x8A, B: 0x37, A: 0x55},
+		Removed:       color.NRGBA{R: 0xC0, G: 0x3A, B: 0x3A, A: 0x55},
+		Syntax: map[syntax.Kind]color.NRGBA{
+			syntax.Keyword:     rgb(0x569CD6),
+			syntax.Type:        rgb(0x4EC9B0),
+			syntax.Builtin:     rgb(0xDCDCAA),
+			syntax.Function:    rgb(0xDCDCAA),
+			syntax.Constant:    rgb(0x569CD6),
+			syntax.Number:      rgb(0xB5CEA8),
+			syntax.String:      rgb(0xCE9178),
+			syntax.Escape:      rgb(0xD7BA7D),
+			syntax.Comment:     rgb(0x6A9955),
+			syntax.Operator:    rgb(0xD4D4D4),
+			syntax.Punctuation: rgb(0x808080),
+			syntax.Variable:    rgb(0x9CDCFE),
+			syntax.Key:         rgb(0x9CDCFE),
+			syntax.Heading:     rgb(0x569CD6),
+			syntax.Emphasis:    rgb(0xC586C0),
+			syntax.Strong:      rgb(0xC586C0),
+			syntax.Code:        rgb(0xCE9178),
+			syntax.Link:        rgb(0x4E94CE),
+		},
+	}
+}
+
+// SyntaxColor returns the color of spans of the given kind.
+func (t *Theme) SyntaxColor(k syntax.Kind) color.NRGBA {
+	if c, ok := t.Syntax[k]; ok {
+		return c
+	}
+	return t.Foreground
+}
+
+// Apply sets the palette of th, which the material widgets use, to match.
+func (t *Theme) Apply(th *material.Theme) {
+	th.Palette.Bg = t.Panel
+	th.Palette.Fg = t.PanelText
+	th.Palette.ContrastBg = t.Split
+	th.Palette.ContrastFg = t.PanelText
+}
+
+// colorFields maps the names used in theme files to the colors they set.
+var colorFields = map[string]func(t *Theme) *color.NRGBA{
+	"background":    func(t *Theme) *color.NRGBA { return &t.Background },
+	"foreground":    func(t *Theme) *color.NRGBA { return &t.Foreground },
+	"lineNumber":    func(t *Theme) *color.NRGBA { return &t.LineNumber },
+	"selection":     func(t *Theme) *color.NRGBA { return &t.Selection },
+	"match":         func(t *Theme) *color.NRGBA { return &t.Match },
+	"caret":         func(t *Theme) *color.NRGBA { return &t.Caret },
+	"scrollbar":     func(t *Theme) *color.NRGBA { return &t.Scrollbar },
+	"panel":         func(t *Theme) *color.NRGBA { return &t.Panel },
+	"panelText":     func(t *Theme) *color.NRGBA { return &t.PanelText },
+	"split":         func(t *Theme) *color.NRGBA { return &t.Split },
+	"activeTab":     func(t *Theme) *color.NRGBA { return &t.ActiveTab },
+	"listSelection": func(t *Theme) *color.NRGBA { return &t.ListSelection },
+	"hover":         func(t *Theme) *color.NRGBA { return &t.Hover },
+	"drop":          func(t *Theme) *color.NRGBA { return &t.Drop },
+	"popup":         func(t *Theme) *color.NRGBA { return &t.Popup },
+	"added":         func(t *Theme) *color.NRGBA { return &t.Added },
+	"removed":       func(t *Theme) *color.NRGBA { return &t.Removed },
+}
+
+// ParseColor parses a color written as #rgb, #rgba, #rrggbb or #rrggbbaa.
+func ParseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	switch len(hex) {
+	case 3, 4:
+		var long strings.Builder
+		for _, c := range hex {
+			long.WriteRune(c)
+			long.WriteRune(c)
+		}
+		hex = long.String()
+	case 6, 8:
+	default:
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+func rgb(v uint32) color.NRGBA {
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xFF}
+}
@@ -0,0 +1,358 @@
+package theme
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vypal/vedit/libs"
+	"github.com/vypal/vedit/libs/syntax"
+)
+
+// vscodeColors maps theme fields to the VS Code color keys they are taken
+// from, in order of preference.
+var vscodeColors = []struct {
+	field string
+	keys  []string
+}{
+	{"background", []string{"editor.background"}},
+	{"foreground", []string{"editor.foreground", "foreground"}},
+	{"lineNumber", []string{"editorLineNumber.foreground"}},
+	{"selection", []string{"editor.selectionBackground"}},
+	{"match", []string{"editor.findMatchHighlightBackground"}},
+	{"caret", []string{"editorCursor.foreground"}},
+	{"scrollbar", []string{"scrollbarSlider.background"}},
+	{"panel", []string{"sideBar.background", "editor.background"}},
+	{"panelText", []string{"sideBar.foreground", "foreground", "editor.foreground"}},
+	{"split", []string{"focusBorder", "editorGroup.border", "panel.border"}},
+	{"activeTab", []string{"tab.activeBackground", "editor.background"}},
+	{"listSelection", []string{"list.activeSelectionBackground", "list.inactiveSelectionBackground"}},
+	{"hover", []string{"list.hoverBackground"}},
+	{"drop", []string{"list.dropBackground"}},
+	{"popup", []string{"editorWidget.background", "menu.background", "sideBar.background"}},
+	{"added", []string{"diffEditor.insertedLineBackground", "diffEditor.insertedTextBackground"}},
+	{"removed", []string{"diffEditor.removedLineBackground", "diffEditor.removedTextBackground"}},
+}
+
+// kindScopes are the TextMate scopes that decide the color of each kind,
+// most specific first.
+var kindScopes = map[syntax.Kind][]string{
+	syntax.Keyword:     {"keyword.control", "keyword", "storage"},
+	syntax.Type:        {"entity.name.type", "support.type", "storage.type"},
+	syntax.Builtin:     {"support.function.builtin", "support.function"},
+	syntax.Function:    {"entity.name.function", "support.function"},
+	syntax.Constant:    {"constant.language", "constant"},
+	syntax.Number:      {"constant.numeric", "constant"},
+	syntax.String:      {"string"},
+	syntax.Escape:      {"constant.character.escape", "constant.character"},
+	syntax.Comment:     {"comment"},
+	syntax.Operator:    {"keyword.operator"},
+	syntax.Punctuation: {"punctuation"},
+	syntax.Variable:    {"variable"},
+	syntax.Key:         {"support.type.property-name", "entity.name.tag", "variable.other.property"},
+	syntax.Heading:     {"markup.heading", "entity.name.section"},
+	syntax.Emphasis:    {"markup.italic"},
+	syntax.Strong:      {"markup.bold"},
+	syntax.Code:        {"markup.inline.raw", "markup.raw"},
+	syntax.Link:        {"markup.underline.link", "string.other.link"},
+}
+
+// tokenRule colors the text matching any of its scope selectors.
+type tokenRule struct {
+	scopes     []string
+	foreground string
+}
+
+type vscodeTheme struct {
+	Name        string            `json:"name"`
+	Include     string            `json:"include"`
+	Colors      map[string]string `json:"colors"`
+	TokenColors json.RawMessage   `json:"tokenColors"`
+}
+
+type vscodeTokenColor struct {
+	Scope    json.RawMessage `json:"scope"`
+	Settings struct {
+		Foreground string `json:"foreground"`
+	} `json:"settings"`
+}
+
+// isVSCodeTheme tells VS Code themes from Vedit's own JSON format, whose
+// color names never contain dots.
+func isVSCodeTheme(data []byte) bool {
+	var probe vscodeTheme
+	if json.Unmarshal(data, &probe) != nil {
+		return false
+	}
+	if probe.TokenColors != nil || probe.Include != "" {
+		return true
+	}
+	for key := range probe.Colors {
+		if strings.Contains(key, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func loadVSCode(path string, data []byte) (*Theme, error) {
+	colors, rules, name, err := readVSCode(path, data, 0)
+	if err != nil {
+		return nil, err
+	}
+	t := Default()
+	t.Name = name
+	for _, c := range vscodeColors {
+		for _, key := range c.keys {
+			if v, ok := colors[key]; ok {
+				if parsed, err := ParseColor(v); err == nil {
+					*colorFields[c.field](t) = parsed
+					break
+				}
+			}
+		}
+	}
+	applyRules(t, rules)
+	return t, nil
+}
+
+// readVSCode reads a VS Code theme and the themes it includes.
+func readVSCode(path string, data []byte, depth int) (map[string]string, []tokenRule, string, error) {
+	if depth > 8 {
+		return nil, nil, "", errors.New("themes include each other")
+	}
+	var vt vscodeTheme
+	if err := json.Unmarshal(data, &vt); err != nil {
+		return nil, nil, "", err
+	}
+
+	colors := map[string]string{}
+	var rules []tokenRule
+	if vt.Include != "" {
+		base := filepath.Join(filepath.Dir(path), vt.Include)
+		included, err := os.ReadFile(base)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		colors, rules, _, err = readVSCode(base, libs.StripJSONC(included), depth+1)
+		if err != nil {
+			return nil, nil, "", err
+		}
+	}
+	for k, v := range vt.Colors {
+		colors[k] = v
+	}
+
+	var file string
+	if json.Unmarshal(vt.TokenColors, &file) == nil && file != "" {
+		// Token colors kept in a TextMate theme next to this one.
+		tm, err := os.ReadFile(filepath.Join(filepath.Dir(path), file))
+		if err != nil {
+			return nil, nil, "", err
+		}
+		_, tmRules, err := readTextMate(tm)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		rules = append(rules, tmRules...)
+	} else if len(vt.TokenColors) > 0 {
+		var tokens []vscodeTokenColor
+		if err := json.Unmarshal(vt.TokenColors, &tokens); err != nil {
+			return nil, nil, "", err
+		}
+		for _, tc := range tokens {
+			var scopes []string
+			var one string
+			if json.Unmarshal(tc.Scope, &one) == nil {
+				scopes = strings.Split(one, ",")
+			} else {
+				json.Unmarshal(tc.Scope, &scopes)
+			}
+			rules = append(rules, tokenRule{scopes: scopes, foreground: tc.Settings.Foreground})
+		}
+	}
+	return colors, rules, vt.Name, nil
+}
+
+// applyRules picks the color of every syntax kind from the rule with the
+// most specific selector matching the kind's scopes. Among equally specific
+// rules the later one wins, as in VS Code. Kinds that no rule matches use
+// the foreground color.
+func applyRules(t *Theme, rules []tokenRule) {
+	if len(rules) == 0 {
+		return
+	}
+	t.Syntax = map[syntax.Kind]color.NRGBA{}
+	for kind, scopes := range kindScopes {
+		for _, scope := range scopes {
+			best, bestLen := "", -1
+			for _, r := range rules {
+				if r.foreground == "" {
+					continue
+				}
+				for _, sel := range r.scopes {
+					sel = strings.TrimSpace(sel)
+					// Only the last part of a descendant selector such as
+					// "source.go keyword" is compared.
+					if i := strings.LastIndexByte(sel, ' '); i >= 0 {
+						sel = sel[i+1:]
+					}
+					if sel != "" && (scope == sel || strings.HasPrefix(scope, sel+".")) && len(sel) >= bestLen {
+						best, bestLen = r.foreground, len(sel)
+					}
+				}
+			}
+			if best != "" {
+				if c, err := ParseColor(best); err == nil {
+					t.Syntax[kind] = c
+					break
+				}
+			}
+		}
+	}
+}
+
+func loadTextMate(data []byte) (*Theme, error) {
+	settings, rules, err := readTextMate(data)
+	if err != nil {
+		return nil, err
+	}
+	t := Default()
+	if name, ok := settings["name"]; ok {
+		t.Name = name
+	}
+	global := map[string]func(*Theme) *color.NRGBA{
+		"background": colorFields["background"],
+		"foreground": colorFields["foreground"],
+		"caret":      colorFields["caret"],
+		"selection":  colorFields["selection"],
+	}
+	for key, field := range global {
+		if c, err := ParseColor(settings[key]); err == nil {
+			*field(t) = c
+		}
+	}
+	// TextMate themes only color the text area, so the panels follow it.
+	t.Panel = t.Background
+	t.ActiveTab = t.Background
+	applyRules(t, rules)
+	return t, nil
+}
+
+// readTextMate reads the global settings and scope rules of a TextMate
+// theme. The global settings also hold the theme's name.
+func readTextMate(data []byte) (map[string]string, []tokenRule, error) {
+	plist, err := decodePlist(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, ok := plist.(map[string]any)
+	if !ok {
+		return nil, nil, errors.New("not a TextMate theme")
+	}
+	global := map[string]string{}
+	if name, ok := root["name"].(string); ok {
+		global["name"] = name
+	}
+	var rules []tokenRule
+	entries, _ := root["settings"].([]any)
+	for _, e := range entries {
+		entry, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+		values, _ := entry["settings"].(map[string]any)
+		scope, hasScope := entry["scope"].(string)
+		if !hasScope {
+			for k, v := range values {
+				if s, ok := v.(string); ok {
+					global[k] = s
+				}
+			}
+			continue
+		}
+		fg, _ := values["foreground"].(string)
+		rules = append(rules, tokenRule{scopes: strings.Split(scope, ","), foreground: fg})
+	}
+	return global, rules, nil
+}
+
+// decodePlist decodes an XML property list into maps, slices and strings.
+// Other value types are skipped.
+func decodePlist(data []byte) (any, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, errors.New("empty property list")
+		} else if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local != "plist" {
+			return decodePlistValue(dec, start)
+		}
+	}
+}
+
+func decodePlistValue(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "dict":
+		m := map[string]any{}
+		key := ""
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "key" {
+					var k string
+					if err := dec.DecodeElement(&k, &t); err != nil {
+						return nil, err
+					}
+					key = k
+					continue
+				}
+				v, err := decodePlistValue(dec, t)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = v
+			case xml.EndElement:
+				return m, nil
+			}
+		}
+	case "array":
+		var list []any
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				v, err := decodePlistValue(dec, t)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			case xml.EndElement:
+				return list, nil
+			}
+		}
+	default:
+		var s string
+		if err := dec.DecodeElement(&s, &start); err != nil {
+			return nil, err
+		}
+		return strings.TrimSpace(s), nil
+	}
+}
@@ -2,7 +2,6 @@ package toolbar
 
 import (
 	"image"
-	"image/color"
 
 	"gioui.org/io/event"
 	"gioui.org/io/pointer"
@@ -11,11 +10,13 @@ import (
 	"gioui.org/op/paint"
 	"gioui.org/unit"
 	"gioui.org/widget/material"
+	"github.com/vypal/vedit/ui/commands"
+	"github.com/vypal/vedit/ui/theme"
 )
 
 type ToolBar struct {
-	Items           []ToolBarItem
-	BackgroundColor color.NRGBA
+	Items  []ToolBarItem
+	Colors *theme.Theme
 }
 
 type ToolBarItem interface {
@@ -23,7 +24,7 @@ type ToolBarItem interface {
 }
 
 func (tb *ToolBar) Layout(gtx layout.Context) layout.Dimensions {
-	paint.Fill(gtx.Ops, tb.BackgroundColor)
+	paint.Fill(gtx.Ops, tb.Colors.Panel)
 	children := make([]layout.FlexChild, len(tb.Items)+1)
 	for i, item := range tb.Items {
 		children[i] = layout.Rigid(item.Layout)
@@ -34,11 +35,15 @@ func (tb *ToolBar) Layout(gtx layout.Context) layout.Dimensions {
 	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, children...)
 }
 
+// Button runs Command through Commands when clicked if it is set, and calls
+// OnClick otherwise.
 type Button struct {
-	Text    string
-	Theme   *material.Theme
-	OnClick func()
-	hovered bool
+	Text     string
+	Theme    *material.Theme
+	Command  string
+	Commands *commands.Registry
+	OnClick  func()
+	hovered  bool
 }
 
 func (b *Button) Layout(gtx layout.Context) layout.Dimensions {
@@ -64,7 +69,9 @@ func (b *Button) Layout(gtx layout.Context) layout.Dimensions {
 			case pointer.Leave:
 				b.hovered = false
 			case pointer.Press:
-				if b.OnClick != nil {
+				if b.Command != "" && b.Commands != nil {
+					b.Commands.Run(b.Command)
+				} else if b.OnClick != nil {
 					b.OnClick()
 				}
 			}
@@ -0,0 +1,272 @@
+package widgets
+
+import (
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/libs/search"
+	"github.com/vypal/vedit/ui/theme"
+)
+
+// FindBar is an inline bar for searching a document, with an optional line
+// for replacing. It only collects what to look for and reports what the user
+// did; the owner does the searching and sets Status.
+//
+// Enter finds the next match and Shift+Enter the previous one. In the
+// replace field Enter replaces the current match and Ctrl+Alt+Enter all of
+// them. Alt+C, Alt+W and Alt+R toggle the options and Escape hides the bar.
+type FindBar struct {
+	Colors  *theme.Theme
+	Options search.Options
+	// Status is shown after the options, such as "3 of 12".
+	Status string
+
+	// OnChange is called whenever the pattern or the options change.
+	OnChange     func(pattern string, opts search.Options)
+	OnNext       func(backward bool)
+	OnReplace    func(replacement string)
+	OnReplaceAll func(replacement string)
+	OnHide       func()
+
+	find, replace widget.Editor
+	caseBtn       widget.Clickable
+	wordBtn       widget.Clickable
+	regexpBtn     widget.Clickable
+	prevBtn       widget.Clickable
+	nextBtn       widget.Clickable
+	closeBtn      widget.Clickable
+	replaceBtn    widget.Clickable
+	allBtn        widget.Clickable
+	visible       bool
+	replacing     bool
+	// focus is the field to focus during the next Layout.
+	focus *widget.Editor
+}
+
+// Show opens the bar, searching for pattern unless it is empty, and shows
+// the replace line if replace is set.
+func (f *FindBar) Show(pattern string, replace bool) {
+	f.find.SingleLine, f.find.Submit = true, true
+	f.replace.SingleLine, f.replace.Submit = true, true
+	if pattern != "" {
+		f.find.SetText(pattern)
+	}
+	f.find.SetCaret(f.find.Len(), 0)
+	f.replacing = replace
+	f.visible = true
+	f.focus = &f.find
+	f.changed()
+}
+
+func (f *FindBar) Hide() {
+	f.visible = false
+	if f.OnHide != nil {
+		f.OnHide()
+	}
+}
+
+func (f *FindBar) Visible() bool {
+	return f.visible
+}
+
+func (f *FindBar) Pattern() string {
+	return f.find.Text()
+}
+
+func (f *FindBar) changed() {
+	if f.OnChange != nil {
+		f.OnChange(f.find.Text(), f.Options)
+	}
+}
+
+func (f *FindBar) next(backward bool) {
+	if f.OnNext != nil {
+		f.OnNext(backward)
+	}
+}
+
+func (f *FindBar) doReplace(all bool) {
+	if all && f.OnReplaceAll != nil {
+		f.OnReplaceAll(f.replace.Text())
+	} else if !all && f.OnReplace != nil {
+		f.OnReplace(f.replace.Text())
+	}
+}
+
+// handleKeys processes the keys of one field that its editor doesn't use.
+// It reports whether the bar was hidden.
+func (f *FindBar) handleKeys(gtx layout.Context, field *widget.Editor) bool {
+	for {
+		e, ok := gtx.Event(
+			key.Filter{Focus: field, Name: key.NameEscape},
+			key.Filter{Focus: field, Name: key.NameReturn, Required: key.ModShift},
+			key.Filter{Focus: field, Name: key.NameReturn, Required: key.ModCtrl | key.ModAlt},
+			key.Filter{Focus: field, Name: "C", Required: key.ModAlt},
+			key.Filter{Focus: field, Name: "W", Required: key.ModAlt},
+			key.Filter{Focus: field, Name: "R", Required: key.ModAlt},
+		)
+		if !ok {
+			return false
+		}
+		ev, ok := e.(key.Event)
+		if !ok || ev.State != key.Press {
+			continue
+		}
+		switch ev.Name {
+		case key.NameEscape:
+			f.Hide()
+			return true
+		case key.NameReturn:
+			if ev.Modifiers.Contain(key.ModShift) {
+				f.next(true)
+			} else if field == &f.replace {
+				f.doReplace(true)
+			}
+		case "C":
+			f.Options.CaseSensitive = !f.Options.CaseSensitive
+			f.changed()
+		case "W":
+			f.Options.WholeWord = !f.Options.WholeWord
+			f.changed()
+		case "R":
+			f.Options.Regexp = !f.Options.Regexp
+			f.changed()
+		}
+	}
+}
+
+func (f *FindBar) update(gtx layout.Context) bool {
+	if f.handleKeys(gtx, &f.find) || f.handleKeys(gtx, &f.replace) {
+		return false
+	}
+	for {
+		ev, ok := f.find.Update(gtx)
+		if !ok {
+			break
+		}
+		switch ev.(type) {
+		case widget.ChangeEvent:
+			f.changed()
+		case widget.SubmitEvent:
+			f.next(false)
+		}
+	}
+	for {
+		ev, ok := f.replace.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, ok := ev.(widget.SubmitEvent); ok {
+			f.doReplace(false)
+		}
+	}
+
+	// Clicking a button takes the focus, so it is given back to the field.
+	toggles := []struct {
+		btn *widget.Clickable
+		opt *bool
+	}{
+		{&f.caseBtn, &f.Options.CaseSensitive},
+		{&f.wordBtn, &f.Options.WholeWord},
+		{&f.regexpBtn, &f.Options.Regexp},
+	}
+	for _, t := range toggles {
+		if t.btn.Clicked(gtx) {
+			*t.opt = !*t.opt
+			f.changed()
+			f.focus = &f.find
+		}
+	}
+	if f.prevBtn.Clicked(gtx) {
+		f.next(true)
+		f.focus = &f.find
+	}
+	if f.nextBtn.Clicked(gtx) {
+		f.next(false)
+		f.focus = &f.find
+	}
+	if f.replaceBtn.Clicked(gtx) {
+		f.doReplace(false)
+		f.focus = &f.replace
+	}
+	if f.allBtn.Clicked(gtx) {
+		f.doReplace(true)
+		f.focus = &f.replace
+	}
+	if f.closeBtn.Clicked(gtx) {
+		f.Hide()
+		return false
+	}
+	return true
+}
+
+func (f *FindBar) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	if !f.visible || !f.update(gtx) {
+		return layout.Dimensions{}
+	}
+	if f.focus != nil {
+		// Asking again until the field has the focus keeps widgets laid out
+		// later in the same frame from taking it.
+		if gtx.Focused(f.focus) {
+			f.focus = nil
+		} else {
+			gtx.Execute(key.FocusCmd{Tag: f.focus})
+			gtx.Execute(op.InvalidateCmd{})
+		}
+	}
+
+	gtx.Constraints.Min = gtx.Constraints.Max
+	gtx.Constraints.Min.Y = 0
+	return layout.Background{}.Layout(gtx,
+		func(gtx layout.Context) layout.Dimensions {
+			paint.FillShape(gtx.Ops, f.Colors.Popup, clip.Rect{Max: gtx.Constraints.Min}.Op())
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				rows := []layout.FlexChild{
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+							layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+								return TextField(gtx, th, f.Colors, &f.find, "Find")
+							}),
+							layout.Rigid(TextButton(th, f.Colors, &f.caseBtn, "Aa", f.Options.CaseSensitive)),
+							layout.Rigid(TextButton(th, f.Colors, &f.wordBtn, "ab", f.Options.WholeWord)),
+							layout.Rigid(TextButton(th, f.Colors, &f.regexpBtn, ".*", f.Options.Regexp)),
+							layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+								gtx.Constraints.Min.X = gtx.Dp(unit.Dp(90))
+								lbl := material.Body2(th, f.Status)
+								lbl.Color = f.Colors.PanelText
+								lbl.Alignment = text.Middle
+								return layout.Inset{Left: unit.Dp(6), Right: unit.Dp(6)}.Layout(gtx, lbl.Layout)
+							}),
+							layout.Rigid(TextButton(th, f.Colors, &f.prevBtn, "↑", false)),
+							layout.Rigid(TextButton(th, f.Colors, &f.nextBtn, "↓", false)),
+							layout.Rigid(TextButton(th, f.Colors, &f.closeBtn, "×", false)),
+						)
+					}),
+				}
+				if f.replacing {
+					rows = append(rows, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+							return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+								layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+									return TextField(gtx, th, f.Colors, &f.replace, "Replace")
+								}),
+								layout.Rigid(TextButton(th, f.Colors, &f.replaceBtn, "Replace", false)),
+								layout.Rigid(TextButton(th, f.Colors, &f.allBtn, "All", false)),
+							)
+						})
+					}))
+				}
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+			})
+		},
+	)
+}
@@ -11,6 +11,7 @@ import (
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"gioui.org/unit"
+	"github.com/vypal/vedit/ui/theme"
 )
 
 type Direction int
@@ -39,10 +40,12 @@ type Split struct {
 
 type LayoutManager struct {
 	RootSplit *Split
+	// Colors gives the color of the bars between splits.
+	Colors *theme.Theme
 }
 
 func NewLayoutManager() *LayoutManager {
-	return &LayoutManager{}
+	return &LayoutManager{Colors: theme.Default()}
 }
 
 func (lm *LayoutManager) Layout(gtx layout.Context) layout.Dimensions {
@@ -95,7 +98,7 @@ func (lm *LayoutManager) layoutFlexSplit(gtx layout.Context, axis layout.Axis, s
 		secondSize = gtx.Constraints.Max.Y - secondOffset
 	}
 
-	split.handleInput(gtx, axis, firstSize)
+	split.handleInput(gtx, axis, firstSize, lm.Colors.Split)
 
 	// Layout first child
 	{
@@ -126,7 +129,7 @@ func (lm *LayoutManager) layoutFlexSplit(gtx layout.Context, axis layout.Axis, s
 	return layout.Dimensions{Size: gtx.Constraints.Max}
 }
 
-func (split *Split) handleInput(gtx layout.Context, axis layout.Axis, firstSize int) {
+func (split *Split) handleInput(gtx layout.Context, axis layout.Axis, firstSize int, barColor color.NRGBA) {
 	var barRect image.Rectangle
 	if axis == layout.Vertical {
 		barRect = image.Rect(firstSize, 0, firstSize+gtx.Dp(defaultBarWidth), gtx.Constraints.Max.Y)
@@ -134,8 +137,6 @@ func (split *Split) handleInput(gtx layout.Context, axis layout.Axis, firstSize
 		barRect = image.Rect(0, firstSize, gtx.Constraints.Max.X, firstSize+gtx.Dp(defaultBarHeight))
 	}
 
-	var barColor = color.NRGBA{R: 0x5A, G: 0x72, B: 0xB2, A: 0xFF}
-
 	var expandedBarRect image.Rectangle
 	if axis == layout.Vertical {
 		expandedBarRect = image.Rect(barRect.Min.X-5, barRect.Min.Y, barRect.Max.X+5, barRect.Max.Y)
@@ -0,0 +1,166 @@
+package widgets
+
+import (
+	"image"
+
+	"gioui.org/gesture"
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/ui/commands"
+	"github.com/vypal/vedit/ui/theme"
+)
+
+// MenuItem runs Command through the menu's Commands when it is set, and
+// Action otherwise.
+type MenuItem struct {
+	Label   string
+	Command string
+	Action  func()
+}
+
+// Menu is a popup list of actions drawn above everything else. Clicking
+// outside of it or pressing Escape closes it.
+type Menu struct {
+	Colors   *theme.Theme
+	Commands *commands.Registry
+
+	items   []MenuItem
+	clicks  []gesture.Click
+	pos     image.Point
+	visible bool
+}
+
+// ShowAt opens the menu with its top left corner at pos, in the coordinates
+// of the widget calling Layout.
+func (m *Menu) ShowAt(pos image.Point, items []MenuItem) {
+	m.items = items
+	m.clicks = make([]gesture.Click, len(items))
+	m.pos = pos
+	m.visible = true
+}
+
+func (m *Menu) Hide() {
+	m.visible = false
+}
+
+func (m *Menu) Visible() bool {
+	return m.visible
+}
+
+func (m *Menu) Layout(gtx layout.Context, th *material.Theme) {
+	if !m.visible {
+		return
+	}
+
+	for {
+		ev, ok := gtx.Event(
+			pointer.Filter{Target: m, Kinds: pointer.Press},
+			key.Filter{Name: key.NameEscape},
+		)
+		if !ok {
+			break
+		}
+		switch ev := ev.(type) {
+		case pointer.Event:
+			m.Hide()
+			return
+		case key.Event:
+			if ev.State == key.Press {
+				m.Hide()
+				return
+			}
+		}
+	}
+	for i := range m.clicks {
+		for {
+			ev, ok := m.clicks[i].Update(gtx.Source)
+			if !ok {
+				break
+			}
+			if ev.Kind == gesture.KindClick {
+				m.Hide()
+				m.run(m.items[i])
+				return
+			}
+		}
+	}
+
+	macro := op.Record(gtx.Ops)
+	// Catch presses anywhere else to close the menu.
+	outside := clip.Rect{Min: image.Pt(-1<<20, -1<<20), Max: image.Pt(1<<20, 1<<20)}.Push(gtx.Ops)
+	event.Op(gtx.Ops, m)
+	outside.Pop()
+
+	gtx.Constraints.Min = image.Point{}
+	off := op.Offset(m.pos).Push(gtx.Ops)
+	items := op.Record(gtx.Ops)
+	children := make([]layout.FlexChild, len(m.items))
+	for i := range m.items {
+		i := i
+		children[i] = layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return m.layoutItem(gtx, th, i)
+		})
+	}
+	dims := layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	list := items.Stop()
+	paint.FillShape(gtx.Ops, m.Colors.Popup, clip.Rect{Max: dims.Size}.Op())
+	list.Add(gtx.Ops)
+	off.Pop()
+	op.Defer(gtx.Ops, macro.Stop())
+}
+
+func (m *Menu) run(item MenuItem) {
+	switch {
+	case item.Command != "" && m.Commands != nil:
+		m.Commands.Run(item.Command)
+	case item.Action != nil:
+		item.Action()
+	}
+}
+
+// shortcut returns the first key bound to the command of item, if any.
+func (m *Menu) shortcut(item MenuItem) string {
+	if item.Command == "" || m.Commands == nil {
+		return ""
+	}
+	if keys := m.Commands.KeysOf(item.Command); len(keys) > 0 {
+		return keys[0].String()
+	}
+	return ""
+}
+
+func (m *Menu) layoutItem(gtx layout.Context, th *material.Theme, i int) layout.Dimensions {
+	gtx.Constraints.Min.X = gtx.Dp(unit.Dp(160))
+	rec := op.Record(gtx.Ops)
+	dims := layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4), Left: unit.Dp(10), Right: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Alignment: layout.Baseline}.Layout(gtx,
+			layout.Rigid(material.Body2(th, m.items[i].Label).Layout),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				keys := m.shortcut(m.items[i])
+				if keys == "" {
+					return layout.Dimensions{}
+				}
+				lbl := material.Body2(th, keys)
+				lbl.Color = m.Colors.LineNumber
+				return layout.Inset{Left: unit.Dp(24)}.Layout(gtx, lbl.Layout)
+			}),
+		)
+	})
+	label := rec.Stop()
+
+	rect := clip.Rect{Max: dims.Size}
+	if m.clicks[i].Hovered() {
+		paint.FillShape(gtx.Ops, m.Colors.ListSelection, rect.Op())
+	}
+	label.Add(gtx.Ops)
+	defer rect.Push(gtx.Ops).Pop()
+	m.clicks[i].Add(gtx.Ops)
+	return dims
+}
@@ -0,0 +1,286 @@
+package widgets
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/font"
+	"gioui.org/gesture"
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/ui/theme"
+)
+
+// PickerItem is a line of a Picker. The runes of Title and Detail at the
+// indices in TitleMatched and DetailMatched are highlighted.
+type PickerItem struct {
+	Title         string
+	Detail        string
+	TitleMatched  []int
+	DetailMatched []int
+}
+
+// Picker is a list to choose from shown over the top of the window, which
+// is narrowed down by typing. Up and Down move the selection, Enter picks it
+// and Escape closes the picker.
+type Picker struct {
+	Colors *theme.Theme
+	// Hint is shown while nothing has been typed.
+	Hint string
+	// Filter returns the items for what was typed, best first.
+	Filter func(query string) []PickerItem
+	// OnPick is called with the index of the item picked among those Filter
+	// returned last.
+	OnPick func(i int)
+	// OnHide is called whenever the picker closes, picked or not.
+	OnHide func()
+
+	editor   widget.Editor
+	items    []PickerItem
+	selected int
+	list     widget.List
+	clicks   []gesture.Click
+	visible  bool
+	focus    bool
+	// stale is set when the items have to be filtered again.
+	stale bool
+}
+
+// Show opens the picker with query typed in.
+func (p *Picker) Show(query string) {
+	p.editor.SingleLine = true
+	p.editor.Submit = true
+	p.editor.SetText(query)
+	p.editor.SetCaret(p.editor.Len(), 0)
+	p.list.Axis = layout.Vertical
+	p.visible = true
+	p.focus = true
+	p.stale = true
+}
+
+func (p *Picker) Hide() {
+	p.visible = false
+	p.items = nil
+	if p.OnHide != nil {
+		p.OnHide()
+	}
+}
+
+func (p *Picker) Visible() bool {
+	return p.visible
+}
+
+// Refresh filters the items again in the next frame, for when what Filter
+// returns changed.
+func (p *Picker) Refresh() {
+	p.stale = true
+}
+
+func (p *Picker) filter() {
+	p.stale = false
+	if p.Filter != nil {
+		p.items = p.Filter(p.editor.Text())
+	}
+	p.selected = max(0, min(p.selected, len(p.items)-1))
+	if len(p.clicks) < len(p.items) {
+		p.clicks = make([]gesture.Click, len(p.items))
+	}
+}
+
+func (p *Picker) pick(i int) {
+	if i < 0 || i >= len(p.items) {
+		return
+	}
+	onPick := p.OnPick
+	p.Hide()
+	if onPick != nil {
+		onPick(i)
+	}
+}
+
+// move moves the selection by n items, scrolling it into view.
+func (p *Picker) move(n int) {
+	if len(p.items) == 0 {
+		return
+	}
+	p.selected = max(0, min(p.selected+n, len(p.items)-1))
+	if p.selected < p.list.Position.First {
+		p.list.Position.First, p.list.Position.Offset = p.selected, 0
+	} else if last := p.list.Position.First + p.list.Position.Count - 1; p.selected >= last && p.list.Position.Count > 0 {
+		p.list.Position.First = p.selected - p.list.Position.Count + 2
+		p.list.Position.Offset = 0
+	}
+}
+
+// update handles input, reporting false once the picker has closed.
+func (p *Picker) update(gtx layout.Context) bool {
+	for {
+		ev, ok := gtx.Event(
+			key.Filter{Focus: &p.editor, Name: key.NameEscape},
+			key.Filter{Focus: &p.editor, Name: key.NameUpArrow},
+			key.Filter{Focus: &p.editor, Name: key.NameDownArrow},
+			key.Filter{Focus: &p.editor, Name: key.NamePageUp},
+			key.Filter{Focus: &p.editor, Name: key.NamePageDown},
+		)
+		if !ok {
+			break
+		}
+		ke, ok := ev.(key.Event)
+		if !ok || ke.State != key.Press {
+			continue
+		}
+		switch ke.Name {
+		case key.NameEscape:
+			p.Hide()
+			return false
+		case key.NameUpArrow:
+			p.move(-1)
+		case key.NameDownArrow:
+			p.move(1)
+		case key.NamePageUp:
+			p.move(-max(1, p.list.Position.Count-1))
+		case key.NamePageDown:
+			p.move(max(1, p.list.Position.Count-1))
+		}
+	}
+	for {
+		ev, ok := p.editor.Update(gtx)
+		if !ok {
+			break
+		}
+		switch ev.(type) {
+		case widget.ChangeEvent:
+			p.stale = true
+			p.selected = 0
+			p.list.Position = layout.Position{}
+		case widget.SubmitEvent:
+			if p.stale {
+				p.filter()
+			}
+			p.pick(p.selected)
+			return false
+		}
+	}
+	for i := range p.items {
+		for {
+			ev, ok := p.clicks[i].Update(gtx.Source)
+			if !ok {
+				break
+			}
+			if ev.Kind == gesture.KindClick {
+				p.pick(i)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *Picker) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	if !p.visible || !p.update(gtx) {
+		return layout.Dimensions{}
+	}
+	if p.stale {
+		p.filter()
+	}
+	if p.focus {
+		gtx.Execute(key.FocusCmd{Tag: &p.editor})
+		p.focus = false
+	}
+
+	// The picker is centred at the top, and only as tall as its items.
+	width := min(gtx.Dp(unit.Dp(640)), gtx.Constraints.Max.X)
+	defer op.Offset(image.Pt((gtx.Constraints.Max.X-width)/2, 0)).Push(gtx.Ops).Pop()
+	gtx.Constraints = layout.Exact(image.Pt(width, min(gtx.Dp(unit.Dp(420)), gtx.Constraints.Max.Y)))
+	gtx.Constraints.Min.Y = 0
+
+	m := op.Record(gtx.Ops)
+	dims := layout.UniformInset(unit.Dp(6)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return TextField(gtx, th, p.Colors, &p.editor, p.Hint)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return material.List(th, &p.list).Layout(gtx, len(p.items), func(gtx layout.Context, i int) layout.Dimensions {
+						return p.layoutItem(gtx, th, i)
+					})
+				})
+			}),
+		)
+	})
+	call := m.Stop()
+	paint.FillShape(gtx.Ops, p.Colors.Popup, clip.Rect{Max: dims.Size}.Op())
+	call.Add(gtx.Ops)
+	return dims
+}
+
+func (p *Picker) layoutItem(gtx layout.Context, th *material.Theme, i int) layout.Dimensions {
+	item := p.items[i]
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	m := op.Record(gtx.Ops)
+	dims := layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Alignment: layout.Baseline}.Layout(gtx,
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return p.layoutMatched(gtx, th, item.Title, item.TitleMatched, p.Colors.PanelText)
+			}),
+			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Left: unit.Dp(10)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return p.layoutMatched(gtx, th, item.Detail, item.DetailMatched, p.Colors.LineNumber)
+				})
+			}),
+		)
+	})
+	call := m.Stop()
+	rect := clip.Rect{Max: image.Pt(gtx.Constraints.Max.X, dims.Size.Y)}
+	switch {
+	case i == p.selected:
+		paint.FillShape(gtx.Ops, p.Colors.ListSelection, rect.Op())
+	case p.clicks[i].Hovered():
+		paint.FillShape(gtx.Ops, p.Colors.Hover, rect.Op())
+	}
+	call.Add(gtx.Ops)
+	defer rect.Push(gtx.Ops).Pop()
+	p.clicks[i].Add(gtx.Ops)
+	return layout.Dimensions{Size: rect.Max, Baseline: dims.Baseline}
+}
+
+// layoutMatched lays out text on one line in color c, with the runes at
+// matched in bold and in the theme's foreground color.
+func (p *Picker) layoutMatched(gtx layout.Context, th *material.Theme, text string, matched []int, c color.NRGBA) layout.Dimensions {
+	runes := []rune(text)
+	var children []layout.FlexChild
+	span := func(from, to int, bold bool) {
+		s := string(runes[from:to])
+		children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			lbl := material.Body1(th, s)
+			lbl.MaxLines = 1
+			lbl.Color = c
+			if bold {
+				lbl.Font.Weight = font.Bold
+				lbl.Color = p.Colors.Foreground
+			}
+			return lbl.Layout(gtx)
+		}))
+	}
+	from, k := 0, 0
+	for from < len(runes) {
+		bold := k < len(matched) && matched[k] == from
+		to := from
+		for to < len(runes) && (k < len(matched) && matched[k] == to) == bold {
+			to++
+			if bold {
+				k++
+			}
+		}
+		span(from, to, bold)
+		from = to
+	}
+	return layout.Flex{Alignment: layout.Baseline}.Layout(gtx, children...)
+}
@@ -0,0 +1,106 @@
+package widgets
+
+import (
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/ui/theme"
+)
+
+// Prompt is a single line input shown over the top of the window, used to ask
+// for things like file names. Enter submits the text, Escape cancels.
+type Prompt struct {
+	Label string
+
+	Colors *theme.Theme
+	// OnHide is called whenever the prompt closes, submitted or not.
+	OnHide func()
+
+	editor   widget.Editor
+	visible  bool
+	focus    bool
+	onSubmit func(text string)
+}
+
+func (p *Prompt) Show(label, text string, onSubmit func(text string)) {
+	p.Label = label
+	p.editor.SingleLine = true
+	p.editor.Submit = true
+	p.editor.SetText(text)
+	p.editor.SetCaret(p.editor.Len(), p.editor.Len())
+	p.onSubmit = onSubmit
+	p.visible = true
+	p.focus = true
+}
+
+func (p *Prompt) Hide() {
+	p.visible = false
+	p.onSubmit = nil
+	if p.OnHide != nil {
+		p.OnHide()
+	}
+}
+
+func (p *Prompt) Visible() bool {
+	return p.visible
+}
+
+func (p *Prompt) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	if !p.visible {
+		return layout.Dimensions{}
+	}
+	if p.focus {
+		gtx.Execute(key.FocusCmd{Tag: &p.editor})
+		p.focus = false
+	}
+
+	for {
+		ev, ok := gtx.Event(key.Filter{Focus: &p.editor, Name: key.NameEscape})
+		if !ok {
+			break
+		}
+		if ev, ok := ev.(key.Event); ok && ev.State == key.Press {
+			p.Hide()
+			return layout.Dimensions{}
+		}
+	}
+	for {
+		ev, ok := p.editor.Update(gtx)
+		if !ok {
+			break
+		}
+		if ev, ok := ev.(widget.SubmitEvent); ok {
+			submit := p.onSubmit
+			p.Hide()
+			if submit != nil {
+				submit(ev.Text)
+			}
+			return layout.Dimensions{}
+		}
+	}
+
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	gtx.Constraints.Min.Y = 0
+	return layout.Background{}.Layout(gtx,
+		func(gtx layout.Context) layout.Dimensions {
+			defer clip.Rect{Max: gtx.Constraints.Min}.Push(gtx.Ops).Pop()
+			paint.ColorOp{Color: p.Colors.Popup}.Add(gtx.Ops)
+			paint.PaintOp{}.Add(gtx.Ops)
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Alignment: layout.Baseline}.Layout(gtx,
+					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+						return layout.Inset{Right: unit.Dp(8)}.Layout(gtx, material.Body1(th, p.Label).Layout)
+					}),
+					layout.Flexed(1, material.Editor(th, &p.editor, "").Layout),
+				)
+			})
+		},
+	)
+}
@@ -0,0 +1,44 @@
+package widgets
+
+import (
+	"image"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/ui/theme"
+)
+
+// StatusBar is a line of text along the bottom of the editor, with Left at
+// its start and Right at its end.
+type StatusBar struct {
+	Colors      *theme.Theme
+	Left, Right string
+}
+
+func (s *StatusBar) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	height := gtx.Dp(unit.Dp(22))
+	size := image.Pt(gtx.Constraints.Max.X, height)
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	paint.Fill(gtx.Ops, s.Colors.Panel)
+
+	gtx.Constraints = layout.Exact(size)
+	return layout.Inset{Left: unit.Dp(8), Right: unit.Dp(8)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+			layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Body2(th, s.Left)
+				lbl.Color = s.Colors.PanelText
+				lbl.MaxLines = 1
+				return layout.W.Layout(gtx, lbl.Layout)
+			}),
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				lbl := material.Body2(th, s.Right)
+				lbl.Color = s.Colors.PanelText
+				lbl.MaxLines = 1
+				return lbl.Layout(gtx)
+			}),
+		)
+	})
+}
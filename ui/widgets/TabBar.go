@@ -0,0 +1,188 @@
+package widgets
+
+import (
+	"image"
+
+	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/ui/theme"
+)
+
+type Tab struct {
+	Title string
+	Dirty bool
+}
+
+// TabBar is a strip of tabs. It only draws the tabs and reports what the
+// user did with them; the owner keeps the list and the active tab.
+type TabBar struct {
+	Tabs   []Tab
+	Active int
+
+	Colors *theme.Theme
+
+	OnSelect func(i int)
+	OnClose  func(i int)
+	// OnMove is called while a tab is dragged to another place.
+	OnMove func(from, to int)
+
+	// bounds and closes are the areas of the tabs and their close buttons
+	// from the last Layout, in bar coordinates.
+	bounds   []image.Rectangle
+	closes   []image.Rectangle
+	scroll   int
+	dragging bool
+	dragID   pointer.ID
+	dragTab  int
+	hover    int
+	// shown is the tab that was last scrolled into view.
+	shown int
+}
+
+func (tb *TabBar) tabAt(pos f32.Point) int {
+	pt := image.Pt(int(pos.X)+tb.scroll, int(pos.Y))
+	for i, r := range tb.bounds {
+		if pt.In(r) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (tb *TabBar) handlePointer(gtx layout.Context, ev pointer.Event) {
+	switch ev.Kind {
+	case pointer.Move:
+		tb.hover = tb.tabAt(ev.Position)
+	case pointer.Leave:
+		tb.hover = -1
+	case pointer.Scroll:
+		tb.scroll += int(ev.Scroll.X + ev.Scroll.Y)
+	case pointer.Press:
+		i := tb.tabAt(ev.Position)
+		if i < 0 || tb.dragging {
+			break
+		}
+		switch {
+		case ev.Buttons == pointer.ButtonTertiary:
+			if tb.OnClose != nil {
+				tb.OnClose(i)
+			}
+		case ev.Buttons != pointer.ButtonPrimary:
+		case i < len(tb.closes) && image.Pt(int(ev.Position.X)+tb.scroll, int(ev.Position.Y)).In(tb.closes[i]):
+			if tb.OnClose != nil {
+				tb.OnClose(i)
+			}
+		default:
+			if tb.OnSelect != nil {
+				tb.OnSelect(i)
+			}
+			tb.dragging = true
+			tb.dragID = ev.PointerID
+			tb.dragTab = i
+		}
+	case pointer.Drag:
+		if !tb.dragging || ev.PointerID != tb.dragID {
+			break
+		}
+		// Only the horizontal position matters while reordering.
+		if i := tb.tabAt(f32.Point{X: ev.Position.X}); i >= 0 && i != tb.dragTab {
+			if tb.OnMove != nil {
+				tb.OnMove(tb.dragTab, i)
+			}
+			tb.dragTab = i
+		}
+		if ev.Priority < pointer.Grabbed {
+			gtx.Execute(pointer.GrabCmd{Tag: tb, ID: tb.dragID})
+		}
+	case pointer.Release, pointer.Cancel:
+		tb.dragging = false
+	}
+}
+
+func (tb *TabBar) Layout(gtx layout.Context, th *material.Theme) layout.Dimensions {
+	height := gtx.Dp(unit.Dp(30))
+	size := image.Pt(gtx.Constraints.Max.X, height)
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+	paint.Fill(gtx.Ops, tb.Colors.Panel)
+
+	event.Op(gtx.Ops, tb)
+	for {
+		ev, ok := gtx.Event(pointer.Filter{
+			Target:  tb,
+			Kinds:   pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel | pointer.Move | pointer.Leave | pointer.Scroll,
+			ScrollX: pointer.ScrollRange{Min: -1 << 20, Max: 1 << 20},
+			ScrollY: pointer.ScrollRange{Min: -1 << 20, Max: 1 << 20},
+		})
+		if !ok {
+			break
+		}
+		if ev, ok := ev.(pointer.Event); ok {
+			tb.handlePointer(gtx, ev)
+		}
+	}
+
+	// Lay the tabs out first to know their widths.
+	tb.bounds = tb.bounds[:0]
+	tb.closes = tb.closes[:0]
+	calls := make([]op.CallOp, len(tb.Tabs))
+	x := 0
+	pad := gtx.Dp(unit.Dp(10))
+	for i, tab := range tb.Tabs {
+		m := op.Record(gtx.Ops)
+		title := material.Body2(th, tab.Title)
+		title.Color = tb.Colors.PanelText
+		title.MaxLines = 1
+		tgtx := gtx
+		tgtx.Constraints = layout.Constraints{Min: image.Pt(0, height), Max: image.Pt(gtx.Dp(unit.Dp(200)), height)}
+		op.Offset(image.Pt(pad, 0)).Add(gtx.Ops)
+		dims := layout.W.Layout(tgtx, title.Layout)
+		// The close button shows a dot while the tab has unsaved changes.
+		mark := "×"
+		if tab.Dirty && tb.hover != i {
+			mark = "●"
+		}
+		op.Offset(image.Pt(dims.Size.X+pad/2, 0)).Add(gtx.Ops)
+		closeLbl := material.Body2(th, mark)
+		closeLbl.Color = tb.Colors.PanelText
+		cgtx := gtx
+		cgtx.Constraints = layout.Constraints{Min: image.Pt(height*2/3, height), Max: image.Pt(height, height)}
+		cdims := layout.Center.Layout(cgtx, closeLbl.Layout)
+		calls[i] = m.Stop()
+
+		w := pad + dims.Size.X + pad/2 + cdims.Size.X + pad/2
+		tb.bounds = append(tb.bounds, image.Rect(x, 0, x+w, height))
+		cx := x + pad + dims.Size.X + pad/2
+		tb.closes = append(tb.closes, image.Rect(cx, 0, cx+cdims.Size.X, height))
+		x += w
+	}
+
+	// Scroll to the active tab when it changes.
+	if tb.Active != tb.shown && tb.Active >= 0 && tb.Active < len(tb.bounds) {
+		tb.shown = tb.Active
+		r := tb.bounds[tb.Active]
+		if r.Min.X < tb.scroll {
+			tb.scroll = r.Min.X
+		} else if r.Max.X > tb.scroll+size.X {
+			tb.scroll = r.Max.X - size.X
+		}
+	}
+	tb.scroll = max(0, min(tb.scroll, x-size.X))
+
+	for i, call := range calls {
+		r := tb.bounds[i].Sub(image.Pt(tb.scroll, 0))
+		if i == tb.Active {
+			paint.FillShape(gtx.Ops, tb.Colors.ActiveTab, clip.Rect(r).Op())
+		}
+		off := op.Offset(r.Min).Push(gtx.Ops)
+		call.Add(gtx.Ops)
+		off.Pop()
+	}
+	return layout.Dimensions{Size: size}
+}
@@ -0,0 +1,56 @@
+package widgets
+
+import (
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/vypal/vedit/ui/theme"
+)
+
+// TextField lays out a single line editor on a background, showing hint
+// while it is empty.
+func TextField(gtx layout.Context, th *material.Theme, colors *theme.Theme, field *widget.Editor, hint string) layout.Dimensions {
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	return layout.Background{}.Layout(gtx,
+		func(gtx layout.Context) layout.Dimensions {
+			paint.FillShape(gtx.Ops, colors.Background, clip.Rect{Max: gtx.Constraints.Min}.Op())
+			return layout.Dimensions{Size: gtx.Constraints.Min}
+		},
+		func(gtx layout.Context) layout.Dimensions {
+			return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				ed := material.Editor(th, field, hint)
+				ed.Color = colors.PanelText
+				ed.HintColor = colors.LineNumber
+				return ed.Layout(gtx)
+			})
+		},
+	)
+}
+
+// TextButton returns a small flat button showing label, highlighted while
+// active, for toggles.
+func TextButton(th *material.Theme, colors *theme.Theme, btn *widget.Clickable, label string, active bool) layout.Widget {
+	return func(gtx layout.Context) layout.Dimensions {
+		return layout.Inset{Left: unit.Dp(2)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+			return btn.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				m := op.Record(gtx.Ops)
+				lbl := material.Body2(th, label)
+				lbl.Color = colors.PanelText
+				dims := layout.UniformInset(unit.Dp(4)).Layout(gtx, lbl.Layout)
+				content := m.Stop()
+				switch {
+				case active:
+					paint.FillShape(gtx.Ops, colors.ListSelection, clip.Rect{Max: dims.Size}.Op())
+				case btn.Hovered():
+					paint.FillShape(gtx.Ops, colors.Hover, clip.Rect{Max: dims.Size}.Op())
+				}
+				content.Add(gtx.Ops)
+				return dims
+			})
+		})
+	}
+}